@@ -1,12 +1,23 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
 	"github.com/projectdiscovery/subfinder/v2/pkg/runner"
 	// Attempts to increase the OS file descriptors - Fail silently
 	_ "github.com/projectdiscovery/fdmax/autofdmax"
 	"github.com/projectdiscovery/gologger"
+	contextutil "github.com/projectdiscovery/utils/context"
 )
 
+// exitCodeInterrupted is the exit code of an enumeration interrupted by a signal
+const exitCodeInterrupted = 130
+
 func main() {
 	// Parse the command line flags and read config files
 	options := runner.ParseOptions()
@@ -16,7 +27,35 @@ func main() {
 		gologger.Fatal().Msgf("Could not create runner: %s\n", err)
 	}
 
-	err = newRunner.RunEnumeration()
+	// The first signal stops the enumeration and writes the partial results,
+	// restoring the default behavior so that a second one exits immediately
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	ctx, _ = contextutil.WithValues(ctx, contextutil.ContextArg("All"), contextutil.ContextArg(strconv.FormatBool(options.All)))
+
+	if options.VerifyKeys {
+		if err := newRunner.VerifyKeys(ctx); err != nil {
+			gologger.Fatal().Msgf("Could not verify keys: %s\n", err)
+		}
+		return
+	}
+
+	if options.Serve != "" {
+		if err := newRunner.Serve(ctx, options.Serve); err != nil {
+			gologger.Fatal().Msgf("Could not run server: %s\n", err)
+		}
+		return
+	}
+
+	err = newRunner.RunEnumerationWithCtx(ctx)
+	stop()
+	if errors.Is(err, runner.ErrInterrupted) {
+		gologger.Warning().Msgf("Enumeration interrupted, partial results were written\n")
+		os.Exit(exitCodeInterrupted)
+	}
 	if err != nil {
 		gologger.Fatal().Msgf("Could not run enumeration: %s\n", err)
 	}
@@ -49,6 +49,17 @@ func main() {
 	// print the output
 	log.Println(output.String())
 
+	// Or iterate over typed results, with all their sources, without any writer
+	// enumeration := subfinder.Enumerate(context.Background(), "hackerone.com")
+	// for subdomain, err := range enumeration.Results() {
+	// 	if err != nil {
+	// 		log.Printf("error: %v\n", err)
+	// 		continue
+	// 	}
+	// 	log.Printf("%s %v\n", subdomain.Host, subdomain.Sources)
+	// }
+	// log.Printf("found %d subdomains in %s\n", enumeration.Summary().Subdomains, enumeration.Summary().Duration)
+
 	// Or use sourceMap to access the results in your application
 	for subdomain, sources := range sourceMap {
 		sourcesList := make([]string, 0, len(sources))
@@ -0,0 +1,3 @@
+// Package metrics collects the metrics of the sources and of the resolution
+// and exposes them in the Prometheus text format.
+package metrics
@@ -0,0 +1,312 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Outcomes of the resolution of a host
+const (
+	Resolved   = "resolved"
+	Unresolved = "unresolved"
+	Wildcard   = "wildcard"
+	Failed     = "error"
+)
+
+// DurationBuckets are the upper bounds in seconds of the request latency histogram
+var DurationBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// Default is the registry the sources and the resolution pool report to
+var Default = New()
+
+// Registry holds the metrics of the enumeration
+type Registry struct {
+	mu            sync.Mutex
+	requests      map[sourceStatus]uint64
+	durations     map[string]*histogram
+	results       map[string]uint64
+	errors        map[string]uint64
+	rateLimitWait map[string]time.Duration
+	resolutions   map[string]uint64
+	keyRequests   map[sourceKey]uint64
+	keyFailures   map[sourceKey]uint64
+	keyStatus     map[sourceKey]string
+}
+
+type sourceStatus struct {
+	source string
+	status string
+}
+
+// sourceKey is the label of an API key of a source
+type sourceKey struct {
+	source string
+	key    string
+}
+
+type histogram struct {
+	buckets []uint64
+	sum     float64
+	count   uint64
+}
+
+// New creates an empty registry
+func New() *Registry {
+	return &Registry{
+		requests:      make(map[sourceStatus]uint64),
+		durations:     make(map[string]*histogram),
+		results:       make(map[string]uint64),
+		errors:        make(map[string]uint64),
+		rateLimitWait: make(map[string]time.Duration),
+		resolutions:   make(map[string]uint64),
+		keyRequests:   make(map[sourceKey]uint64),
+		keyFailures:   make(map[sourceKey]uint64),
+		keyStatus:     make(map[sourceKey]string),
+	}
+}
+
+// ObserveRequest records an HTTP request of a source, the status code
+// being 0 when no response was received
+func (r *Registry) ObserveRequest(source string, statusCode int, duration time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.requests[sourceStatus{source: source, status: statusClass(statusCode)}]++
+
+	h, ok := r.durations[source]
+	if !ok {
+		h = &histogram{buckets: make([]uint64, len(DurationBuckets))}
+		r.durations[source] = h
+	}
+	seconds := duration.Seconds()
+	for i, bound := range DurationBuckets {
+		if seconds <= bound {
+			h.buckets[i]++
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+// ObserveRateLimitWait records the time a source waited for the rate limiter
+func (r *Registry) ObserveRateLimitWait(source string, wait time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.rateLimitWait[source] += wait
+}
+
+// AddResult records a subdomain returned by a source
+func (r *Registry) AddResult(source string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.results[source]++
+}
+
+// AddError records an error returned by a source
+func (r *Registry) AddError(source string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.errors[source]++
+}
+
+// ObserveResolution records the outcome of the resolution of a host
+func (r *Registry) ObserveResolution(outcome string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.resolutions[outcome]++
+}
+
+// ObserveKeyRequest records a request of a source sent with an API key, labelled
+// without revealing the key, along with the status of the key after its response
+func (r *Registry) ObserveKeyRequest(source, key string, failed bool, status string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	k := sourceKey{source: source, key: key}
+	r.keyRequests[k]++
+	if failed {
+		r.keyFailures[k]++
+	}
+	r.keyStatus[k] = status
+}
+
+// WriteTo writes the metrics in the Prometheus text exposition format
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+
+	writeHeader(cw, "subfinder_source_requests_total", "counter", "HTTP requests sent by the sources by status class.")
+	keys := make([]sourceStatus, 0, len(r.requests))
+	for key := range r.requests {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].source != keys[j].source {
+			return keys[i].source < keys[j].source
+		}
+		return keys[i].status < keys[j].status
+	})
+	for _, key := range keys {
+		fmt.Fprintf(cw, "subfinder_source_requests_total{source=%s,status=%s} %d\n", quote(key.source), quote(key.status), r.requests[key])
+	}
+
+	writeHeader(cw, "subfinder_source_request_duration_seconds", "histogram", "Latency of the HTTP requests sent by the sources.")
+	for _, source := range sortedKeys(r.durations) {
+		h := r.durations[source]
+		for i, bound := range DurationBuckets {
+			fmt.Fprintf(cw, "subfinder_source_request_duration_seconds_bucket{source=%s,le=%s} %d\n", quote(source), quote(formatFloat(bound)), h.buckets[i])
+		}
+		fmt.Fprintf(cw, "subfinder_source_request_duration_seconds_bucket{source=%s,le=\"+Inf\"} %d\n", quote(source), h.count)
+		fmt.Fprintf(cw, "subfinder_source_request_duration_seconds_sum{source=%s} %s\n", quote(source), formatFloat(h.sum))
+		fmt.Fprintf(cw, "subfinder_source_request_duration_seconds_count{source=%s} %d\n", quote(source), h.count)
+	}
+
+	writeHeader(cw, "subfinder_source_results_total", "counter", "Subdomains returned by the sources.")
+	for _, source := range sortedKeys(r.results) {
+		fmt.Fprintf(cw, "subfinder_source_results_total{source=%s} %d\n", quote(source), r.results[source])
+	}
+
+	writeHeader(cw, "subfinder_source_errors_total", "counter", "Errors returned by the sources.")
+	for _, source := range sortedKeys(r.errors) {
+		fmt.Fprintf(cw, "subfinder_source_errors_total{source=%s} %d\n", quote(source), r.errors[source])
+	}
+
+	writeHeader(cw, "subfinder_source_rate_limit_wait_seconds_total", "counter", "Time the sources waited for the rate limiter.")
+	for _, source := range sortedKeys(r.rateLimitWait) {
+		fmt.Fprintf(cw, "subfinder_source_rate_limit_wait_seconds_total{source=%s} %s\n", quote(source), formatFloat(r.rateLimitWait[source].Seconds()))
+	}
+
+	writeHeader(cw, "subfinder_resolutions_total", "counter", "Resolutions of the found hosts by outcome.")
+	for _, outcome := range sortedKeys(r.resolutions) {
+		fmt.Fprintf(cw, "subfinder_resolutions_total{outcome=%s} %d\n", quote(outcome), r.resolutions[outcome])
+	}
+
+	writeHeader(cw, "subfinder_key_requests_total", "counter", "HTTP requests sent by the sources with each API key.")
+	sourceKeys := sortedSourceKeys(r.keyRequests)
+	for _, key := range sourceKeys {
+		fmt.Fprintf(cw, "subfinder_key_requests_total{source=%s,key=%s} %d\n", quote(key.source), quote(key.key), r.keyRequests[key])
+	}
+
+	writeHeader(cw, "subfinder_key_failures_total", "counter", "Failed HTTP requests sent by the sources with each API key.")
+	for _, key := range sourceKeys {
+		fmt.Fprintf(cw, "subfinder_key_failures_total{source=%s,key=%s} %d\n", quote(key.source), quote(key.key), r.keyFailures[key])
+	}
+
+	writeHeader(cw, "subfinder_key_status", "gauge", "Status of each API key of the sources, 1 for its current status.")
+	for _, key := range sourceKeys {
+		fmt.Fprintf(cw, "subfinder_key_status{source=%s,key=%s,status=%s} 1\n", quote(key.source), quote(key.key), quote(r.keyStatus[key]))
+	}
+
+	if cw.err != nil {
+		return cw.n, cw.err
+	}
+	return cw.n, cw.w.Flush()
+}
+
+// Handler serves the metrics in the Prometheus text exposition format
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_, _ = r.WriteTo(w)
+	})
+}
+
+// WriteFile writes the metrics to a file read by the textfile collector of
+// the node exporter, replacing it at once so that it is never read partially
+func (r *Registry) WriteFile(path string) error {
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(file.Name()) }()
+
+	if _, err := r.WriteTo(file); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(file.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+// statusClass returns the class of an HTTP status code, e.g. 2xx
+func statusClass(statusCode int) string {
+	if statusCode < 100 || statusCode > 599 {
+		return "error"
+	}
+	return strconv.Itoa(statusCode/100) + "xx"
+}
+
+func writeHeader(w io.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedSourceKeys[V any](m map[sourceKey]V) []sourceKey {
+	keys := make([]sourceKey, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].source != keys[j].source {
+			return keys[i].source < keys[j].source
+		}
+		return keys[i].key < keys[j].key
+	})
+	return keys
+}
+
+var labelReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// quote returns a label value escaped and quoted
+func quote(value string) string {
+	return `"` + labelReplacer.Replace(value) + `"`
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// countingWriter counts the bytes written and keeps the first error
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
@@ -0,0 +1,87 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistryWriteTo(t *testing.T) {
+	registry := New()
+	registry.ObserveRequest("crtsh", 200, 300*time.Millisecond)
+	registry.ObserveRequest("crtsh", 503, 2*time.Second)
+	registry.ObserveRequest("crtsh", 0, time.Minute+time.Second)
+	registry.ObserveRateLimitWait("crtsh", 1500*time.Millisecond)
+	registry.AddResult("crtsh")
+	registry.AddResult("crtsh")
+	registry.AddError("crtsh")
+	registry.ObserveResolution(Resolved)
+	registry.ObserveResolution(Unresolved)
+	registry.ObserveResolution(Resolved)
+
+	var buf bytes.Buffer
+	n, err := registry.WriteTo(&buf)
+	require.NoError(t, err)
+	require.Equal(t, int64(buf.Len()), n)
+
+	output := buf.String()
+	require.Contains(t, output, "# TYPE subfinder_source_requests_total counter\n")
+	require.Contains(t, output, `subfinder_source_requests_total{source="crtsh",status="2xx"} 1`+"\n")
+	require.Contains(t, output, `subfinder_source_requests_total{source="crtsh",status="5xx"} 1`+"\n")
+	require.Contains(t, output, `subfinder_source_requests_total{source="crtsh",status="error"} 1`+"\n")
+	require.Contains(t, output, `subfinder_source_request_duration_seconds_bucket{source="crtsh",le="0.25"} 0`+"\n")
+	require.Contains(t, output, `subfinder_source_request_duration_seconds_bucket{source="crtsh",le="0.5"} 1`+"\n")
+	require.Contains(t, output, `subfinder_source_request_duration_seconds_bucket{source="crtsh",le="2.5"} 2`+"\n")
+	require.Contains(t, output, `subfinder_source_request_duration_seconds_bucket{source="crtsh",le="60"} 2`+"\n")
+	require.Contains(t, output, `subfinder_source_request_duration_seconds_bucket{source="crtsh",le="+Inf"} 3`+"\n")
+	require.Contains(t, output, `subfinder_source_request_duration_seconds_count{source="crtsh"} 3`+"\n")
+	require.Contains(t, output, `subfinder_source_results_total{source="crtsh"} 2`+"\n")
+	require.Contains(t, output, `subfinder_source_errors_total{source="crtsh"} 1`+"\n")
+	require.Contains(t, output, `subfinder_source_rate_limit_wait_seconds_total{source="crtsh"} 1.5`+"\n")
+	require.Contains(t, output, `subfinder_resolutions_total{outcome="resolved"} 2`+"\n")
+	require.Contains(t, output, `subfinder_resolutions_total{outcome="unresolved"} 1`+"\n")
+}
+
+func TestRegistryHandlerKeys(t *testing.T) {
+	registry := New()
+	registry.ObserveKeyRequest("github", "ghp_****#1", false, "healthy")
+	registry.ObserveKeyRequest("github", "ghp_****#2", false, "healthy")
+	registry.ObserveKeyRequest("github", "ghp_****#2", true, "exhausted")
+
+	recorder := httptest.NewRecorder()
+	registry.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	output := recorder.Body.String()
+	require.Contains(t, output, "# TYPE subfinder_key_requests_total counter\n")
+	require.Contains(t, output, `subfinder_key_requests_total{source="github",key="ghp_****#1"} 1`+"\n")
+	require.Contains(t, output, `subfinder_key_requests_total{source="github",key="ghp_****#2"} 2`+"\n")
+	require.Contains(t, output, `subfinder_key_failures_total{source="github",key="ghp_****#1"} 0`+"\n")
+	require.Contains(t, output, `subfinder_key_failures_total{source="github",key="ghp_****#2"} 1`+"\n")
+	require.Contains(t, output, "# TYPE subfinder_key_status gauge\n")
+	require.Contains(t, output, `subfinder_key_status{source="github",key="ghp_****#1",status="healthy"} 1`+"\n")
+	require.Contains(t, output, `subfinder_key_status{source="github",key="ghp_****#2",status="exhausted"} 1`+"\n")
+	require.NotContains(t, output, `subfinder_key_status{source="github",key="ghp_****#2",status="healthy"}`, "only the current status of a key is exposed")
+}
+
+func TestRegistryWriteFile(t *testing.T) {
+	registry := New()
+	registry.AddResult(`quo"ted`)
+
+	path := filepath.Join(t.TempDir(), "subfinder.prom")
+	require.NoError(t, registry.WriteFile(path))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Contains(t, string(data), `subfinder_source_results_total{source="quo\"ted"} 1`+"\n", "label values are escaped")
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "the temporary file is renamed")
+}
@@ -4,17 +4,23 @@ import (
 	"context"
 	"fmt"
 	"math"
-	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/projectdiscovery/ratelimit"
+	"github.com/projectdiscovery/subfinder/v2/pkg/metrics"
 	"github.com/projectdiscovery/subfinder/v2/pkg/subscraping"
 )
 
 type EnumerationOptions struct {
 	customRateLimiter *subscraping.CustomRateLimit
+	multiRateLimiter  *ratelimit.MultiLimiter
+	responseCache     *subscraping.ResponseCache
+	statistics        *SourceStatistics
+	retrier           *subscraping.Retrier
+	adaptiveLimiter   *subscraping.AdaptiveLimiter
+	keyManager        *subscraping.KeyManager
 }
 
 type EnumerateOption func(opts *EnumerationOptions)
@@ -25,6 +31,55 @@ func WithCustomRateLimit(crl *subscraping.CustomRateLimit) EnumerateOption {
 	}
 }
 
+// WithMultiRateLimiter shares an existing rate limiter across enumerations instead of
+// building a new one for every domain. The caller is responsible for stopping it.
+func WithMultiRateLimiter(mrl *ratelimit.MultiLimiter) EnumerateOption {
+	return func(opts *EnumerationOptions) {
+		opts.multiRateLimiter = mrl
+	}
+}
+
+// WithResponseCache caches the responses received by the sources on disk
+func WithResponseCache(cache *subscraping.ResponseCache) EnumerateOption {
+	return func(opts *EnumerationOptions) {
+		opts.responseCache = cache
+	}
+}
+
+// WithStatistics accumulates the statistics of the sources run by the enumeration,
+// in addition to the statistics of the agent
+func WithStatistics(statistics *SourceStatistics) EnumerateOption {
+	return func(opts *EnumerationOptions) {
+		opts.statistics = statistics
+	}
+}
+
+// WithRetrier retries the failed requests of the sources with the given retrier,
+// which can be shared across enumerations for its circuit breakers to span them.
+// The default retry options are used otherwise.
+func WithRetrier(retrier *subscraping.Retrier) EnumerateOption {
+	return func(opts *EnumerationOptions) {
+		opts.retrier = retrier
+	}
+}
+
+// WithAdaptiveLimiter paces the requests of the sources at the rate limits learned
+// by the given adaptive limiter, which can be shared across enumerations
+func WithAdaptiveLimiter(limiter *subscraping.AdaptiveLimiter) EnumerateOption {
+	return func(opts *EnumerationOptions) {
+		opts.adaptiveLimiter = limiter
+	}
+}
+
+// WithKeyManager tracks the health of the API keys of the sources with the given
+// key manager, which can be shared across enumerations for the keys rejected by
+// the providers to stay quarantined. A key manager of the enumeration is used otherwise.
+func WithKeyManager(keyManager *subscraping.KeyManager) EnumerateOption {
+	return func(opts *EnumerationOptions) {
+		opts.keyManager = keyManager
+	}
+}
+
 // EnumerateSubdomains wraps EnumerateSubdomainsWithCtx with an empty context
 func (a *Agent) EnumerateSubdomains(domain string, proxy string, rateLimit int, timeout int, maxEnumTime time.Duration, options ...EnumerateOption) chan subscraping.Result {
 	return a.EnumerateSubdomainsWithCtx(context.Background(), domain, proxy, rateLimit, timeout, maxEnumTime, options...)
@@ -42,12 +97,16 @@ func (a *Agent) EnumerateSubdomainsWithCtx(ctx context.Context, domain string, p
 			enumerateOption(&enumerateOptions)
 		}
 
-		multiRateLimiter, err := a.buildMultiRateLimiter(ctx, rateLimit, enumerateOptions.customRateLimiter)
-		if err != nil {
-			results <- subscraping.Result{
-				Type: subscraping.Error, Error: fmt.Errorf("could not init multi rate limiter for %s: %s", domain, err),
+		multiRateLimiter := enumerateOptions.multiRateLimiter
+		if multiRateLimiter == nil {
+			var err error
+			multiRateLimiter, err = a.BuildMultiRateLimiter(ctx, rateLimit, enumerateOptions.customRateLimiter)
+			if err != nil {
+				results <- subscraping.Result{
+					Type: subscraping.Error, Error: fmt.Errorf("could not init multi rate limiter for %s: %s", domain, err),
+				}
+				return
 			}
-			return
 		}
 		session, err := subscraping.NewSession(domain, proxy, multiRateLimiter, timeout)
 		if err != nil {
@@ -56,7 +115,22 @@ func (a *Agent) EnumerateSubdomainsWithCtx(ctx context.Context, domain string, p
 			}
 			return
 		}
-		defer session.Close()
+		session.Cache = enumerateOptions.responseCache
+		session.Retrier = enumerateOptions.retrier
+		if session.Retrier == nil {
+			session.Retrier = subscraping.NewRetrier(subscraping.DefaultRetryOptions)
+		}
+		session.AdaptiveLimiter = enumerateOptions.adaptiveLimiter
+		session.Keys = enumerateOptions.keyManager
+		if session.Keys == nil {
+			session.Keys = subscraping.NewKeyManager(maxEnumTime)
+		}
+		if enumerateOptions.multiRateLimiter == nil {
+			defer session.Close()
+		} else {
+			// the shared rate limiter must outlive the session
+			defer session.Client.CloseIdleConnections()
+		}
 
 		ctx, cancel := context.WithTimeout(ctx, maxEnumTime)
 
@@ -66,15 +140,34 @@ func (a *Agent) EnumerateSubdomainsWithCtx(ctx context.Context, domain string, p
 			wg.Add(1)
 			go func(source subscraping.Source) {
 				defer wg.Done()
+				// The statistics are final once the source closed its results
+				defer func() {
+					stat := source.Statistics()
+					stat.Retries += session.Retries(source.Name())
+					a.statistics.add(source.Name(), stat)
+					if enumerateOptions.statistics != nil {
+						enumerateOptions.statistics.add(source.Name(), stat)
+					}
+				}()
 				ctxWithValue := context.WithValue(ctx, subscraping.CtxSourceArg, source.Name())
-				for resp := range source.Run(ctxWithValue, domain, session) {
+				sourceResults := source.Run(ctxWithValue, domain, session)
+				for resp := range sourceResults {
+					switch resp.Type {
+					case subscraping.Subdomain:
+						metrics.Default.AddResult(resp.Source)
+					case subscraping.Error:
+						metrics.Default.AddError(resp.Source)
+					}
 					select {
 					case <-ctx.Done():
+						// Drain the source so that it is not blocked sending its remaining results
+						for range sourceResults {
+						}
 						return
 					case results <- resp:
 					}
 				}
-			}(runner)
+			}(a.newInstance(runner))
 		}
 		wg.Wait()
 		cancel()
@@ -82,7 +175,8 @@ func (a *Agent) EnumerateSubdomainsWithCtx(ctx context.Context, domain string, p
 	return results
 }
 
-func (a *Agent) buildMultiRateLimiter(ctx context.Context, globalRateLimit int, rateLimit *subscraping.CustomRateLimit) (*ratelimit.MultiLimiter, error) {
+// BuildMultiRateLimiter creates a rate limiter holding a bucket for each source of the agent
+func (a *Agent) BuildMultiRateLimiter(ctx context.Context, globalRateLimit int, rateLimit *subscraping.CustomRateLimit) (*ratelimit.MultiLimiter, error) {
 	var multiRateLimiter *ratelimit.MultiLimiter
 	var err error
 	for _, source := range a.sources {
@@ -130,14 +224,13 @@ func addRateLimiter(ctx context.Context, multiRateLimiter *ratelimit.MultiLimite
 	return multiRateLimiter, err
 }
 
+// GetStatistics returns the statistics of the sources of the agent accumulated over its enumerations
 func (a *Agent) GetStatistics() map[string]subscraping.Statistics {
-	stats := make(map[string]subscraping.Statistics)
-	sort.Slice(a.sources, func(i, j int) bool {
-		return a.sources[i].Name() > a.sources[j].Name()
-	})
-
+	stats := a.statistics.Get()
 	for _, source := range a.sources {
-		stats[source.Name()] = source.Statistics()
+		if _, ok := stats[source.Name()]; !ok {
+			stats[source.Name()] = subscraping.Statistics{}
+		}
 	}
 	return stats
 }
@@ -1,6 +1,7 @@
 package passive
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"strings"
@@ -60,80 +61,148 @@ import (
 	mapsutil "github.com/projectdiscovery/utils/maps"
 )
 
-var AllSources = [...]subscraping.Source{
-	&alienvault.Source{},
-	&anubis.Source{},
-	&bevigil.Source{},
-	&bufferover.Source{},
-	&c99.Source{},
-	&censys.Source{},
-	&certspotter.Source{},
-	&chaos.Source{},
-	&chinaz.Source{},
-	&commoncrawl.Source{},
-	&crtsh.Source{},
-	&digitorus.Source{},
-	&dnsdb.Source{},
-	&dnsdumpster.Source{},
-	&domainsproject.Source{},
-	&dnsrepo.Source{},
-	&driftnet.Source{},
-	&fofa.Source{},
-	&fullhunt.Source{},
-	&github.Source{},
-	&hackertarget.Source{},
-	&intelx.Source{},
-	&netlas.Source{},
-	&merklemap.Source{},
-	&onyphe.Source{},
-	&leakix.Source{},
-	&quake.Source{},
-	&pugrecon.Source{},
-	&rapiddns.Source{},
-	&redhuntlabs.Source{},
-	// &riddler.Source{}, // failing due to cloudfront protection
-	&robtex.Source{},
-	&rsecloud.Source{},
-	&securitytrails.Source{},
-	&profundis.Source{},
-	&shodan.Source{},
-	&sitedossier.Source{},
-	&threatbook.Source{},
-	&threatcrowd.Source{},
-	&virustotal.Source{},
-	&waybackarchive.Source{},
-	&whoisxmlapi.Source{},
-	&windvane.Source{},
-	&zoomeyeapi.Source{},
-	&facebook.Source{},
-	// &threatminer.Source{}, // failing  api
-	// &reconcloud.Source{}, // failing due to cloudflare bot protection
-	&builtwith.Source{},
-	&hudsonrock.Source{},
-	&digitalyama.Source{},
-	&thc.Source{},
+// sourceFactories create the built-in sources
+var sourceFactories = []SourceFactory{
+	newSource[alienvault.Source],
+	newSource[anubis.Source],
+	newSource[bevigil.Source],
+	newSource[bufferover.Source],
+	newSource[c99.Source],
+	newSource[censys.Source],
+	newSource[certspotter.Source],
+	newSource[chaos.Source],
+	newSource[chinaz.Source],
+	newSource[commoncrawl.Source],
+	newSource[crtsh.Source],
+	newSource[digitorus.Source],
+	newSource[dnsdb.Source],
+	newSource[dnsdumpster.Source],
+	newSource[domainsproject.Source],
+	newSource[dnsrepo.Source],
+	newSource[driftnet.Source],
+	newSource[fofa.Source],
+	newSource[fullhunt.Source],
+	newSource[github.Source],
+	newSource[hackertarget.Source],
+	newSource[intelx.Source],
+	newSource[netlas.Source],
+	newSource[merklemap.Source],
+	newSource[onyphe.Source],
+	newSource[leakix.Source],
+	newSource[quake.Source],
+	newSource[pugrecon.Source],
+	newSource[rapiddns.Source],
+	newSource[redhuntlabs.Source],
+	// newSource[riddler.Source], // failing due to cloudfront protection
+	newSource[robtex.Source],
+	newSource[rsecloud.Source],
+	newSource[securitytrails.Source],
+	newSource[profundis.Source],
+	newSource[shodan.Source],
+	newSource[sitedossier.Source],
+	newSource[threatbook.Source],
+	newSource[threatcrowd.Source],
+	newSource[virustotal.Source],
+	newSource[waybackarchive.Source],
+	newSource[whoisxmlapi.Source],
+	newSource[windvane.Source],
+	newSource[zoomeyeapi.Source],
+	newSource[facebook.Source],
+	// newSource[threatminer.Source], // failing  api
+	// newSource[reconcloud.Source], // failing due to cloudflare bot protection
+	newSource[builtwith.Source],
+	newSource[hudsonrock.Source],
+	newSource[digitalyama.Source],
+	newSource[thc.Source],
 }
 
+// AllSources describe the available sources. The agents do not run them
+// but new instances of the sources created for every enumeration.
+var AllSources = make([]subscraping.Source, 0, len(sourceFactories))
+
 var sourceWarnings = mapsutil.NewSyncLockMap[string, string](
 	mapsutil.WithMap(mapsutil.Map[string, string]{}))
 
-var NameSourceMap = make(map[string]subscraping.Source, len(AllSources))
+// NameSourceMap maps the lower case names of the sources to their description
+var NameSourceMap = make(map[string]subscraping.Source, len(sourceFactories))
+
+// nameFactoryMap maps the lower case names of the sources to their factory
+var nameFactoryMap = make(map[string]SourceFactory, len(sourceFactories))
 
 func init() {
-	for _, currentSource := range AllSources {
-		NameSourceMap[strings.ToLower(currentSource.Name())] = currentSource
+	for _, factory := range sourceFactories {
+		if err := Register(factory); err != nil {
+			panic(err)
+		}
 	}
 }
 
+// SourceFactory creates a new instance of a source
+type SourceFactory func() subscraping.Source
+
+// Register adds a source to the available sources, the factory creating a new
+// instance of it for every enumeration. The registered sources are selected by
+// name, loaded with their keys from the provider config, rate limited and
+// reported in the statistics just like the built-in ones. Register must be
+// called before the agents are created, e.g. from an init function.
+func Register(factory SourceFactory) error {
+	source := factory()
+	if source == nil {
+		return errors.New("source factory returned no source")
+	}
+	name := strings.ToLower(source.Name())
+	if name == "" {
+		return errors.New("source has no name")
+	}
+	if _, ok := NameSourceMap[name]; ok {
+		return fmt.Errorf("source %s is already registered", name)
+	}
+
+	AllSources = append(AllSources, source)
+	NameSourceMap[name] = source
+	nameFactoryMap[name] = factory
+	return nil
+}
+
+// newSource creates a zero value instance of a source type
+func newSource[T any, PT interface {
+	*T
+	subscraping.Source
+}]() subscraping.Source {
+	return PT(new(T))
+}
+
 // Agent is a struct for running passive subdomain enumeration
 // against a given host. It wraps subscraping package and provides
 // a layer to build upon.
 type Agent struct {
-	sources []subscraping.Source
+	sources []agentSource
+	// keys are the API keys of the sources by lower case name
+	keys map[string][]string
+	// statistics accumulates the statistics of every enumeration of the agent
+	statistics *SourceStatistics
+}
+
+// agentSource is a source of an agent, the embedded instance describing
+// it while new instances are created by the factory to be run
+type agentSource struct {
+	subscraping.Source
+	factory SourceFactory
+}
+
+// AgentOption configures an agent
+type AgentOption func(agent *Agent)
+
+// WithAPIKeys sets the API keys of the sources by lower case name,
+// the keys found in the environment taking precedence
+func WithAPIKeys(keys map[string][]string) AgentOption {
+	return func(agent *Agent) {
+		maps.Copy(agent.keys, keys)
+	}
 }
 
 // New creates a new agent for passive subdomain discovery
-func New(sourceNames, excludedSourceNames []string, useAllSources, useSourcesSupportingRecurse bool) *Agent {
+func New(sourceNames, excludedSourceNames []string, useAllSources, useSourcesSupportingRecurse bool, options ...AgentOption) *Agent {
 	sources := make(map[string]subscraping.Source, len(AllSources))
 
 	if useAllSources {
@@ -150,7 +219,7 @@ func New(sourceNames, excludedSourceNames []string, useAllSources, useSourcesSup
 		} else {
 			for _, currentSource := range AllSources {
 				if currentSource.IsDefault() {
-					sources[currentSource.Name()] = currentSource
+					sources[strings.ToLower(currentSource.Name())] = currentSource
 				}
 			}
 		}
@@ -182,17 +251,56 @@ func New(sourceNames, excludedSourceNames []string, useAllSources, useSourcesSup
 		}
 	}
 
+	factories := make([]SourceFactory, 0, len(sources))
+	for name := range sources {
+		factories = append(factories, nameFactoryMap[strings.ToLower(name)])
+	}
+	return NewAgent(factories, options...)
+}
+
+// NewAgent creates an agent running the sources created by the factories. Every
+// enumeration runs new instances of the sources so that agents, and enumerations
+// of the same agent, can run concurrently.
+func NewAgent(factories []SourceFactory, options ...AgentOption) *Agent {
+	agent := &Agent{keys: make(map[string][]string), statistics: &SourceStatistics{}}
+	for _, factory := range factories {
+		agent.sources = append(agent.sources, agentSource{Source: factory(), factory: factory})
+	}
+	for _, option := range options {
+		option(agent)
+	}
+
 	// TODO: Consider refactoring this to avoid potential duplication issues
-	for _, source := range sources {
+	for _, source := range agent.sources {
 		if source.NeedsKey() {
 			if apiKey := os.Getenv(fmt.Sprintf("%s_API_KEY", strings.ToUpper(source.Name()))); apiKey != "" {
-				source.AddApiKeys([]string{apiKey})
+				agent.keys[strings.ToLower(source.Name())] = []string{apiKey}
 			}
 		}
 	}
+	return agent
+}
 
-	// Create the agent, insert the sources and remove the excluded sources
-	agent := &Agent{sources: maps.Values(sources)}
+// newInstance creates a new instance of a source of the agent along with its keys
+func (a *Agent) newInstance(source agentSource) subscraping.Source {
+	instance := source.factory()
+	if keys := a.keys[strings.ToLower(source.Name())]; source.NeedsKey() && len(keys) > 0 {
+		instance.AddApiKeys(keys)
+	}
+	return instance
+}
 
-	return agent
+// RecursiveAgent returns an agent restricted to the sources of the agent which accept
+// subdomains as input, or nil if none of them does. Its statistics are the ones of the agent.
+func (a *Agent) RecursiveAgent() *Agent {
+	var sources []agentSource
+	for _, source := range a.sources {
+		if source.HasRecursiveSupport() {
+			sources = append(sources, source)
+		}
+	}
+	if len(sources) == 0 {
+		return nil
+	}
+	return &Agent{sources: sources, keys: a.keys, statistics: a.statistics}
 }
@@ -0,0 +1,116 @@
+package passive
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/projectdiscovery/subfinder/v2/pkg/subscraping"
+	"github.com/projectdiscovery/subfinder/v2/pkg/subscraping/replay"
+)
+
+// record refreshes the fixtures against the live providers:
+//
+//	go test ./pkg/passive -run TestSourcesReplay/alienvault -record
+//
+// The keys are read from the <SOURCE>_API_KEY environment variables and replaced
+// by placeholders in the fixtures. Adding a source only requires a fixture file
+// holding its name and a domain before recording it.
+var record = flag.Bool("record", false, "record the fixtures of the sources against the live providers")
+
+func TestSourcesReplay(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "replay", "*.json"))
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+
+	for _, file := range files {
+		fixture, err := replay.LoadFixture(file)
+		require.NoError(t, err)
+
+		t.Run(fixture.Source, func(t *testing.T) {
+			factory, ok := nameFactoryMap[fixture.Source]
+			require.True(t, ok, "unknown source %s", fixture.Source)
+			source := factory()
+
+			multiRateLimiter, err := addRateLimiter(context.Background(), nil, source.Name(), math.MaxInt32, time.Millisecond)
+			require.NoError(t, err)
+			session, err := subscraping.NewSession(fixture.Domain, "", multiRateLimiter, 30)
+			require.NoError(t, err)
+			defer session.Close()
+
+			var replayer *replay.Replayer
+			var recorder *replay.Recorder
+			if *record {
+				keys, placeholders, secrets := recordingKeys(source)
+				if source.NeedsKey() && len(keys) == 0 {
+					t.Skipf("no API key is provided for %s", source.Name())
+				}
+				fixture.Keys = placeholders
+				recorder = replay.NewRecorder(session.Client.Transport, secrets)
+				session.Client.Transport = recorder
+				source.AddApiKeys(keys)
+			} else {
+				replayer = replay.NewReplayer(fixture)
+				session.Client.Transport = replayer
+				source.AddApiKeys(fixture.Keys)
+			}
+
+			var results []string
+			var errs []error
+			ctx := context.WithValue(context.Background(), subscraping.CtxSourceArg, source.Name())
+			for result := range source.Run(ctx, fixture.Domain, session) {
+				require.Equal(t, source.Name(), result.Source, "wrong source name")
+				switch result.Type {
+				case subscraping.Subdomain:
+					results = append(results, strings.ToLower(result.Value))
+				case subscraping.Error:
+					errs = append(errs, result.Error)
+				}
+			}
+			slices.Sort(results)
+			results = slices.Compact(results)
+
+			if *record {
+				require.Empty(t, errs)
+				fixture.Interactions = recorder.Interactions()
+				fixture.Results = results
+				require.NoError(t, fixture.Save(file))
+				return
+			}
+			require.Empty(t, errs)
+			require.Equal(t, fixture.Results, results)
+			require.Empty(t, replayer.Unused(), "all the recorded requests are sent")
+		})
+	}
+}
+
+// recordingKeys returns the key of the source read from the environment and its
+// placeholder, along with the placeholder of every part of the key. A single
+// key is used so that the replayed requests are those recorded.
+func recordingKeys(source subscraping.Source) ([]string, []string, map[string]string) {
+	key := os.Getenv(strings.ToUpper(source.Name()) + "_API_KEY")
+	if key == "" {
+		return nil, nil, nil
+	}
+	secrets := make(map[string]string)
+	parts := strings.Split(key, ":")
+	placeholders := make([]string, 0, len(parts))
+	for i, part := range parts {
+		placeholder := "key"
+		if len(parts) > 1 {
+			placeholder = fmt.Sprintf("key-part%d", i+1)
+		}
+		secrets[part] = placeholder
+		placeholders = append(placeholders, placeholder)
+	}
+	return []string{key}, []string{strings.Join(placeholders, ":")}, secrets
+}
@@ -1,12 +1,16 @@
 package passive
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"golang.org/x/exp/maps"
+
+	"github.com/projectdiscovery/subfinder/v2/pkg/subscraping"
 )
 
 var (
@@ -189,3 +193,61 @@ func TestSourceFiltering(t *testing.T) {
 		})
 	}
 }
+
+func TestAgentSourceInstances(t *testing.T) {
+	first := New([]string{"chaos"}, nil, false, false, WithAPIKeys(map[string][]string{"chaos": {"first"}}))
+	second := New([]string{"chaos"}, nil, false, false, WithAPIKeys(map[string][]string{"chaos": {"second"}}))
+
+	firstInstance := first.newInstance(first.sources[0])
+	secondInstance := second.newInstance(second.sources[0])
+	assert.NotSame(t, firstInstance, secondInstance, "every enumeration runs its own instance")
+	assert.NotSame(t, NameSourceMap["chaos"], firstInstance, "the described sources are not run")
+	assert.Equal(t, []string{"first"}, first.keys["chaos"])
+	assert.Equal(t, []string{"second"}, second.keys["chaos"], "the keys are not shared across agents")
+}
+
+// customSource is a source registered by the tests
+type customSource struct{ name string }
+
+func (s *customSource) Run(_ context.Context, _ string, _ *subscraping.Session) <-chan subscraping.Result {
+	results := make(chan subscraping.Result)
+	close(results)
+	return results
+}
+func (s *customSource) Name() string                       { return s.name }
+func (s *customSource) IsDefault() bool                    { return true }
+func (s *customSource) HasRecursiveSupport() bool          { return false }
+func (s *customSource) NeedsKey() bool                     { return false }
+func (s *customSource) AddApiKeys(_ []string)              {}
+func (s *customSource) Statistics() subscraping.Statistics { return subscraping.Statistics{} }
+
+func TestRegister(t *testing.T) {
+	factory := func() subscraping.Source { return &customSource{name: "Custom"} }
+	require.NoError(t, Register(factory))
+	t.Cleanup(func() {
+		AllSources = AllSources[:len(AllSources)-1]
+		delete(NameSourceMap, "custom")
+		delete(nameFactoryMap, "custom")
+	})
+
+	require.EqualError(t, Register(factory), "source custom is already registered")
+	require.EqualError(t, Register(func() subscraping.Source { return &customSource{} }), "source has no name")
+
+	sourceNames := func(agent *Agent) []string {
+		var names []string
+		for _, source := range agent.sources {
+			names = append(names, source.Name())
+		}
+		return names
+	}
+	require.Equal(t, []string{"Custom"}, sourceNames(New([]string{"custom"}, nil, false, false)))
+	require.Contains(t, sourceNames(New(nil, nil, false, false)), "Custom", "default sources are used without -s")
+	require.NotContains(t, sourceNames(New(nil, []string{"custom"}, false, false)), "Custom", "default sources are excluded by lower case name")
+	require.Contains(t, sourceNames(New(nil, nil, true, false)), "Custom")
+	require.NotContains(t, sourceNames(New(nil, []string{"custom"}, true, false)), "Custom")
+
+	multiRateLimiter, err := New([]string{"custom"}, nil, false, false).BuildMultiRateLimiter(context.Background(), 0, &subscraping.CustomRateLimit{})
+	require.NoError(t, err)
+	defer multiRateLimiter.Stop()
+	require.NoError(t, multiRateLimiter.Take("Custom"), "registered sources are rate limited")
+}
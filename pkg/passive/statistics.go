@@ -0,0 +1,50 @@
+package passive
+
+import (
+	"maps"
+	"sync"
+
+	"github.com/projectdiscovery/subfinder/v2/pkg/subscraping"
+)
+
+// SourceStatistics accumulates the statistics of the sources over enumerations.
+// The zero value is ready to use.
+type SourceStatistics struct {
+	mu      sync.Mutex
+	sources map[string]subscraping.Statistics
+}
+
+// add merges the statistics of a run of a source, which is
+// reported as skipped only when all of its runs were
+func (s *SourceStatistics) add(source string, stat subscraping.Statistics) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.sources == nil {
+		s.sources = make(map[string]subscraping.Statistics)
+	}
+	current, ok := s.sources[source]
+	if !ok {
+		s.sources[source] = stat
+		return
+	}
+	current.TimeTaken += stat.TimeTaken
+	current.Errors += stat.Errors
+	current.Results += stat.Results
+	current.Skipped = current.Skipped && stat.Skipped
+	current.CacheHits += stat.CacheHits
+	current.CacheMisses += stat.CacheMisses
+	current.Retries += stat.Retries
+	s.sources[source] = current
+}
+
+// Get returns the statistics of the sources which were run
+func (s *SourceStatistics) Get() map[string]subscraping.Statistics {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.sources == nil {
+		return make(map[string]subscraping.Statistics)
+	}
+	return maps.Clone(s.sources)
+}
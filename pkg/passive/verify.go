@@ -0,0 +1,115 @@
+package passive
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/projectdiscovery/subfinder/v2/pkg/subscraping"
+)
+
+// verificationDomain is the domain the sources without a dedicated verification
+// are run for to check the format of their keys, none of their requests being sent
+const verificationDomain = "example.com"
+
+// errNoVerification is the error of the keys of the sources without an account
+// endpoint, which are left unverified rather than spending a search of their quota
+var errNoVerification = errors.New("the source has no endpoint to verify the key without spending a search")
+
+// KeyVerification is the verification of an API key of a source
+type KeyVerification struct {
+	Source string `json:"source"`
+	// Key is the masked key
+	Key    string                `json:"key"`
+	Status subscraping.KeyStatus `json:"status"`
+	// Remaining is the remaining quota of the key when the provider reports it
+	Remaining *int   `json:"quota_remaining,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// VerifyKeys verifies every API key of the sources of the agent which need one
+// with the dedicated call of the sources implementing subscraping.KeyVerifier,
+// the keys of the others being unverified unless they are malformed. The sources
+// are verified in parallel and their keys one after the other, in the order of
+// the sources.
+func (a *Agent) VerifyKeys(ctx context.Context, proxy string, timeout int) []KeyVerification {
+	var sources []agentSource
+	for _, source := range a.sources {
+		if source.NeedsKey() && len(a.keys[strings.ToLower(source.Name())]) > 0 {
+			sources = append(sources, source)
+		}
+	}
+	slices.SortFunc(sources, func(a, b agentSource) int { return strings.Compare(a.Name(), b.Name()) })
+
+	verifications := make([][]KeyVerification, len(sources))
+	wg := &sync.WaitGroup{}
+	for i, source := range sources {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, key := range a.keys[strings.ToLower(source.Name())] {
+				verifications[i] = append(verifications[i], verifyKey(ctx, source, key, proxy, timeout))
+			}
+		}()
+	}
+	wg.Wait()
+	return slices.Concat(verifications...)
+}
+
+// verifyKey verifies a key with a new instance of the source holding only this key
+func verifyKey(ctx context.Context, source agentSource, key string, proxy string, timeout int) KeyVerification {
+	verification := KeyVerification{Source: source.Name(), Key: subscraping.MaskKey(key)}
+	check := checkKey(ctx, source, key, proxy, timeout)
+	verification.Status = check.Status
+	verification.Remaining = check.Remaining
+	if check.Error != nil {
+		// the errors of the requests hold their URL, which can hold the key
+		replacements := []string{key, verification.Key}
+		if keyPartA, keyPartB, ok := strings.Cut(key, ":"); ok {
+			replacements = append(replacements, keyPartA, subscraping.MaskKey(keyPartA), keyPartB, subscraping.MaskKey(keyPartB))
+		}
+		verification.Error = strings.NewReplacer(replacements...).Replace(check.Error.Error())
+	}
+	return verification
+}
+
+func checkKey(ctx context.Context, source agentSource, key string, proxy string, timeout int) subscraping.KeyCheck {
+	instance := source.factory()
+	instance.AddApiKeys([]string{key})
+
+	multiRateLimiter, err := addRateLimiter(ctx, nil, source.Name(), math.MaxUint32, time.Second)
+	if err != nil {
+		return subscraping.KeyCheck{Status: subscraping.KeyUnverified, Error: err}
+	}
+	session, err := subscraping.NewSession(verificationDomain, proxy, multiRateLimiter, timeout)
+	if err != nil {
+		return subscraping.KeyCheck{Status: subscraping.KeyUnverified, Error: err}
+	}
+	defer session.Close()
+
+	ctx = context.WithValue(ctx, subscraping.CtxSourceArg, source.Name())
+	if verifier, ok := instance.(subscraping.KeyVerifier); ok {
+		return verifier.VerifyKey(ctx, key, session)
+	}
+
+	// the source is run without sending its requests to find out whether it skips the key
+	session.Client.Transport = noRequests{}
+	for range instance.Run(ctx, verificationDomain, session) {
+	}
+	if instance.Statistics().Skipped {
+		return subscraping.KeyCheck{Status: subscraping.KeyInvalid, Error: subscraping.ErrKeyFormat}
+	}
+	return subscraping.KeyCheck{Status: subscraping.KeyUnverified, Error: errNoVerification}
+}
+
+// noRequests is a transport failing every request without sending it
+type noRequests struct{}
+
+func (noRequests) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, errNoVerification
+}
@@ -0,0 +1,103 @@
+package passive
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/projectdiscovery/subfinder/v2/pkg/subscraping"
+)
+
+// keyedSource sends paginated requests with its key to the URL
+type keyedSource struct {
+	url     string
+	keys    []string
+	skipped bool
+}
+
+func (s *keyedSource) Run(ctx context.Context, domain string, session *subscraping.Session) <-chan subscraping.Result {
+	results := make(chan subscraping.Result)
+	go func() {
+		defer close(results)
+		if len(s.keys) == 0 || s.keys[0] == "malformed" {
+			s.skipped = true
+			return
+		}
+		for range 3 {
+			resp, err := session.Get(ctx, s.url, "", map[string]string{"X-Api-Key": s.keys[0]})
+			session.DiscardHTTPResponse(resp)
+			if err != nil {
+				results <- subscraping.Result{Source: s.Name(), Type: subscraping.Error, Error: err}
+				return
+			}
+		}
+	}()
+	return results
+}
+
+func (s *keyedSource) Name() string              { return "keyed" }
+func (s *keyedSource) IsDefault() bool           { return true }
+func (s *keyedSource) HasRecursiveSupport() bool { return false }
+func (s *keyedSource) NeedsKey() bool            { return true }
+func (s *keyedSource) AddApiKeys(keys []string)  { s.keys = keys }
+func (s *keyedSource) Statistics() subscraping.Statistics {
+	return subscraping.Statistics{Skipped: s.skipped}
+}
+
+// verifiedSource verifies its keys against the account endpoint of the URL
+type verifiedSource struct {
+	keyedSource
+}
+
+func (s *verifiedSource) Name() string { return "verified" }
+
+func (s *verifiedSource) VerifyKey(ctx context.Context, key string, session *subscraping.Session) subscraping.KeyCheck {
+	resp, err := session.Get(ctx, s.url+"/account", "", map[string]string{"X-Api-Key": key})
+	defer session.DiscardHTTPResponse(resp)
+	return subscraping.CheckKeyResponse(resp, err)
+}
+
+func TestVerifyKeys(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		require.Equal(t, "/account", r.URL.Path, "only the account endpoint is requested")
+		switch r.Header.Get("X-Api-Key") {
+		case "valid-key-1":
+			w.Header().Set("X-RateLimit-Remaining", "42")
+		case "expired-key":
+			w.WriteHeader(http.StatusPaymentRequired)
+		case "exhausted-key":
+			w.WriteHeader(http.StatusTooManyRequests)
+		case "rejected-key":
+			_, _ = w.Write([]byte(`{"error": true, "errmsg": "Invalid API key"}`))
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	agent := NewAgent([]SourceFactory{
+		func() subscraping.Source { return &verifiedSource{keyedSource{url: server.URL}} },
+		func() subscraping.Source { return &keyedSource{url: server.URL} },
+	}, WithAPIKeys(map[string][]string{
+		"keyed":    {"unverified-key", "malformed"},
+		"verified": {"valid-key-1", "revoked-key", "expired-key", "exhausted-key", "rejected-key"},
+	}))
+
+	remaining := 42
+	require.Equal(t, []KeyVerification{
+		{Source: "keyed", Key: "unve****", Status: subscraping.KeyUnverified, Error: "the source has no endpoint to verify the key without spending a search"},
+		{Source: "keyed", Key: "malf****", Status: subscraping.KeyInvalid, Error: "the key is not in the format expected by the source"},
+		{Source: "verified", Key: "vali****", Status: subscraping.KeyValid, Remaining: &remaining},
+		{Source: "verified", Key: "revo****", Status: subscraping.KeyInvalid, Error: "unexpected status code 401 received from " + server.URL + "/account"},
+		{Source: "verified", Key: "expi****", Status: subscraping.KeyExpired, Error: "unexpected status code 402 received from " + server.URL + "/account"},
+		{Source: "verified", Key: "exha****", Status: subscraping.KeyExhausted, Error: "unexpected status code 429 received from " + server.URL + "/account"},
+		{Source: "verified", Key: "reje****", Status: subscraping.KeyInvalid, Error: "Invalid API key"},
+	}, agent.VerifyKeys(context.Background(), "", 5))
+	require.Equal(t, int32(5), requests.Load(), "a single account request is sent per verified key and none for the others")
+}
@@ -0,0 +1,3 @@
+// Package permutation generates candidate subdomains from wordlists
+// and from the patterns of the subdomains already known for a domain.
+package permutation
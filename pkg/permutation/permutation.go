@@ -0,0 +1,125 @@
+package permutation
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DefaultWords are the words used to alter known subdomains
+var DefaultWords = []string{
+	"admin", "api", "beta", "dev", "internal", "new", "old", "prod", "qa", "stage", "staging", "test", "uat",
+}
+
+var numberRegex = regexp.MustCompile(`\d+`)
+
+// Bruteforce returns the candidates made of every word of the wordlist
+// followed by the domain
+func Bruteforce(domain string, words []string) []string {
+	candidates := newCandidateSet(len(words))
+	for _, word := range words {
+		word = strings.Trim(strings.ToLower(word), ".")
+		if word == "" {
+			continue
+		}
+		candidates.add(word + "." + domain)
+	}
+	return candidates.items
+}
+
+// Alterations returns the candidates derived from the known subdomains of the domain:
+//   - the words (and first labels learnt from the known subdomains) joined to
+//     the first label with a dash, e.g. dev-api.example.com and api-dev.example.com
+//   - the numbers of the first label incremented and decremented,
+//     e.g. api1.example.com and api3.example.com for api2.example.com, keeping
+//     the zero padding, e.g. ns00.example.com and ns02.example.com for ns01.example.com
+//   - the first label swapped with the first labels learnt from the other
+//     subdomains, e.g. mail.corp.example.com for vpn.corp.example.com
+func Alterations(domain string, known []string, words []string) []string {
+	knownSet := make(map[string]struct{}, len(known))
+	learnt := newCandidateSet(len(known))
+	for _, host := range known {
+		knownSet[host] = struct{}{}
+		if label, _, ok := splitFirstLabel(domain, host); ok {
+			learnt.add(label)
+		}
+	}
+
+	candidates := newCandidateSet(len(known))
+	for _, host := range known {
+		label, parent, ok := splitFirstLabel(domain, host)
+		if !ok {
+			continue
+		}
+
+		for _, word := range words {
+			if word != label {
+				candidates.add(word + "-" + label + "." + parent)
+				candidates.add(label + "-" + word + "." + parent)
+			}
+		}
+
+		for _, number := range numberRegex.FindAllStringIndex(label, -1) {
+			digits := label[number[0]:number[1]]
+			value, err := strconv.Atoi(digits)
+			if err != nil {
+				continue
+			}
+			// zero padded numbers keep their width
+			width := 0
+			if len(digits) > 1 && digits[0] == '0' {
+				width = len(digits)
+			}
+			for _, increment := range []int{value - 1, value + 1} {
+				if increment < 0 {
+					continue
+				}
+				candidates.add(label[:number[0]] + fmt.Sprintf("%0*d", width, increment) + label[number[1]:] + "." + parent)
+			}
+		}
+
+		// Only swap labels under intermediate subdomains, at the root
+		// level the learnt labels are already the known subdomains
+		if parent != domain {
+			for _, learntLabel := range learnt.items {
+				candidates.add(learntLabel + "." + parent)
+			}
+		}
+	}
+
+	alterations := candidates.items[:0]
+	for _, candidate := range candidates.items {
+		if _, ok := knownSet[candidate]; !ok {
+			alterations = append(alterations, candidate)
+		}
+	}
+	return alterations
+}
+
+// splitFirstLabel splits a subdomain into its first label and the rest of the name
+func splitFirstLabel(domain, host string) (label, parent string, ok bool) {
+	if !strings.HasSuffix(host, "."+domain) {
+		return "", "", false
+	}
+	label, parent, _ = strings.Cut(host, ".")
+	return label, parent, label != ""
+}
+
+// candidateSet is an insertion ordered set of names
+type candidateSet struct {
+	seen  map[string]struct{}
+	items []string
+}
+
+func newCandidateSet(size int) *candidateSet {
+	return &candidateSet{seen: make(map[string]struct{}, size)}
+}
+
+func (c *candidateSet) add(name string) {
+	if _, ok := c.seen[name]; ok {
+		return
+	}
+	c.seen[name] = struct{}{}
+	c.items = append(c.items, name)
+}
@@ -0,0 +1,31 @@
+package permutation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBruteforce(t *testing.T) {
+	candidates := Bruteforce("example.com", []string{"www", "API", "", "www", ".mail."})
+	require.Equal(t, []string{"www.example.com", "api.example.com", "mail.example.com"}, candidates)
+}
+
+func TestAlterations(t *testing.T) {
+	known := []string{"api2.example.com", "vpn.corp.example.com", "mail.corp.example.com", "ns01.example.com", "web10.example.com"}
+	candidates := Alterations("example.com", known, []string{"dev"})
+
+	require.Contains(t, candidates, "dev-api2.example.com")
+	require.Contains(t, candidates, "api2-dev.example.com")
+	require.Contains(t, candidates, "api1.example.com")
+	require.Contains(t, candidates, "api3.example.com")
+	require.Contains(t, candidates, "api2.corp.example.com")
+	require.Contains(t, candidates, "ns00.example.com", "zero padded numbers keep their width")
+	require.Contains(t, candidates, "ns02.example.com")
+	require.NotContains(t, candidates, "ns0.example.com")
+	require.Contains(t, candidates, "web9.example.com", "numbers without padding are not padded")
+	require.Contains(t, candidates, "web11.example.com")
+	for _, host := range known {
+		require.NotContains(t, candidates, host, "known subdomains should not be candidates")
+	}
+}
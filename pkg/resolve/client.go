@@ -1,7 +1,13 @@
 package resolve
 
 import (
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
 	"github.com/projectdiscovery/dnsx/libs/dnsx"
+
+	"github.com/projectdiscovery/subfinder/v2/pkg/takeover"
 )
 
 // DefaultResolvers contains the default list of resolvers known to be good
@@ -18,10 +24,25 @@ var DefaultResolvers = []string{
 	"208.67.220.220:53", // OpenDNS Secondary
 }
 
+// ExtraRecordTypes contains the record types that can be collected along with the addresses
+var ExtraRecordTypes = map[string]uint16{
+	"mx":  dns.TypeMX,
+	"txt": dns.TypeTXT,
+	"ns":  dns.TypeNS,
+}
+
 // Resolver is a struct for resolving DNS names
 type Resolver struct {
 	DNSClient *dnsx.DNSX
 	Resolvers []string
+	// CollectRecords enables the collection of all the A/AAAA records and of the CNAME
+	// chain of the hosts instead of the first address only
+	CollectRecords bool
+	// RecordTypes contains the extra record types (mx, txt, ns) to collect
+	RecordTypes []string
+	// Fingerprints contains the services checked for takeover candidates,
+	// it requires CollectRecords to be enabled
+	Fingerprints takeover.Fingerprints
 }
 
 // New creates a new resolver struct with the default resolvers
@@ -30,3 +51,17 @@ func New() *Resolver {
 		Resolvers: []string{},
 	}
 }
+
+// QuestionTypes returns the DNS question types needed to collect the
+// addresses and the given extra record types
+func QuestionTypes(recordTypes []string) ([]uint16, error) {
+	questionTypes := []uint16{dns.TypeA, dns.TypeAAAA}
+	for _, recordType := range recordTypes {
+		questionType, ok := ExtraRecordTypes[strings.ToLower(recordType)]
+		if !ok {
+			return nil, fmt.Errorf("unsupported record type %s", recordType)
+		}
+		questionTypes = append(questionTypes, questionType)
+	}
+	return questionTypes, nil
+}
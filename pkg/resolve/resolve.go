@@ -2,9 +2,12 @@ package resolve
 
 import (
 	"fmt"
+	"strings"
 	"sync"
 
 	"github.com/rs/xid"
+
+	"github.com/projectdiscovery/subfinder/v2/pkg/metrics"
 )
 
 const (
@@ -29,6 +32,12 @@ type HostEntry struct {
 	Host                string
 	Source              string
 	WildcardCertificate bool
+	// Parent is the subdomain whose recursive enumeration found the host
+	Parent string
+	// Depth is the recursion depth at which the host was found
+	Depth int
+	// Score is the confidence that the host exists, between 0 and 1
+	Score float64
 }
 
 // Result contains the result for a host resolution
@@ -39,6 +48,26 @@ type Result struct {
 	Error               error
 	Source              string
 	WildcardCertificate bool
+	Parent              string
+	Depth               int
+	DNSRecords
+	// TakeoverCandidate is set when the CNAME chain of the host ends on a
+	// known service and its target does not exist
+	TakeoverCandidate bool
+	TakeoverReason    string
+	Score             float64
+}
+
+// DNSRecords contains the records collected for a host when
+// the collection of records is enabled on the resolver
+type DNSRecords struct {
+	IPs   []string
+	CNAME []string
+	MX    []string
+	TXT   []string
+	NS    []string
+	// Status is the DNS response code of the host
+	Status string
 }
 
 // ResultType is the type of result found
@@ -78,14 +107,14 @@ func (r *ResolutionPool) InitWildcards(domain string) error {
 	for range maxWildcardChecks {
 		uid := xid.New().String()
 
-		hosts, _ := r.DNSClient.Lookup(uid + "." + domain)
-		if len(hosts) == 0 {
+		records, _ := r.lookup(uid + "." + domain)
+		if len(records.IPs) == 0 {
 			return fmt.Errorf("%s is not a wildcard domain", domain)
 		}
 
 		// Append all wildcard ips found for domains
-		for _, host := range hosts {
-			r.wildcardIPs[host] = struct{}{}
+		for _, ip := range records.IPs {
+			r.wildcardIPs[ip] = struct{}{}
 		}
 	}
 	return nil
@@ -94,32 +123,89 @@ func (r *ResolutionPool) InitWildcards(domain string) error {
 func (r *ResolutionPool) resolveWorker() {
 	for task := range r.Tasks {
 		if !r.removeWildcard {
-			r.Results <- Result{Type: Subdomain, Host: task.Host, IP: "", Source: task.Source, WildcardCertificate: task.WildcardCertificate}
+			r.Results <- Result{Type: Subdomain, Host: task.Host, IP: "", Source: task.Source, WildcardCertificate: task.WildcardCertificate, Parent: task.Parent, Depth: task.Depth}
 			continue
 		}
 
-		hosts, err := r.DNSClient.Lookup(task.Host)
+		records, err := r.lookup(task.Host)
 		if err != nil {
+			metrics.Default.ObserveResolution(metrics.Failed)
 			r.Results <- Result{Type: Error, Host: task.Host, Source: task.Source, Error: err, WildcardCertificate: task.WildcardCertificate}
 			continue
 		}
 
-		if len(hosts) == 0 {
+		// Dangling CNAMEs have no addresses, but are kept as takeover candidates
+		takeoverReason, takeoverCandidate := r.Fingerprints.Check(records.CNAME, records.Status)
+		if len(records.IPs) == 0 {
+			metrics.Default.ObserveResolution(metrics.Unresolved)
+			if takeoverCandidate {
+				r.Results <- Result{Type: Subdomain, Host: task.Host, Source: task.Source, WildcardCertificate: task.WildcardCertificate, Parent: task.Parent, Depth: task.Depth, DNSRecords: records, TakeoverCandidate: true, TakeoverReason: takeoverReason}
+			}
 			continue
 		}
 
 		var skip bool
-		for _, host := range hosts {
+		for _, ip := range records.IPs {
 			// Ignore the host if it exists in wildcard ips map
-			if _, ok := r.wildcardIPs[host]; ok {
+			if _, ok := r.wildcardIPs[ip]; ok {
 				skip = true
 				break
 			}
 		}
 
-		if !skip {
-			r.Results <- Result{Type: Subdomain, Host: task.Host, IP: hosts[0], Source: task.Source, WildcardCertificate: task.WildcardCertificate}
+		if skip {
+			metrics.Default.ObserveResolution(metrics.Wildcard)
+		} else {
+			metrics.Default.ObserveResolution(metrics.Resolved)
+			r.Results <- Result{Type: Subdomain, Host: task.Host, IP: records.IPs[0], Source: task.Source, WildcardCertificate: task.WildcardCertificate, Parent: task.Parent, Depth: task.Depth, DNSRecords: records, TakeoverCandidate: takeoverCandidate, TakeoverReason: takeoverReason}
 		}
 	}
 	r.wg.Done()
 }
+
+// lookup resolves the addresses of the host, collecting all its records
+// when enabled on the resolver
+func (r *ResolutionPool) lookup(host string) (DNSRecords, error) {
+	if !r.CollectRecords {
+		ips, err := r.DNSClient.Lookup(host)
+		return DNSRecords{IPs: ips}, err
+	}
+
+	data, err := r.DNSClient.QueryMultiple(host)
+	if err != nil || data == nil {
+		return DNSRecords{}, err
+	}
+
+	records := DNSRecords{
+		IPs:    dedupe(append(data.A, data.AAAA...)),
+		CNAME:  dedupe(data.CNAME),
+		Status: data.StatusCode,
+	}
+	// The authority section is parsed as well, so only keep what was asked for
+	for _, recordType := range r.RecordTypes {
+		switch strings.ToLower(recordType) {
+		case "mx":
+			records.MX = dedupe(data.MX)
+		case "txt":
+			records.TXT = dedupe(data.TXT)
+		case "ns":
+			records.NS = dedupe(data.NS)
+		}
+	}
+	return records, nil
+}
+
+// dedupe removes the duplicate values keeping the original order,
+// which matters for the CNAME chain
+func dedupe(values []string) []string {
+	seen := make(map[string]struct{}, len(values))
+	var result []string
+	for _, value := range values {
+		if _, ok := seen[value]; ok {
+			continue
+		}
+		seen[value] = struct{}{}
+		result = append(result, value)
+	}
+	return result
+}
@@ -1,13 +1,17 @@
 package runner
 
 import (
+	"maps"
 	"os"
 	"strings"
+	"sync"
 
 	"gopkg.in/yaml.v3"
 
 	"github.com/projectdiscovery/gologger"
 	"github.com/projectdiscovery/subfinder/v2/pkg/passive"
+	"github.com/projectdiscovery/subfinder/v2/pkg/subscraping"
+	"github.com/projectdiscovery/subfinder/v2/pkg/subscraping/sources/declarative"
 	fileutil "github.com/projectdiscovery/utils/file"
 )
 
@@ -34,22 +38,75 @@ func createProviderConfigYAML(configFilePath string) error {
 	return yaml.NewEncoder(configFile).Encode(sourcesRequiringApiKeysMap)
 }
 
-// UnmarshalFrom writes the marshaled yaml config to disk
+var (
+	// unmarshaledProviderKeys are the keys read by UnmarshalFrom for the runners created afterwards
+	unmarshaledProviderKeys   = make(map[string][]string)
+	unmarshaledProviderKeysMu sync.Mutex
+)
+
+// UnmarshalFrom reads the API keys of the sources from the provider config file.
+// The keys are used by the runners created afterwards, the keys of their own
+// provider config taking precedence.
+//
+// Deprecated: set Options.ProviderConfig instead.
 func UnmarshalFrom(file string) error {
+	keys, err := loadProviderKeys(file)
+	unmarshaledProviderKeysMu.Lock()
+	defer unmarshaledProviderKeysMu.Unlock()
+	maps.Copy(unmarshaledProviderKeys, keys)
+	return err
+}
+
+// withUnmarshaledProviderKeys adds the keys read by UnmarshalFrom to the keys
+// of the sources missing from the given ones
+func withUnmarshaledProviderKeys(keys map[string][]string) map[string][]string {
+	unmarshaledProviderKeysMu.Lock()
+	defer unmarshaledProviderKeysMu.Unlock()
+
+	merged := maps.Clone(unmarshaledProviderKeys)
+	maps.Copy(merged, keys)
+	return merged
+}
+
+// loadProviderKeys reads the API keys of the sources from the provider config file,
+// returning the keys found along with the decoding error if any
+func loadProviderKeys(file string) (map[string][]string, error) {
 	reader, err := fileutil.SubstituteConfigFromEnvVars(file)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	sourceApiKeysMap := map[string][]string{}
 	err = yaml.NewDecoder(reader).Decode(sourceApiKeysMap)
+	keys := make(map[string][]string)
 	for _, source := range passive.AllSources {
 		sourceName := strings.ToLower(source.Name())
 		apiKeys := sourceApiKeysMap[sourceName]
-		if source.NeedsKey() && apiKeys != nil && len(apiKeys) > 0 {
+		if source.NeedsKey() && len(apiKeys) > 0 {
 			gologger.Debug().Msgf("API key(s) found for %s.", sourceName)
-			source.AddApiKeys(apiKeys)
+			keys[sourceName] = apiKeys
 		}
 	}
-	return err
+	return keys, err
+}
+
+// loadDeclarativeSources registers the sources defined in the YAML files of the
+// sources directory, their default rate limit applying unless set with -rls
+func (options *Options) loadDeclarativeSources() error {
+	definitions, err := declarative.LoadDirectory(options.SourcesDirectory)
+	if err != nil {
+		return err
+	}
+	for _, definition := range definitions {
+		if err := passive.Register(func() subscraping.Source { return declarative.New(definition) }); err != nil {
+			return err
+		}
+		if _, ok := options.RateLimits.AsMap()[definition.Name]; !ok && definition.RateLimit != "" {
+			if err := options.RateLimits.Set(definition.Name + "=" + definition.RateLimit); err != nil {
+				return err
+			}
+		}
+		gologger.Debug().Msgf("Loaded the %s source from %s", definition.Name, options.SourcesDirectory)
+	}
+	return nil
 }
@@ -0,0 +1,20 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnmarshalFrom(t *testing.T) {
+	t.Cleanup(func() { clear(unmarshaledProviderKeys) })
+
+	file := filepath.Join(t.TempDir(), "provider-config.yaml")
+	require.NoError(t, os.WriteFile(file, []byte("shodan:\n  - legacy-key\nvirustotal:\n  - legacy-key\n"), 0600))
+	require.NoError(t, UnmarshalFrom(file))
+
+	keys := withUnmarshaledProviderKeys(map[string][]string{"shodan": {"provider-key"}})
+	require.Equal(t, map[string][]string{"shodan": {"provider-key"}, "virustotal": {"legacy-key"}}, keys, "the keys of the provider config take precedence")
+}
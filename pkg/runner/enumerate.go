@@ -10,6 +10,7 @@ import (
 	"github.com/hako/durafmt"
 
 	"github.com/projectdiscovery/gologger"
+	"github.com/projectdiscovery/ratelimit"
 
 	"github.com/projectdiscovery/subfinder/v2/pkg/passive"
 	"github.com/projectdiscovery/subfinder/v2/pkg/resolve"
@@ -33,9 +34,35 @@ func (r *Runner) EnumerateSingleDomain(domain string, writers []io.Writer) (map[
 }
 
 // EnumerateSingleDomainWithCtx performs subdomain enumeration against a single domain
+// and returns the sources of each subdomain found. The subdomains are only moved to
+// disk with the DedupeDisk option, in which case the returned map is nil.
 func (r *Runner) EnumerateSingleDomainWithCtx(ctx context.Context, domain string, writers []io.Writer) (map[string]map[string]struct{}, error) {
+	return r.enumerateSingleDomain(ctx, domain, writers, nil, true)
+}
+
+// enumerateSingleDomain performs subdomain enumeration against a single domain,
+// optionally sharing the rate limiter with other concurrent enumerations. When
+// returnSources is set the sources of the subdomains are returned, so the subdomains
+// are only moved to disk with the DedupeDisk option.
+func (r *Runner) enumerateSingleDomain(ctx context.Context, domain string, writers []io.Writer, multiRateLimiter *ratelimit.MultiLimiter, returnSources bool) (map[string]map[string]struct{}, error) {
 	gologger.Info().Msgf("Enumerating subdomains for %s\n", domain)
 
+	// Create a store filtering duplicate subdomains out and tracking the sources
+	// of each host, which moves them to disk when there are too many of them
+	dedupeThreshold := r.dedupeThreshold()
+	if returnSources && !r.options.DedupeDisk {
+		dedupeThreshold = 0
+	}
+	hosts, err := newHostStore(dedupeThreshold, r.options.DedupeDisk)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := hosts.close(); err != nil {
+			gologger.Warning().Msgf("Could not remove the subdomains stored on disk for %s: %s\n", domain, err)
+		}
+	}()
+
 	// Check if the user has asked to remove wildcards explicitly.
 	// If yes, create the resolution pool and get the wildcards for the current domain
 	var resolutionPool *resolve.ResolutionPool
@@ -48,20 +75,53 @@ func (r *Runner) EnumerateSingleDomainWithCtx(ctx context.Context, domain string
 		}
 	}
 
+	// The enumerations of the domain and of its subdomains share the same rate
+	// limiter so that the recursion does not multiply the limits of the sources
+	if multiRateLimiter == nil {
+		multiRateLimiter, err = r.passiveAgent.BuildMultiRateLimiter(ctx, r.options.RateLimit, r.rateLimit)
+		if err != nil {
+			return nil, err
+		}
+		defer multiRateLimiter.Stop()
+	}
+
 	// Run the passive subdomain enumeration
 	now := time.Now()
-	passiveResults := r.passiveAgent.EnumerateSubdomainsWithCtx(ctx, domain, r.options.Proxy, r.options.RateLimit, r.options.Timeout, time.Duration(r.options.MaxEnumerationTime)*time.Minute, passive.WithCustomRateLimit(r.rateLimit))
+	enumerateOptions := []passive.EnumerateOption{passive.WithCustomRateLimit(r.rateLimit), passive.WithMultiRateLimiter(multiRateLimiter)}
+	if r.responseCache != nil {
+		enumerateOptions = append(enumerateOptions, passive.WithResponseCache(r.responseCache))
+	}
+	if r.retrier != nil {
+		enumerateOptions = append(enumerateOptions, passive.WithRetrier(r.retrier))
+	}
+	if r.keyManager != nil {
+		enumerateOptions = append(enumerateOptions, passive.WithKeyManager(r.keyManager))
+	}
+	if r.adaptiveLimiter != nil {
+		enumerateOptions = append(enumerateOptions, passive.WithAdaptiveLimiter(r.adaptiveLimiter))
+	}
+	// The statistics of the domain are kept apart from the ones of the concurrent enumerations
+	domainStatistics := &passive.SourceStatistics{}
+	enumerateOptions = append(enumerateOptions, passive.WithStatistics(domainStatistics))
+	passiveResults := r.passiveAgent.EnumerateSubdomainsWithCtx(ctx, domain, r.options.Proxy, r.options.RateLimit, r.options.Timeout, time.Duration(r.options.MaxEnumerationTime)*time.Minute, enumerateOptions...)
+	results := r.enumerateRecursively(ctx, domain, passiveResults, enumerateOptions, gologger.DefaultLogger)
 
 	wg := &sync.WaitGroup{}
 	wg.Add(1)
-	// Create a unique map for filtering duplicate subdomains out
-	uniqueMap := make(map[string]resolve.HostEntry)
-	// Create a map to track sources for each host
-	sourceMap := make(map[string]map[string]struct{})
+	// Create a map to track when and how each source found a host when requested
+	var provenance provenanceMap
+	if r.options.Provenance {
+		provenance = make(provenanceMap)
+	}
 	skippedCounts := make(map[string]int)
+	// Write the hosts as soon as they are found when streaming
+	var streamer *hostStreamer
+	if r.options.Stream {
+		streamer = r.newHostStreamer(domain, writers, hosts)
+	}
 	// Process the results in a separate goroutine
 	go func() {
-		for result := range passiveResults {
+		for result := range results {
 			switch result.Type {
 			case subscraping.Error:
 				gologger.Warning().Msgf("Encountered an error with source %s: %s\n", result.Source, result.Error)
@@ -78,42 +138,36 @@ func (r *Runner) EnumerateSingleDomainWithCtx(ctx context.Context, domain string
 				}
 
 				if matchSubdomain := r.filterAndMatchSubdomain(subdomain); matchSubdomain {
-					if _, ok := uniqueMap[subdomain]; !ok {
-						sourceMap[subdomain] = make(map[string]struct{})
-					}
+					hostEntry := resolve.HostEntry{Domain: domain, Host: subdomain, Source: result.Source, WildcardCertificate: isWildcard, Parent: result.Parent, Depth: result.Depth}
+					isNew, isNewSource := hosts.add(hostEntry)
 
 					// Log the verbose message about the found subdomain per source
-					if _, ok := sourceMap[subdomain][result.Source]; !ok {
+					if isNewSource {
 						gologger.Verbose().Label(result.Source).Msg(subdomain)
 					}
 
-					sourceMap[subdomain][result.Source] = struct{}{}
+					if provenance != nil {
+						provenance.add(subdomain, result.Result, time.Now())
+					}
 
 					// Check if the subdomain is a duplicate. If not,
 					// send the subdomain for resolution.
-					if _, ok := uniqueMap[subdomain]; ok {
+					if !isNew {
 						skippedCounts[result.Source]++
-						// even if it is duplicate if it was not marked as wildcard before but this source says it is wildcard
-						// then we should mark it as wildcard
-						if !uniqueMap[subdomain].WildcardCertificate && isWildcard {
-							val := uniqueMap[subdomain]
-							val.WildcardCertificate = true
-							uniqueMap[subdomain] = val
-						}
 						continue
 					}
 
-					hostEntry := resolve.HostEntry{Domain: domain, Host: subdomain, Source: result.Source, WildcardCertificate: isWildcard}
 					if r.options.ResultCallback != nil && !r.options.RemoveWildcard {
 						r.options.ResultCallback(&hostEntry)
 					}
 
-					uniqueMap[subdomain] = hostEntry
 					// If the user asked to remove wildcard then send on the resolve
 					// queue. Otherwise, if mode is not verbose print the results on
 					// the screen as they are discovered.
 					if r.options.RemoveWildcard {
 						resolutionPool.Tasks <- hostEntry
+					} else if streamer != nil {
+						streamer.writeHost(hostEntry)
 					}
 				}
 			}
@@ -128,7 +182,6 @@ func (r *Runner) EnumerateSingleDomainWithCtx(ctx context.Context, domain string
 
 	// If the user asked to remove wildcards, listen from the results
 	// queue and write to the map. At the end, print the found results to the screen
-	foundResults := make(map[string]resolve.Result)
 	if r.options.RemoveWildcard {
 		// Process the results coming from the resolutions pool
 		for result := range resolutionPool.Results {
@@ -136,64 +189,115 @@ func (r *Runner) EnumerateSingleDomainWithCtx(ctx context.Context, domain string
 			case resolve.Error:
 				gologger.Warning().Msgf("Could not resolve host: %s\n", result.Error)
 			case resolve.Subdomain:
-				// Add the found subdomain to a map.
-				if _, ok := foundResults[result.Host]; !ok {
-					foundResults[result.Host] = result
+				// Add the found subdomain to the store.
+				if hosts.addResult(result) {
+					if result.TakeoverCandidate {
+						gologger.Info().Msgf("Takeover candidate %s: %s\n", result.Host, result.TakeoverReason)
+					}
 					if r.options.ResultCallback != nil {
-						r.options.ResultCallback(&resolve.HostEntry{Domain: domain, Host: result.Host, Source: result.Source, WildcardCertificate: result.WildcardCertificate})
+						r.options.ResultCallback(&resolve.HostEntry{Domain: domain, Host: result.Host, Source: result.Source, WildcardCertificate: result.WildcardCertificate, Parent: result.Parent, Depth: result.Depth})
+					}
+					if streamer != nil {
+						streamer.writeResult(result)
 					}
 				}
 			}
 		}
+	}
+	wg.Wait()
 
-		// Merge wildcard certificate information from uniqueMap into foundResults
-		// This handles cases where a later source marked a subdomain as wildcard
-		// after it was already sent to the resolution pool
-		for host, result := range foundResults {
-			if entry, ok := uniqueMap[host]; ok && entry.WildcardCertificate && !result.WildcardCertificate {
-				result.WildcardCertificate = true
-				foundResults[host] = result
-			}
+	interrupted := ctx.Err() != nil
+	if interrupted {
+		gologger.Warning().Msgf("Enumeration of %s was interrupted, writing partial results\n", domain)
+	}
+
+	// Concurrent enumerations share the writers, so the output
+	// of every domain is written as a single block
+	if hosts.onDisk() {
+		r.outputMutex.Lock()
+		defer r.outputMutex.Unlock()
+
+		found, err := r.writeStoredHosts(domain, hosts, writers, streamer)
+		if err != nil {
+			gologger.Error().Msgf("Could not write results for %s: %s\n", domain, err)
+			return nil, err
 		}
+		r.logEnumerationSummary(domain, now, found, skippedCounts, domainStatistics)
+		return nil, nil
 	}
-	wg.Wait()
-	outputWriter := NewOutputWriter(r.options.JSON)
-	// Now output all results in output writers
-	var err error
-	for _, writer := range writers {
-		if r.options.HostIP {
-			err = outputWriter.WriteHostIP(domain, foundResults, writer)
-		} else {
-			if r.options.RemoveWildcard {
-				err = outputWriter.WriteHostNoWildcard(domain, foundResults, writer)
+
+	uniqueMap, foundResults, sourceMap := hosts.maps()
+
+	if (len(r.wordlist) > 0 || r.options.Permute) && !interrupted {
+		r.runPermutationStage(ctx, domain, uniqueMap, foundResults, sourceMap)
+		interrupted = ctx.Err() != nil
+	}
+
+	r.scoreHosts(uniqueMap, foundResults, sourceMap)
+
+	r.outputMutex.Lock()
+	defer r.outputMutex.Unlock()
+
+	// The hosts an interrupted enumeration did not find yet would be recorded as gone
+	if r.store != nil && !interrupted {
+		if err := r.updateHistory(domain, now, uniqueMap, foundResults, sourceMap); err != nil {
+			gologger.Warning().Msgf("Could not update history for %s: %s\n", domain, err)
+		}
+	}
+
+	// Only what was not streamed is left to write when streaming
+	if streamer != nil {
+		if err := streamer.finish(uniqueMap, foundResults, sourceMap, provenance); err != nil {
+			gologger.Error().Msgf("Could not write results for %s: %s\n", domain, err)
+			return nil, err
+		}
+	} else {
+		outputWriter := NewOutputWriter(r.options.JSON)
+		// Now output all results in output writers
+		for _, writer := range writers {
+			if r.options.HostIP {
+				err = outputWriter.WriteHostIP(domain, foundResults, writer)
 			} else {
-				if r.options.CaptureSources {
-					err = outputWriter.WriteSourceHost(domain, sourceMap, writer)
+				if r.options.RemoveWildcard {
+					err = outputWriter.WriteHostNoWildcard(domain, foundResults, writer)
 				} else {
-					err = outputWriter.WriteHost(domain, uniqueMap, writer)
+					if r.options.Provenance {
+						err = outputWriter.WriteSourceProvenanceHost(domain, sourceMap, provenance, uniqueMap, writer)
+					} else if r.options.CaptureSources {
+						err = outputWriter.WriteSourceHostEntries(domain, sourceMap, uniqueMap, writer)
+					} else {
+						err = outputWriter.WriteHost(domain, uniqueMap, writer)
+					}
 				}
 			}
-		}
-		if err != nil {
-			gologger.Error().Msgf("Could not write results for %s: %s\n", domain, err)
-			return nil, err
+			if err != nil {
+				gologger.Error().Msgf("Could not write results for %s: %s\n", domain, err)
+				return nil, err
+			}
 		}
 	}
 
 	// Show found subdomain count in any case.
-	duration := durafmt.Parse(time.Since(now)).LimitFirstN(maxNumCount).String()
 	var numberOfSubDomains int
 	if r.options.RemoveWildcard {
 		numberOfSubDomains = len(foundResults)
 	} else {
 		numberOfSubDomains = len(uniqueMap)
 	}
+	r.logEnumerationSummary(domain, now, numberOfSubDomains, skippedCounts, domainStatistics)
 
+	return sourceMap, nil
+}
+
+// logEnumerationSummary logs the number of subdomains found for
+// a domain along with the source statistics when requested
+func (r *Runner) logEnumerationSummary(domain string, start time.Time, numberOfSubDomains int, skippedCounts map[string]int, domainStatistics *passive.SourceStatistics) {
+	duration := durafmt.Parse(time.Since(start)).LimitFirstN(maxNumCount).String()
 	gologger.Info().Msgf("Found %d subdomains for %s in %s\n", numberOfSubDomains, domain, duration)
 
 	if r.options.Statistics {
 		gologger.Info().Msgf("Printing source statistics for %s", domain)
-		statistics := r.passiveAgent.GetStatistics()
+		statistics := r.withCacheStatistics(domainStatistics.Get())
 		// This is a hack to remove the skipped count from the statistics
 		// as we don't want to show it in the statistics.
 		// TODO: Design a better way to do this.
@@ -203,10 +307,8 @@ func (r *Runner) EnumerateSingleDomainWithCtx(ctx context.Context, domain string
 				statistics[source] = stat
 			}
 		}
-		printStatistics(statistics)
+		printStatistics(statistics, r.responseCache != nil)
 	}
-
-	return sourceMap, nil
 }
 
 func (r *Runner) filterAndMatchSubdomain(subdomain string) bool {
@@ -1,10 +1,15 @@
 package runner
 
 import (
+	"bytes"
+	"context"
+	"io"
 	"os"
 	"testing"
 
 	"github.com/stretchr/testify/require"
+
+	"github.com/projectdiscovery/subfinder/v2/pkg/subscraping"
 )
 
 func TestFilterAndMatchSubdomain(t *testing.T) {
@@ -143,3 +148,17 @@ func TestFilterAndMatchSubdomain(t *testing.T) {
 		}
 	})
 }
+
+func TestEnumerateSingleDomainKeepsSourcesInMemory(t *testing.T) {
+	runner := newStaticSourcesRunner(&staticSource{name: "first", results: []subscraping.Result{
+		{Type: subscraping.Subdomain, Value: "www.example.com"},
+		{Type: subscraping.Subdomain, Value: "api.example.com"},
+	}})
+	runner.options.DedupeThreshold = 1
+
+	output := &bytes.Buffer{}
+	sourceMap, err := runner.EnumerateSingleDomainWithCtx(context.Background(), "example.com", []io.Writer{output})
+	require.NoError(t, err)
+	require.Len(t, sourceMap, 2, "the threshold does not move the subdomains to disk when their sources are returned")
+	require.Contains(t, output.String(), "api.example.com")
+}
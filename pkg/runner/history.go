@@ -0,0 +1,66 @@
+package runner
+
+import (
+	"errors"
+	"time"
+
+	"github.com/projectdiscovery/gologger"
+
+	"github.com/projectdiscovery/subfinder/v2/pkg/resolve"
+	"github.com/projectdiscovery/subfinder/v2/pkg/store"
+)
+
+// History returns the recorded history of the subdomains found for a domain
+func (r *Runner) History(domain string) ([]store.Record, error) {
+	if r.store == nil {
+		return nil, errors.New("history store is not enabled")
+	}
+	return r.store.Records(preprocessDomain(domain))
+}
+
+// updateHistory records the hosts found for a domain in the history store,
+// writes the hosts that disappeared since the previous run and, in new-only mode,
+// removes all the previously seen hosts from the results.
+func (r *Runner) updateHistory(domain string, seenAt time.Time, uniqueMap map[string]resolve.HostEntry, foundResults map[string]resolve.Result, sourceMap map[string]map[string]struct{}) error {
+	// Only the hosts which are part of the output are recorded
+	hosts := sourceMap
+	if r.options.RemoveWildcard {
+		hosts = make(map[string]map[string]struct{}, len(foundResults))
+		for host := range foundResults {
+			hosts[host] = sourceMap[host]
+		}
+	}
+
+	diff, err := r.store.Update(domain, hosts, seenAt)
+	if err != nil {
+		return err
+	}
+
+	gologger.Info().Msgf("Found %d new and %d gone subdomains for %s since the previous run\n", len(diff.New), len(diff.Gone), domain)
+
+	if r.options.GoneOutput != "" && len(diff.Gone) > 0 {
+		outputWriter := NewOutputWriter(r.options.JSON)
+		file, err := outputWriter.createFile(r.options.GoneOutput, true)
+		if err != nil {
+			return err
+		}
+		err = outputWriter.WriteGoneHost(domain, diff.Gone, file)
+		if closeErr := file.Close(); closeErr != nil {
+			gologger.Error().Msgf("Error closing file %s: %s", r.options.GoneOutput, closeErr)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if r.options.NewOnly {
+		for host := range sourceMap {
+			if _, ok := diff.New[host]; !ok {
+				delete(uniqueMap, host)
+				delete(foundResults, host)
+				delete(sourceMap, host)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package runner
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/projectdiscovery/subfinder/v2/pkg/resolve"
+	"github.com/projectdiscovery/subfinder/v2/pkg/store"
+	"github.com/projectdiscovery/subfinder/v2/pkg/subscraping"
+)
+
+func TestInterruptedEnumerationKeepsHistory(t *testing.T) {
+	dir := t.TempDir()
+	history, err := store.New(filepath.Join(dir, "history"))
+	require.NoError(t, err)
+	_, err = history.Update("example.com", map[string]map[string]struct{}{
+		"www.example.com": {"static": {}},
+		"api.example.com": {"static": {}},
+	}, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	records, err := os.ReadFile(filepath.Join(dir, "history", "example.com.jsonl"))
+	require.NoError(t, err)
+	lastRun, err := os.ReadFile(filepath.Join(dir, "history", "example.com.last-run"))
+	require.NoError(t, err)
+
+	runner := newStaticSourcesRunner(&staticSource{name: "static", results: []subscraping.Result{
+		{Type: subscraping.Subdomain, Value: "www.example.com"},
+		{Type: subscraping.Subdomain, Value: "api.example.com"},
+	}})
+	runner.store = history
+	gone := filepath.Join(dir, "gone.txt")
+	runner.options.GoneOutput = gone
+
+	// The enumeration is interrupted once the first subdomain is found
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	runner.options.ResultCallback = func(*resolve.HostEntry) { cancel() }
+	_, err = runner.EnumerateSingleDomainWithCtx(ctx, "example.com", []io.Writer{io.Discard})
+	require.NoError(t, err)
+
+	updated, err := os.ReadFile(filepath.Join(dir, "history", "example.com.jsonl"))
+	require.NoError(t, err)
+	require.Equal(t, string(records), string(updated), "the history is not updated by an interrupted enumeration")
+	updated, err = os.ReadFile(filepath.Join(dir, "history", "example.com.last-run"))
+	require.NoError(t, err)
+	require.Equal(t, string(lastRun), string(updated), "the time of the last run is not updated by an interrupted enumeration")
+	require.NoFileExists(t, gone, "no host is reported gone by an interrupted enumeration")
+}
@@ -0,0 +1,344 @@
+package runner
+
+import (
+	"io"
+	"sync"
+
+	jsoniter "github.com/json-iterator/go"
+
+	"github.com/projectdiscovery/gologger"
+	"github.com/projectdiscovery/hmap/store/hybrid"
+
+	"github.com/projectdiscovery/subfinder/v2/pkg/resolve"
+)
+
+// storedHost is a host along with its sources and resolution
+// result as saved in the disk-backed map
+type storedHost struct {
+	Entry   resolve.HostEntry `json:"entry"`
+	Sources []string          `json:"sources"`
+	Result  *resolve.Result   `json:"result,omitempty"`
+}
+
+// sourceSet returns the sources of the host as a set
+func (h *storedHost) sourceSet() map[string]struct{} {
+	sources := make(map[string]struct{}, len(h.Sources))
+	for _, source := range h.Sources {
+		sources[source] = struct{}{}
+	}
+	return sources
+}
+
+// hostStore deduplicates the hosts found for a domain along with their sources
+// and resolution results. The hosts are kept in memory until their number reaches
+// the threshold, then they are moved to a disk-backed map which bounds the memory
+// used by the enumeration of domains with millions of subdomains.
+type hostStore struct {
+	mu        sync.Mutex
+	threshold int
+
+	uniqueMap    map[string]resolve.HostEntry
+	sourceMap    map[string]map[string]struct{}
+	foundResults map[string]resolve.Result
+
+	disk *hybrid.HybridMap
+}
+
+// newHostStore creates a host store moving the hosts to disk once it holds threshold
+// hosts, 0 keeping them in memory. The hosts are stored on disk from the start when
+// onDisk is true.
+func newHostStore(threshold int, onDisk bool) (*hostStore, error) {
+	s := &hostStore{
+		threshold:    threshold,
+		uniqueMap:    make(map[string]resolve.HostEntry),
+		sourceMap:    make(map[string]map[string]struct{}),
+		foundResults: make(map[string]resolve.Result),
+	}
+	if onDisk {
+		if err := s.moveToDisk(); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// add records a host reported by the source of the entry. It returns whether the
+// host is new and whether the source had not reported the host before.
+func (s *hostStore) add(entry resolve.HostEntry) (isNew, isNewSource bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.disk != nil {
+		return s.addOnDisk(entry)
+	}
+
+	existing, ok := s.uniqueMap[entry.Host]
+	if !ok {
+		s.uniqueMap[entry.Host] = entry
+		s.sourceMap[entry.Host] = map[string]struct{}{entry.Source: {}}
+		if s.threshold > 0 && len(s.uniqueMap) >= s.threshold {
+			if err := s.moveToDisk(); err != nil {
+				gologger.Warning().Msgf("Could not move the subdomains to disk, keeping them in memory: %s\n", err)
+				s.threshold = 0
+			}
+		}
+		return true, true
+	}
+
+	_, sourceFound := s.sourceMap[entry.Host][entry.Source]
+	s.sourceMap[entry.Host][entry.Source] = struct{}{}
+	// even if it is duplicate if it was not marked as wildcard before but this source says it is wildcard
+	// then we should mark it as wildcard
+	if !existing.WildcardCertificate && entry.WildcardCertificate {
+		existing.WildcardCertificate = true
+		s.uniqueMap[entry.Host] = existing
+	}
+	return false, !sourceFound
+}
+
+func (s *hostStore) addOnDisk(entry resolve.HostEntry) (isNew, isNewSource bool) {
+	host, ok := s.get(entry.Host)
+	if !ok {
+		s.set(&storedHost{Entry: entry, Sources: []string{entry.Source}})
+		return true, true
+	}
+
+	changed := false
+	isNewSource = true
+	for _, source := range host.Sources {
+		if source == entry.Source {
+			isNewSource = false
+			break
+		}
+	}
+	if isNewSource {
+		host.Sources = append(host.Sources, entry.Source)
+		changed = true
+	}
+	if !host.Entry.WildcardCertificate && entry.WildcardCertificate {
+		host.Entry.WildcardCertificate = true
+		changed = true
+	}
+	if changed {
+		s.set(host)
+	}
+	return false, isNewSource
+}
+
+// addResult records the resolution result of a host, returning whether it is new
+func (s *hostStore) addResult(result resolve.Result) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.disk == nil {
+		if _, ok := s.foundResults[result.Host]; ok {
+			return false
+		}
+		s.foundResults[result.Host] = result
+		return true
+	}
+
+	host, ok := s.get(result.Host)
+	if !ok || host.Result != nil {
+		return false
+	}
+	host.Result = &result
+	s.set(host)
+	return true
+}
+
+// onDisk returns whether the hosts were moved to disk
+func (s *hostStore) onDisk() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.disk != nil
+}
+
+// maps returns the hosts kept in memory, merging the wildcard certificate information
+// found after the resolution of the hosts into their results
+func (s *hostStore) maps() (map[string]resolve.HostEntry, map[string]resolve.Result, map[string]map[string]struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// This handles cases where a later source marked a subdomain as wildcard
+	// after it was already sent to the resolution pool
+	for host, result := range s.foundResults {
+		if entry, ok := s.uniqueMap[host]; ok && entry.WildcardCertificate && !result.WildcardCertificate {
+			result.WildcardCertificate = true
+			s.foundResults[host] = result
+		}
+	}
+	return s.uniqueMap, s.foundResults, s.sourceMap
+}
+
+// scan calls f for every host stored on disk until it returns an error
+func (s *hostStore) scan(f func(*storedHost) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var scanErr error
+	s.disk.Scan(func(_, value []byte) error {
+		var host storedHost
+		if err := jsoniter.Unmarshal(value, &host); err != nil {
+			scanErr = err
+			return err
+		}
+		if err := f(&host); err != nil {
+			scanErr = err
+			return err
+		}
+		return nil
+	})
+	return scanErr
+}
+
+// close removes the hosts stored on disk
+func (s *hostStore) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.disk == nil {
+		return nil
+	}
+	return s.disk.Close()
+}
+
+// moveToDisk creates the disk-backed map and moves the hosts kept in memory to it
+func (s *hostStore) moveToDisk() error {
+	disk, err := hybrid.New(hybrid.DefaultDiskOptions)
+	if err != nil {
+		return err
+	}
+	s.disk = disk
+
+	if len(s.uniqueMap) > 0 {
+		gologger.Info().Msgf("Moving %d subdomains to disk to bound memory usage\n", len(s.uniqueMap))
+	}
+	for host, entry := range s.uniqueMap {
+		stored := &storedHost{Entry: entry}
+		for source := range s.sourceMap[host] {
+			stored.Sources = append(stored.Sources, source)
+		}
+		if result, ok := s.foundResults[host]; ok {
+			stored.Result = &result
+		}
+		s.set(stored)
+	}
+	s.uniqueMap, s.sourceMap, s.foundResults = nil, nil, nil
+	return nil
+}
+
+func (s *hostStore) get(host string) (*storedHost, bool) {
+	value, ok := s.disk.Get(host)
+	if !ok {
+		return nil, false
+	}
+	var stored storedHost
+	if err := jsoniter.Unmarshal(value, &stored); err != nil {
+		gologger.Warning().Msgf("Could not decode %s from disk: %s\n", host, err)
+		return nil, false
+	}
+	return &stored, true
+}
+
+func (s *hostStore) set(host *storedHost) {
+	value, err := jsoniter.Marshal(host)
+	if err == nil {
+		err = s.disk.Set(host.Entry.Host, value)
+	}
+	if err != nil {
+		gologger.Warning().Msgf("Could not store %s on disk: %s\n", host.Entry.Host, err)
+	}
+}
+
+// storedHostsBatchSize is the number of hosts read from disk written at once
+const storedHostsBatchSize = 1000
+
+// dedupeThreshold returns the number of hosts above which they are moved to disk,
+// 0 when an enabled feature needs all the hosts of a domain in memory
+func (r *Runner) dedupeThreshold() int {
+	if len(r.wordlist) > 0 || r.options.Permute || r.store != nil || r.options.Provenance {
+		return 0
+	}
+	return r.options.DedupeThreshold
+}
+
+// writeStoredHosts writes the hosts stored on disk to the outputs by batches,
+// scoring them on the way, and returns the number of hosts written
+func (r *Runner) writeStoredHosts(domain string, hosts *hostStore, writers []io.Writer, streamer *hostStreamer) (int, error) {
+	outputWriter := NewOutputWriter(r.options.JSON)
+	uniqueMap := make(map[string]resolve.HostEntry)
+	foundResults := make(map[string]resolve.Result)
+	sourceMap := make(map[string]map[string]struct{})
+
+	flush := func() error {
+		defer func() {
+			clear(uniqueMap)
+			clear(foundResults)
+			clear(sourceMap)
+		}()
+
+		for _, writer := range writers {
+			var err error
+			switch {
+			case r.options.HostIP:
+				err = outputWriter.WriteHostIP(domain, foundResults, writer)
+			case r.options.RemoveWildcard:
+				err = outputWriter.WriteHostNoWildcard(domain, foundResults, writer)
+			case r.options.CaptureSources:
+				err = outputWriter.WriteSourceHostEntries(domain, sourceMap, uniqueMap, writer)
+			default:
+				err = outputWriter.WriteHost(domain, uniqueMap, writer)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// The hosts were written as they were found when streaming,
+	// only the merged sources are left to write
+	writeHosts := streamer == nil || (!r.options.RemoveWildcard && r.options.CaptureSources)
+
+	var found, batched int
+	err := hosts.scan(func(host *storedHost) error {
+		if r.options.RemoveWildcard && host.Result == nil {
+			return nil
+		}
+
+		sources := host.sourceSet()
+		wildcardOnly := host.Entry.WildcardCertificate && len(sources) <= 1
+		score := r.options.confidenceScore(sources, host.Result != nil, wildcardOnly)
+		if score < r.options.minScore {
+			return nil
+		}
+		found++
+		if !writeHosts {
+			return nil
+		}
+
+		host.Entry.Score = score
+		uniqueMap[host.Entry.Host] = host.Entry
+		sourceMap[host.Entry.Host] = sources
+		if host.Result != nil {
+			result := *host.Result
+			result.Score = score
+			// A later source may have marked the host as wildcard after its resolution
+			result.WildcardCertificate = result.WildcardCertificate || host.Entry.WildcardCertificate
+			foundResults[host.Entry.Host] = result
+		}
+
+		batched++
+		if batched < storedHostsBatchSize {
+			return nil
+		}
+		batched = 0
+		return flush()
+	})
+	if err != nil {
+		return found, err
+	}
+	return found, flush()
+}
@@ -0,0 +1,65 @@
+package runner
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/projectdiscovery/subfinder/v2/pkg/resolve"
+)
+
+func TestHostStoreMovesToDisk(t *testing.T) {
+	hosts, err := newHostStore(2, false)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, hosts.close())
+	}()
+
+	isNew, isNewSource := hosts.add(resolve.HostEntry{Host: "www.example.com", Source: "crtsh"})
+	require.True(t, isNew)
+	require.True(t, isNewSource)
+	require.True(t, hosts.addResult(resolve.Result{Host: "www.example.com", IP: "192.0.2.1"}))
+	require.False(t, hosts.onDisk())
+
+	isNew, _ = hosts.add(resolve.HostEntry{Host: "api.example.com", Source: "crtsh"})
+	require.True(t, isNew)
+	require.True(t, hosts.onDisk(), "hosts should be moved to disk once the threshold is reached")
+
+	isNew, isNewSource = hosts.add(resolve.HostEntry{Host: "www.example.com", Source: "crtsh"})
+	require.False(t, isNew)
+	require.False(t, isNewSource)
+	isNew, isNewSource = hosts.add(resolve.HostEntry{Host: "api.example.com", Source: "alienvault", WildcardCertificate: true})
+	require.False(t, isNew)
+	require.True(t, isNewSource)
+	require.False(t, hosts.addResult(resolve.Result{Host: "www.example.com", IP: "192.0.2.1"}))
+
+	stored := make(map[string]*storedHost)
+	require.NoError(t, hosts.scan(func(host *storedHost) error {
+		stored[host.Entry.Host] = host
+		return nil
+	}))
+	require.Len(t, stored, 2)
+	require.Equal(t, "192.0.2.1", stored["www.example.com"].Result.IP)
+	require.ElementsMatch(t, []string{"crtsh", "alienvault"}, stored["api.example.com"].Sources)
+	require.True(t, stored["api.example.com"].Entry.WildcardCertificate, "wildcard certificates should be merged on disk")
+}
+
+func TestWriteStoredHosts(t *testing.T) {
+	hosts, err := newHostStore(0, true)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, hosts.close())
+	}()
+	hosts.add(resolve.HostEntry{Host: "www.example.com", Source: "crtsh"})
+	hosts.add(resolve.HostEntry{Host: "www.example.com", Source: "alienvault"})
+	hosts.add(resolve.HostEntry{Host: "junk.example.com", Source: "waybackarchive"})
+
+	runner := &Runner{options: &Options{CaptureSources: true, minScore: 0.5}}
+	var buf bytes.Buffer
+	found, err := runner.writeStoredHosts("example.com", hosts, []io.Writer{&buf}, nil)
+	require.NoError(t, err)
+	require.Equal(t, 1, found, "hosts below the minimum score should not be written")
+	require.Contains(t, []string{"www.example.com,[crtsh,alienvault]\n", "www.example.com,[alienvault,crtsh]\n"}, buf.String())
+}
@@ -5,13 +5,17 @@ import (
 	"strings"
 
 	"github.com/projectdiscovery/dnsx/libs/dnsx"
+	"github.com/projectdiscovery/gologger"
 	"github.com/projectdiscovery/subfinder/v2/pkg/passive"
 	"github.com/projectdiscovery/subfinder/v2/pkg/resolve"
+	"github.com/projectdiscovery/subfinder/v2/pkg/store"
+	"github.com/projectdiscovery/subfinder/v2/pkg/subscraping"
+	"github.com/projectdiscovery/subfinder/v2/pkg/takeover"
 )
 
 // initializePassiveEngine creates the passive engine and loads sources etc
 func (r *Runner) initializePassiveEngine() {
-	r.passiveAgent = passive.New(r.options.Sources, r.options.ExcludeSources, r.options.All, r.options.OnlyRecursive)
+	r.passiveAgent = passive.New(r.options.Sources, r.options.ExcludeSources, r.options.All, r.options.OnlyRecursive, passive.WithAPIKeys(r.options.providerKeys))
 }
 
 // initializeResolver creates the resolver used to resolve the found subdomains
@@ -41,11 +45,95 @@ func (r *Runner) initializeResolver() error {
 	}
 
 	r.resolverClient = resolve.New()
+	dnsxOptions := dnsx.Options{BaseResolvers: resolvers, MaxRetries: 5}
+	// The takeover detection needs the CNAME chains of the hosts
+	if r.options.CollectRecords || r.options.Takeover {
+		questionTypes, err := resolve.QuestionTypes(r.options.RecordTypes)
+		if err != nil {
+			return err
+		}
+		dnsxOptions.QuestionTypes = questionTypes
+		r.resolverClient.CollectRecords = true
+		r.resolverClient.RecordTypes = r.options.RecordTypes
+	}
+
+	if r.options.Takeover {
+		r.resolverClient.Fingerprints = takeover.Default()
+		if r.options.TakeoverFingerprints != "" {
+			fingerprints, err := takeover.Load(r.options.TakeoverFingerprints)
+			if err != nil {
+				return err
+			}
+			r.resolverClient.Fingerprints = fingerprints
+		}
+	}
+
 	var err error
-	r.resolverClient.DNSClient, err = dnsx.New(dnsx.Options{BaseResolvers: resolvers, MaxRetries: 5})
+	r.resolverClient.DNSClient, err = dnsx.New(dnsxOptions)
 	if err != nil {
 		return nil
 	}
 
 	return nil
 }
+
+// initializeStore opens the persistent history store
+func (r *Runner) initializeStore() error {
+	storeDirectory := r.options.StoreDirectory
+	if storeDirectory == "" {
+		storeDirectory = defaultStoreLocation
+	}
+
+	var err error
+	r.store, err = store.New(storeDirectory)
+	return err
+}
+
+// initializeRetrier creates the retrier of the failed requests of the sources
+func (r *Runner) initializeRetrier() {
+	retryOptions := subscraping.DefaultRetryOptions
+	retryOptions.Default.MaxRetries = r.options.Retries
+	retryOptions.BreakerThreshold = r.options.CircuitBreaker
+	retryOptions.Sources = make(map[string]subscraping.RetryPolicy, len(r.options.sourceRetries))
+	for source, retries := range r.options.sourceRetries {
+		policy := retryOptions.Default
+		policy.MaxRetries = retries
+		retryOptions.Sources[source] = policy
+	}
+	r.retrier = subscraping.NewRetrier(retryOptions)
+}
+
+// initializeAdaptiveLimiter creates the adaptive limiter, starting the sources at
+// the rate limits learned by the previous runs or else at their static rate limits
+func (r *Runner) initializeAdaptiveLimiter() {
+	initial := make(map[string]float64)
+	for source, sourceRateLimit := range r.options.RateLimits.AsMap() {
+		if sourceRateLimit.MaxCount > 0 && sourceRateLimit.Duration > 0 {
+			initial[source] = float64(sourceRateLimit.MaxCount) / sourceRateLimit.Duration.Seconds()
+		}
+	}
+	r.adaptiveLimiter = subscraping.NewAdaptiveLimiter(subscraping.DefaultAdaptiveOptions, initial)
+	if err := r.adaptiveLimiter.Load(defaultRateLimitsLocation); err != nil {
+		gologger.Warning().Msgf("Could not load the learned rate limits from %s: %s\n", defaultRateLimitsLocation, err)
+	}
+}
+
+// initializeResponseCache creates the source response cache if any cache duration is configured
+func (r *Runner) initializeResponseCache() error {
+	enabled := r.options.CacheTTL > 0
+	for _, ttl := range r.options.cacheTTLs {
+		enabled = enabled || ttl > 0
+	}
+	if !enabled {
+		return nil
+	}
+
+	cacheDirectory := r.options.CacheDirectory
+	if cacheDirectory == "" {
+		cacheDirectory = defaultCacheLocation
+	}
+
+	var err error
+	r.responseCache, err = subscraping.NewResponseCache(cacheDirectory, r.options.CacheTTL, r.options.cacheTTLs, r.options.RefreshCache)
+	return err
+}
@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/projectdiscovery/chaos-client/pkg/chaos"
 	"github.com/projectdiscovery/goflags"
@@ -26,48 +27,92 @@ var (
 	configDir                     = folderutil.AppConfigDirOrDefault(".", "subfinder")
 	defaultConfigLocation         = envutil.GetEnvOrDefault("SUBFINDER_CONFIG", filepath.Join(configDir, "config.yaml"))
 	defaultProviderConfigLocation = envutil.GetEnvOrDefault("SUBFINDER_PROVIDER_CONFIG", filepath.Join(configDir, "provider-config.yaml"))
+	defaultStoreLocation          = filepath.Join(configDir, "store")
+	defaultCacheLocation          = filepath.Join(configDir, "cache")
+	defaultSourcesLocation        = filepath.Join(configDir, "sources")
+	defaultRateLimitsLocation     = filepath.Join(configDir, "rate-limits.json")
 )
 
 // Options contains the configuration options for tuning
 // the subdomain enumeration process.
 type Options struct {
-	Verbose            bool                // Verbose flag indicates whether to show verbose output or not
-	NoColor            bool                // NoColor disables the colored output
-	JSON               bool                // JSON specifies whether to use json for output format or text file
-	HostIP             bool                // HostIP specifies whether to write subdomains in host:ip format
-	Silent             bool                // Silent suppresses any extra text and only writes subdomains to screen
-	ListSources        bool                // ListSources specifies whether to list all available sources
-	RemoveWildcard     bool                // RemoveWildcard specifies whether to remove potential wildcard or dead subdomains from the results.
-	CaptureSources     bool                // CaptureSources specifies whether to save all sources that returned a specific domains or just the first source
-	Stdin              bool                // Stdin specifies whether stdin input was given to the process
-	Version            bool                // Version specifies if we should just show version and exit
-	OnlyRecursive      bool                // Recursive specifies whether to use only recursive subdomain enumeration sources
-	All                bool                // All specifies whether to use all (slow) sources.
-	Statistics         bool                // Statistics specifies whether to report source statistics
-	Threads            int                 // Threads controls the number of threads to use for active enumerations
-	Timeout            int                 // Timeout is the seconds to wait for sources to respond
-	MaxEnumerationTime int                 // MaxEnumerationTime is the maximum amount of time in minutes to wait for enumeration
-	Domain             goflags.StringSlice // Domain is the domain to find subdomains for
-	DomainsFile        string              // DomainsFile is the file containing list of domains to find subdomains for
-	Output             io.Writer
-	OutputFile         string               // Output is the file to write found subdomains to.
-	OutputDirectory    string               // OutputDirectory is the directory to write results to in case list of domains is given
-	Sources            goflags.StringSlice  `yaml:"sources,omitempty"`         // Sources contains a comma-separated list of sources to use for enumeration
-	ExcludeSources     goflags.StringSlice  `yaml:"exclude-sources,omitempty"` // ExcludeSources contains the comma-separated sources to not include in the enumeration process
-	Resolvers          goflags.StringSlice  `yaml:"resolvers,omitempty"`       // Resolvers is the comma-separated resolvers to use for enumeration
-	ResolverList       string               // ResolverList is a text file containing list of resolvers to use for enumeration
-	Config             string               // Config contains the location of the config file
-	ProviderConfig     string               // ProviderConfig contains the location of the provider config file
-	Proxy              string               // HTTP proxy
-	RateLimit          int                  // Global maximum number of HTTP requests to send per second
-	RateLimits         goflags.RateLimitMap // Maximum number of HTTP requests to send per second
-	ExcludeIps         bool
-	Match              goflags.StringSlice
-	Filter             goflags.StringSlice
-	matchRegexes       []*regexp.Regexp
-	filterRegexes      []*regexp.Regexp
-	ResultCallback     OnResultCallback // OnResult callback
-	DisableUpdateCheck bool             // DisableUpdateCheck disable update checking
+	Verbose              bool                // Verbose flag indicates whether to show verbose output or not
+	NoColor              bool                // NoColor disables the colored output
+	JSON                 bool                // JSON specifies whether to use json for output format or text file
+	HostIP               bool                // HostIP specifies whether to write subdomains in host:ip format
+	Silent               bool                // Silent suppresses any extra text and only writes subdomains to screen
+	ListSources          bool                // ListSources specifies whether to list all available sources
+	RemoveWildcard       bool                // RemoveWildcard specifies whether to remove potential wildcard or dead subdomains from the results.
+	CaptureSources       bool                // CaptureSources specifies whether to save all sources that returned a specific domains or just the first source
+	Provenance           bool                // Provenance specifies whether to output the time and the evidence of every source that returned a domain
+	Stdin                bool                // Stdin specifies whether stdin input was given to the process
+	Version              bool                // Version specifies if we should just show version and exit
+	OnlyRecursive        bool                // Recursive specifies whether to use only recursive subdomain enumeration sources
+	All                  bool                // All specifies whether to use all (slow) sources.
+	Statistics           bool                // Statistics specifies whether to report source statistics
+	Threads              int                 // Threads controls the number of threads to use for active enumerations
+	Timeout              int                 // Timeout is the seconds to wait for sources to respond
+	MaxEnumerationTime   int                 // MaxEnumerationTime is the maximum amount of time in minutes to wait for enumeration
+	Domain               goflags.StringSlice // Domain is the domain to find subdomains for
+	DomainsFile          string              // DomainsFile is the file containing list of domains to find subdomains for
+	Output               io.Writer
+	OutputFile           string               // Output is the file to write found subdomains to.
+	OutputDirectory      string               // OutputDirectory is the directory to write results to in case list of domains is given
+	Sources              goflags.StringSlice  `yaml:"sources,omitempty"`         // Sources contains a comma-separated list of sources to use for enumeration
+	ExcludeSources       goflags.StringSlice  `yaml:"exclude-sources,omitempty"` // ExcludeSources contains the comma-separated sources to not include in the enumeration process
+	Resolvers            goflags.StringSlice  `yaml:"resolvers,omitempty"`       // Resolvers is the comma-separated resolvers to use for enumeration
+	ResolverList         string               // ResolverList is a text file containing list of resolvers to use for enumeration
+	Config               string               // Config contains the location of the config file
+	ProviderConfig       string               // ProviderConfig contains the location of the provider config file
+	SourcesDirectory     string               // SourcesDirectory is the directory holding the YAML definitions of additional sources
+	Proxy                string               // HTTP proxy
+	RateLimit            int                  // Global maximum number of HTTP requests to send per second
+	RateLimits           goflags.RateLimitMap // Maximum number of HTTP requests to send per second
+	ExcludeIps           bool
+	Match                goflags.StringSlice
+	Filter               goflags.StringSlice
+	matchRegexes         []*regexp.Regexp
+	filterRegexes        []*regexp.Regexp
+	cacheTTLs            map[string]time.Duration
+	sourceWeights        map[string]float64
+	minScore             float64
+	providerKeys         map[string][]string // providerKeys are the API keys of the sources read from the provider config
+	ResultCallback       OnResultCallback    // OnResult callback
+	DisableUpdateCheck   bool                // DisableUpdateCheck disable update checking
+	Store                bool                // Store specifies whether to record results in the persistent history store
+	StoreDirectory       string              // StoreDirectory is the directory holding the persistent history store
+	NewOnly              bool                // NewOnly specifies whether to output only subdomains not seen in previous runs
+	GoneOutput           string              // GoneOutput is the file to write subdomains that disappeared since the previous run to
+	Resume               string              // Resume is the checkpoint file used to resume an interrupted multi-domain enumeration
+	DomainConcurrency    int                 // DomainConcurrency is the number of root domains to enumerate in parallel
+	CacheTTL             time.Duration       // CacheTTL is the default duration the source responses are cached for
+	CacheTTLs            goflags.StringSlice // CacheTTLs contains the per-source cache durations in source=duration format
+	CacheDirectory       string              // CacheDirectory is the directory holding the cached source responses
+	NoCache              bool                // NoCache disables the source response cache
+	RefreshCache         bool                // RefreshCache ignores the cached source responses while still updating them
+	RecursionDepth       int                 // RecursionDepth is the depth up to which discovered subdomains are enumerated recursively
+	MaxRecursiveQueries  int                 // MaxRecursiveQueries is the maximum number of subdomains enumerated recursively per domain
+	Wordlist             string              // Wordlist is the file containing words to brute-force subdomains with
+	Permute              bool                // Permute specifies whether to resolve alterations of the passive results
+	MaxPermutations      int                 // MaxPermutations is the maximum number of generated candidates resolved per domain
+	CollectRecords       bool                // CollectRecords specifies whether to collect all the addresses and the CNAME chain of the hosts
+	RecordTypes          goflags.StringSlice // RecordTypes contains the extra DNS record types to collect (mx, txt, ns)
+	Takeover             bool                // Takeover specifies whether to flag the hosts with dangling CNAMEs to known services
+	TakeoverFingerprints string              // TakeoverFingerprints is the YAML file with additional takeover fingerprints
+	SourceWeights        goflags.StringSlice // SourceWeights contains the per-source reliability used for scoring in source=weight format
+	MinScore             string              // MinScore is the minimum confidence score of the hosts to output
+	Stream               bool                // Stream specifies whether to write the hosts to the outputs as soon as they are found
+	DedupeDisk           bool                // DedupeDisk specifies whether to deduplicate the hosts in a disk-backed map
+	DedupeThreshold      int                 // DedupeThreshold is the number of hosts of a domain above which they are deduplicated on disk
+	Serve                string              // Serve is the listen address of the HTTP API server
+	MetricsFile          string              // MetricsFile is the file to write the Prometheus metrics to at the end of the run
+	Retries              int                 // Retries is the number of times the failed requests of the sources are retried
+	SourceRetries        goflags.StringSlice // SourceRetries contains the per-source number of retries in source=retries format
+	CircuitBreaker       int                 // CircuitBreaker is the number of consecutive failed requests after which a source is paused
+	sourceRetries        map[string]int
+	AdaptiveRateLimit    bool // AdaptiveRateLimit specifies whether to learn the rate limits of the providers
+	SaveRateLimits       bool // SaveRateLimits specifies whether to save the learned rate limits for the next runs
+	VerifyKeys           bool // VerifyKeys specifies whether to verify the API keys of the sources instead of enumerating
 }
 
 // OnResultCallback (hostResult)
@@ -91,6 +136,8 @@ func ParseOptions() *Options {
 	flagSet.CreateGroup("source", "Source",
 		flagSet.StringSliceVarP(&options.Sources, "sources", "s", nil, "specific sources to use for discovery (-s crtsh,github). Use -ls to display all available sources.", goflags.NormalizedStringSliceOptions),
 		flagSet.BoolVar(&options.OnlyRecursive, "recursive", false, "use only sources that can handle subdomains recursively rather than both recursive and non-recursive sources"),
+		flagSet.IntVarP(&options.RecursionDepth, "recursion-depth", "rd", 0, "depth up to which discovered subdomains are enumerated again with recursive sources (0 disables)"),
+		flagSet.IntVarP(&options.MaxRecursiveQueries, "max-recursive-queries", "mrq", 100, "maximum number of subdomains to enumerate recursively per domain (0 for unlimited)"),
 		flagSet.BoolVar(&options.All, "all", false, "use all sources for enumeration (slow)"),
 		flagSet.StringSliceVarP(&options.ExcludeSources, "exclude-sources", "es", nil, "sources to exclude from enumeration (-es alienvault,zoomeyeapi)", goflags.NormalizedStringSliceOptions),
 	)
@@ -98,12 +145,23 @@ func ParseOptions() *Options {
 	flagSet.CreateGroup("filter", "Filter",
 		flagSet.StringSliceVarP(&options.Match, "match", "m", nil, "subdomain or list of subdomain to match (file or comma separated)", goflags.FileNormalizedStringSliceOptions),
 		flagSet.StringSliceVarP(&options.Filter, "filter", "f", nil, " subdomain or list of subdomain to filter (file or comma separated)", goflags.FileNormalizedStringSliceOptions),
+		flagSet.StringSliceVarP(&options.SourceWeights, "source-weights", "sw", nil, "per-source reliability between 0 and 1 used for scoring in key=value format (-sw github=0.2,crtsh=1)", goflags.NormalizedStringSliceOptions),
+		flagSet.StringVarP(&options.MinScore, "min-score", "ms", "", "minimum confidence score between 0 and 1 of the subdomains to output"),
+	)
+
+	flagSet.CreateGroup("permutation", "Permutation",
+		flagSet.StringVarP(&options.Wordlist, "wordlist", "w", "", "file containing words to brute-force subdomains with"),
+		flagSet.BoolVarP(&options.Permute, "permute", "pm", false, "resolve alterations of the passive results (dev-, numeric increments, label swaps)"),
+		flagSet.IntVarP(&options.MaxPermutations, "max-permutations", "mp", 10000, "maximum number of generated candidates to resolve per domain (0 for unlimited)"),
 	)
 
 	flagSet.CreateGroup("rate-limit", "Rate-limit",
 		flagSet.IntVarP(&options.RateLimit, "rate-limit", "rl", 0, "maximum number of http requests to send per second (global)"),
 		flagSet.RateLimitMapVarP(&options.RateLimits, "rate-limits", "rls", defaultRateLimits, "maximum number of http requests to send per second for providers in key=value format (-rls hackertarget=10/m)", goflags.NormalizedStringSliceOptions),
+		flagSet.BoolVarP(&options.AdaptiveRateLimit, "adaptive-rate-limit", "arl", false, "learn the rate limits of the providers, slowing down when rate limited and speeding up on success"),
+		flagSet.BoolVarP(&options.SaveRateLimits, "save-rate-limits", "srl", false, "save the learned rate limits to the config directory for the next runs (-arl only)"),
 		flagSet.IntVar(&options.Threads, "t", 10, "number of concurrent goroutines for resolving (-active only)"),
+		flagSet.IntVarP(&options.DomainConcurrency, "domain-concurrency", "dc", 1, "number of root domains to enumerate in parallel"),
 	)
 
 	flagSet.CreateGroup("update", "Update",
@@ -116,31 +174,65 @@ func ParseOptions() *Options {
 		flagSet.BoolVarP(&options.JSON, "json", "oJ", false, "write output in JSONL(ines) format"),
 		flagSet.StringVarP(&options.OutputDirectory, "output-dir", "oD", "", "directory to write output (-dL only)"),
 		flagSet.BoolVarP(&options.CaptureSources, "collect-sources", "cs", false, "include all sources in the output (-json only)"),
+		flagSet.BoolVarP(&options.Provenance, "provenance", "pv", false, "include when and how each source found the host in the output sources (-json only)"),
 		flagSet.BoolVarP(&options.HostIP, "ip", "oI", false, "include host IP in output (-active only)"),
+		flagSet.BoolVar(&options.Stream, "stream", false, "write each subdomain to the output as soon as it is found (-cs summary written at the end)"),
+		flagSet.StringVarP(&options.MetricsFile, "metrics-file", "mf", "", "file to write prometheus metrics to at the end of the run (textfile collector)"),
+	)
+
+	flagSet.CreateGroup("history", "History",
+		flagSet.BoolVar(&options.Store, "store", false, "record results in the persistent history store"),
+		flagSet.StringVar(&options.StoreDirectory, "store-dir", defaultStoreLocation, "directory of the persistent history store"),
+		flagSet.BoolVarP(&options.NewOnly, "new-only", "diff", false, "output only subdomains not seen in previous runs (implies -store)"),
+		flagSet.StringVar(&options.GoneOutput, "gone", "", "file to write subdomains that disappeared since the previous run to (implies -store)"),
+	)
+
+	flagSet.CreateGroup("cache", "Cache",
+		flagSet.DurationVar(&options.CacheTTL, "cache-ttl", 0, "duration to cache source responses for (0 disables caching)"),
+		flagSet.StringSliceVar(&options.CacheTTLs, "cache-ttls", nil, "per-source cache duration in key=value format (-cache-ttls securitytrails=24h,shodan=12h)", goflags.NormalizedStringSliceOptions),
+		flagSet.StringVar(&options.CacheDirectory, "cache-dir", defaultCacheLocation, "directory of the source response cache"),
+		flagSet.BoolVar(&options.NoCache, "no-cache", false, "disable the source response cache"),
+		flagSet.BoolVar(&options.RefreshCache, "refresh-cache", false, "ignore cached source responses and refresh them"),
 	)
 
 	flagSet.CreateGroup("configuration", "Configuration",
 		flagSet.StringVar(&options.Config, "config", defaultConfigLocation, "flag config file"),
 		flagSet.StringVarP(&options.ProviderConfig, "provider-config", "pc", defaultProviderConfigLocation, "provider config file"),
+		flagSet.StringVarP(&options.SourcesDirectory, "sources-dir", "sd", defaultSourcesLocation, "directory of the yaml definitions of additional http and command sources"),
 		flagSet.StringSliceVar(&options.Resolvers, "r", nil, "comma separated list of resolvers to use", goflags.NormalizedStringSliceOptions),
 		flagSet.StringVarP(&options.ResolverList, "rlist", "rL", "", "file containing list of resolvers to use"),
 		flagSet.BoolVarP(&options.RemoveWildcard, "active", "nW", false, "display active subdomains only"),
+		flagSet.BoolVarP(&options.CollectRecords, "dns-records", "dr", false, "collect all A/AAAA records and the CNAME chain of each host (-active only)"),
+		flagSet.StringSliceVarP(&options.RecordTypes, "record-types", "rt", nil, "additional dns record types to collect (mx,txt,ns) (-dns-records only)", goflags.NormalizedStringSliceOptions),
+		flagSet.BoolVarP(&options.Takeover, "takeover", "to", false, "flag hosts with dangling CNAMEs to known services as takeover candidates (-active only)"),
+		flagSet.StringVarP(&options.TakeoverFingerprints, "takeover-fingerprints", "tf", "", "yaml file with additional takeover fingerprints (-takeover only)"),
 		flagSet.StringVar(&options.Proxy, "proxy", "", "http proxy to use with subfinder"),
 		flagSet.BoolVarP(&options.ExcludeIps, "exclude-ip", "ei", false, "exclude IPs from the list of domains"),
 	)
 
+	flagSet.CreateGroup("server", "Server",
+		flagSet.StringVar(&options.Serve, "serve", "", "run the HTTP API server on the given address (-serve :8080)"),
+	)
+
 	flagSet.CreateGroup("debug", "Debug",
 		flagSet.BoolVar(&options.Silent, "silent", false, "show only subdomains in output"),
 		flagSet.BoolVar(&options.Version, "version", false, "show version of subfinder"),
 		flagSet.BoolVar(&options.Verbose, "v", false, "show verbose output"),
 		flagSet.BoolVarP(&options.NoColor, "no-color", "nc", false, "disable color in output"),
 		flagSet.BoolVarP(&options.ListSources, "list-sources", "ls", false, "list all available sources"),
+		flagSet.BoolVarP(&options.VerifyKeys, "verify-keys", "vk", false, "verify the api keys of the sources in the provider config and exit"),
 		flagSet.BoolVar(&options.Statistics, "stats", false, "report source statistics"),
 	)
 
 	flagSet.CreateGroup("optimization", "Optimization",
 		flagSet.IntVar(&options.Timeout, "timeout", 30, "seconds to wait before timing out"),
 		flagSet.IntVar(&options.MaxEnumerationTime, "max-time", 10, "minutes to wait for enumeration results"),
+		flagSet.StringVar(&options.Resume, "resume", "", "checkpoint file to resume an interrupted enumeration from (skips completed domains)"),
+		flagSet.BoolVarP(&options.DedupeDisk, "dedupe-disk", "dd", false, "deduplicate subdomains on disk to bound memory usage"),
+		flagSet.IntVarP(&options.DedupeThreshold, "dedupe-threshold", "dt", 1000000, "number of subdomains of a domain above which they are deduplicated on disk (0 disables)"),
+		flagSet.IntVar(&options.Retries, "retries", 2, "number of times to retry the requests failing with 429, 5xx or a timeout"),
+		flagSet.StringSliceVarP(&options.SourceRetries, "source-retries", "sr", nil, "per-source number of retries in key=value format (-sr shodan=0,crtsh=5)", goflags.NormalizedStringSliceOptions),
+		flagSet.IntVarP(&options.CircuitBreaker, "circuit-breaker", "cb", 5, "number of consecutive failed requests after which a source is paused for a minute (0 disables)"),
 	)
 
 	if err := flagSet.Parse(); err != nil {
@@ -164,6 +256,10 @@ func ParseOptions() *Options {
 		}
 	}
 
+	if err := options.loadDeclarativeSources(); err != nil {
+		gologger.Fatal().Msgf("Could not load sources from %s: %s\n", options.SourcesDirectory, err)
+	}
+
 	// Default output is stdout
 	options.Output = os.Stdout
 
@@ -216,9 +312,11 @@ func (options *Options) loadProvidersFrom(location string) {
 
 	// We skip bailing out if file doesn't exist because we'll create it
 	// at the end of options parsing from default via goflags.
-	if err := UnmarshalFrom(location); err != nil && (!strings.Contains(err.Error(), "file doesn't exist") || errors.Is(err, os.ErrNotExist)) {
+	keys, err := loadProviderKeys(location)
+	if err != nil && (!strings.Contains(err.Error(), "file doesn't exist") || errors.Is(err, os.ErrNotExist)) {
 		gologger.Error().Msgf("Could not read providers from %s: %s\n", location, err)
 	}
+	options.providerKeys = withUnmarshaledProviderKeys(keys)
 }
 
 func listSources(options *Options) {
@@ -243,11 +341,9 @@ func (options *Options) preProcessDomains() {
 }
 
 var defaultRateLimits = []string{
-	"github=30/m",
 	"fullhunt=60/m",
 	"pugrecon=10/s",
 	fmt.Sprintf("robtex=%d/ms", uint(math.MaxUint)),
-	"securitytrails=1/s",
 	"shodan=1/s",
 	"virustotal=4/m",
 	"hackertarget=2/s",
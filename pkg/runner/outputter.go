@@ -6,11 +6,14 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	jsoniter "github.com/json-iterator/go"
 
 	"github.com/projectdiscovery/subfinder/v2/pkg/resolve"
+	"github.com/projectdiscovery/subfinder/v2/pkg/store"
 )
 
 // OutputWriter outputs content to writers.
@@ -18,19 +21,39 @@ type OutputWriter struct {
 	JSON bool
 }
 
+type jsonDNSRecords struct {
+	IPs   []string `json:"ips,omitempty"`
+	CNAME []string `json:"cname,omitempty"`
+	MX    []string `json:"mx,omitempty"`
+	TXT   []string `json:"txt,omitempty"`
+	NS    []string `json:"ns,omitempty"`
+}
+
 type jsonSourceResult struct {
-	Host                string `json:"host"`
-	Input               string `json:"input"`
-	Source              string `json:"source"`
-	WildcardCertificate bool   `json:"wildcard_certificate,omitempty"`
+	Host                string  `json:"host"`
+	Input               string  `json:"input"`
+	Source              string  `json:"source"`
+	WildcardCertificate bool    `json:"wildcard_certificate,omitempty"`
+	Parent              string  `json:"parent,omitempty"`
+	Depth               int     `json:"depth,omitempty"`
+	Score               float64 `json:"score,omitempty"`
+	jsonDNSRecords
+	TakeoverCandidate bool   `json:"takeover_candidate,omitempty"`
+	TakeoverReason    string `json:"takeover_reason,omitempty"`
 }
 
 type jsonSourceIPResult struct {
-	Host                string `json:"host"`
-	IP                  string `json:"ip"`
-	Input               string `json:"input"`
-	Source              string `json:"source"`
-	WildcardCertificate bool   `json:"wildcard_certificate,omitempty"`
+	Host                string  `json:"host"`
+	IP                  string  `json:"ip"`
+	Input               string  `json:"input"`
+	Source              string  `json:"source"`
+	WildcardCertificate bool    `json:"wildcard_certificate,omitempty"`
+	Parent              string  `json:"parent,omitempty"`
+	Depth               int     `json:"depth,omitempty"`
+	Score               float64 `json:"score,omitempty"`
+	jsonDNSRecords
+	TakeoverCandidate bool   `json:"takeover_candidate,omitempty"`
+	TakeoverReason    string `json:"takeover_reason,omitempty"`
 }
 
 type jsonSourcesResult struct {
@@ -38,6 +61,34 @@ type jsonSourcesResult struct {
 	Input               string   `json:"input"`
 	Sources             []string `json:"sources"`
 	WildcardCertificate bool     `json:"wildcard_certificate,omitempty"`
+	Parent              string   `json:"parent,omitempty"`
+	Depth               int      `json:"depth,omitempty"`
+	Score               float64  `json:"score,omitempty"`
+}
+
+type jsonSourceProvenance struct {
+	Name       string     `json:"name"`
+	FoundAt    *time.Time `json:"found_at,omitempty"`
+	ObservedAt *time.Time `json:"observed_at,omitempty"`
+	Evidence   []string   `json:"evidence,omitempty"`
+}
+
+type jsonSourcesProvenanceResult struct {
+	Host                string                 `json:"host"`
+	Input               string                 `json:"input"`
+	Sources             []jsonSourceProvenance `json:"sources"`
+	WildcardCertificate bool                   `json:"wildcard_certificate,omitempty"`
+	Parent              string                 `json:"parent,omitempty"`
+	Depth               int                    `json:"depth,omitempty"`
+	Score               float64                `json:"score,omitempty"`
+}
+
+type jsonGoneResult struct {
+	Host      string    `json:"host"`
+	Input     string    `json:"input"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	Sources   []string  `json:"sources"`
 }
 
 // NewOutputWriter creates a new OutputWriter
@@ -121,6 +172,12 @@ func writeJSONHostIP(input string, results map[string]resolve.Result, writer io.
 		data.Input = input
 		data.Source = result.Source
 		data.WildcardCertificate = result.WildcardCertificate
+		data.Parent = result.Parent
+		data.Depth = result.Depth
+		data.Score = result.Score
+		data.jsonDNSRecords = newJSONDNSRecords(result.DNSRecords)
+		data.TakeoverCandidate = result.TakeoverCandidate
+		data.TakeoverReason = result.TakeoverReason
 		err := encoder.Encode(&data)
 		if err != nil {
 			return err
@@ -129,16 +186,47 @@ func writeJSONHostIP(input string, results map[string]resolve.Result, writer io.
 	return nil
 }
 
+func newJSONDNSRecords(records resolve.DNSRecords) jsonDNSRecords {
+	return jsonDNSRecords{IPs: records.IPs, CNAME: records.CNAME, MX: records.MX, TXT: records.TXT, NS: records.NS}
+}
+
 // WriteHostNoWildcard writes the output list of subdomain with nW flag to an io.Writer
 func (o *OutputWriter) WriteHostNoWildcard(input string, results map[string]resolve.Result, writer io.Writer) error {
+	if o.JSON {
+		return writeJSONHostNoWildcard(input, results, writer)
+	}
+
 	hosts := make(map[string]resolve.HostEntry)
 	for host, result := range results {
-		hosts[host] = resolve.HostEntry{Domain: host, Host: result.Host, Source: result.Source, WildcardCertificate: result.WildcardCertificate}
+		hosts[host] = resolve.HostEntry{Domain: host, Host: result.Host, Source: result.Source, WildcardCertificate: result.WildcardCertificate, Parent: result.Parent, Depth: result.Depth, Score: result.Score}
 	}
 
 	return o.WriteHost(input, hosts, writer)
 }
 
+func writeJSONHostNoWildcard(input string, results map[string]resolve.Result, writer io.Writer) error {
+	encoder := jsoniter.NewEncoder(writer)
+
+	var data jsonSourceResult
+	for _, result := range results {
+		data.Host = result.Host
+		data.Input = input
+		data.Source = result.Source
+		data.WildcardCertificate = result.WildcardCertificate
+		data.Parent = result.Parent
+		data.Depth = result.Depth
+		data.Score = result.Score
+		data.jsonDNSRecords = newJSONDNSRecords(result.DNSRecords)
+		data.TakeoverCandidate = result.TakeoverCandidate
+		data.TakeoverReason = result.TakeoverReason
+		err := encoder.Encode(&data)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // WriteHost writes the output list of subdomain to an io.Writer
 func (o *OutputWriter) WriteHost(input string, results map[string]resolve.HostEntry, writer io.Writer) error {
 	var err error
@@ -179,6 +267,9 @@ func writeJSONHost(input string, results map[string]resolve.HostEntry, writer io
 		data.Input = input
 		data.Source = result.Source
 		data.WildcardCertificate = result.WildcardCertificate
+		data.Parent = result.Parent
+		data.Depth = result.Depth
+		data.Score = result.Score
 		err := encoder.Encode(data)
 		if err != nil {
 			return err
@@ -189,16 +280,22 @@ func writeJSONHost(input string, results map[string]resolve.HostEntry, writer io
 
 // WriteSourceHost writes the output list of subdomain to an io.Writer
 func (o *OutputWriter) WriteSourceHost(input string, sourceMap map[string]map[string]struct{}, writer io.Writer) error {
+	return o.WriteSourceHostEntries(input, sourceMap, nil, writer)
+}
+
+// WriteSourceHostEntries writes the output list of subdomain with all their sources
+// to an io.Writer, including the details of the matching host entries in JSON format
+func (o *OutputWriter) WriteSourceHostEntries(input string, sourceMap map[string]map[string]struct{}, entries map[string]resolve.HostEntry, writer io.Writer) error {
 	var err error
 	if o.JSON {
-		err = writeSourceJSONHost(input, sourceMap, writer)
+		err = writeSourceJSONHost(input, sourceMap, entries, writer)
 	} else {
 		err = writeSourcePlainHost(input, sourceMap, writer)
 	}
 	return err
 }
 
-func writeSourceJSONHost(input string, sourceMap map[string]map[string]struct{}, writer io.Writer) error {
+func writeSourceJSONHost(input string, sourceMap map[string]map[string]struct{}, entries map[string]resolve.HostEntry, writer io.Writer) error {
 	encoder := jsoniter.NewEncoder(writer)
 
 	var data jsonSourcesResult
@@ -206,6 +303,11 @@ func writeSourceJSONHost(input string, sourceMap map[string]map[string]struct{},
 	for host, sources := range sourceMap {
 		data.Host = host
 		data.Input = input
+		entry := entries[host]
+		data.WildcardCertificate = entry.WildcardCertificate
+		data.Parent = entry.Parent
+		data.Depth = entry.Depth
+		data.Score = entry.Score
 		keys := make([]string, 0, len(sources))
 		for source := range sources {
 			keys = append(keys, source)
@@ -246,3 +348,87 @@ func writeSourcePlainHost(_ string, sourceMap map[string]map[string]struct{}, wr
 	}
 	return bufwriter.Flush()
 }
+
+// WriteSourceProvenanceHost writes the output list of subdomain with all their sources
+// to an io.Writer, describing when and how each source found the host in JSON format
+func (o *OutputWriter) WriteSourceProvenanceHost(input string, sourceMap map[string]map[string]struct{}, provenance provenanceMap, entries map[string]resolve.HostEntry, writer io.Writer) error {
+	if !o.JSON {
+		return writeSourcePlainHost(input, sourceMap, writer)
+	}
+
+	encoder := jsoniter.NewEncoder(writer)
+
+	var data jsonSourcesProvenanceResult
+	for host, sources := range sourceMap {
+		data.Host = host
+		data.Input = input
+		entry := entries[host]
+		data.WildcardCertificate = entry.WildcardCertificate
+		data.Parent = entry.Parent
+		data.Depth = entry.Depth
+		data.Score = entry.Score
+
+		data.Sources = make([]jsonSourceProvenance, 0, len(sources))
+		for source := range sources {
+			item := jsonSourceProvenance{Name: source}
+			// Sources which are not passive, like brute-force, have no provenance
+			if details, ok := provenance[host][source]; ok {
+				item.FoundAt = &details.FoundAt
+				if !details.ObservedAt.IsZero() {
+					item.ObservedAt = &details.ObservedAt
+				}
+				item.Evidence = details.Evidence
+			}
+			data.Sources = append(data.Sources, item)
+		}
+		sort.Slice(data.Sources, func(i, j int) bool { return data.Sources[i].Name < data.Sources[j].Name })
+
+		err := encoder.Encode(&data)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteGoneHost writes the list of subdomains that disappeared since the previous run to an io.Writer
+func (o *OutputWriter) WriteGoneHost(input string, records []store.Record, writer io.Writer) error {
+	if o.JSON {
+		return writeGoneJSONHost(input, records, writer)
+	}
+	return writeGonePlainHost(input, records, writer)
+}
+
+func writeGoneJSONHost(input string, records []store.Record, writer io.Writer) error {
+	encoder := jsoniter.NewEncoder(writer)
+
+	var data jsonGoneResult
+	for _, record := range records {
+		data.Host = record.Host
+		data.Input = input
+		data.FirstSeen = record.FirstSeen
+		data.LastSeen = record.LastSeen
+		data.Sources = record.Sources
+
+		err := encoder.Encode(&data)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeGonePlainHost(_ string, records []store.Record, writer io.Writer) error {
+	bufwriter := bufio.NewWriter(writer)
+
+	for _, record := range records {
+		_, err := bufwriter.WriteString(record.Host + "\n")
+		if err != nil {
+			if flushErr := bufwriter.Flush(); flushErr != nil {
+				return errors.Join(err, flushErr)
+			}
+			return err
+		}
+	}
+	return bufwriter.Flush()
+}
@@ -0,0 +1,106 @@
+package runner
+
+import (
+	"context"
+	"maps"
+	"slices"
+
+	"github.com/projectdiscovery/gologger"
+
+	"github.com/projectdiscovery/subfinder/v2/pkg/permutation"
+	"github.com/projectdiscovery/subfinder/v2/pkg/resolve"
+)
+
+// Synthetic source names of the subdomains found by the active stage
+const (
+	bruteforceSource  = "bruteforce"
+	permutationSource = "permutation"
+)
+
+// runPermutationStage generates candidates from the wordlist and from alterations
+// of the passive results, resolves them eliminating wildcards and merges the valid
+// ones into the results under a synthetic source name. No candidate is sent for
+// resolution once the context is done.
+func (r *Runner) runPermutationStage(ctx context.Context, domain string, uniqueMap map[string]resolve.HostEntry, foundResults map[string]resolve.Result, sourceMap map[string]map[string]struct{}) {
+	if r.resolverClient == nil || r.resolverClient.DNSClient == nil {
+		gologger.Warning().Msgf("No resolver available, skipping permutations for %s\n", domain)
+		return
+	}
+
+	tasks := r.permutationCandidates(domain, uniqueMap)
+	if len(tasks) == 0 {
+		return
+	}
+
+	gologger.Info().Msgf("Resolving %d generated candidates for %s\n", len(tasks), domain)
+
+	resolutionPool := r.resolverClient.NewResolutionPool(r.options.Threads, true)
+	if err := resolutionPool.InitWildcards(domain); err != nil {
+		gologger.Debug().Msgf("Could not get wildcards for domain %s: %s\n", domain, err)
+	}
+	go func() {
+		defer close(resolutionPool.Tasks)
+		for _, task := range tasks {
+			select {
+			case resolutionPool.Tasks <- task:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var found int
+	for result := range resolutionPool.Results {
+		if result.Type != resolve.Subdomain {
+			continue
+		}
+		// Candidates are unique, but guard against duplicates anyway
+		if _, ok := uniqueMap[result.Host]; ok {
+			continue
+		}
+		found++
+
+		hostEntry := resolve.HostEntry{Domain: domain, Host: result.Host, Source: result.Source}
+		gologger.Verbose().Label(result.Source).Msg(result.Host)
+		if r.options.ResultCallback != nil {
+			r.options.ResultCallback(&hostEntry)
+		}
+
+		uniqueMap[result.Host] = hostEntry
+		sourceMap[result.Host] = map[string]struct{}{result.Source: {}}
+		if r.options.RemoveWildcard {
+			foundResults[result.Host] = result
+			if result.TakeoverCandidate {
+				gologger.Info().Msgf("Takeover candidate %s: %s\n", result.Host, result.TakeoverReason)
+			}
+		}
+	}
+
+	gologger.Info().Msgf("Found %d subdomains for %s by permutations and brute-force\n", found, domain)
+}
+
+// permutationCandidates returns the candidates generated from the wordlist and
+// from alterations of the known hosts which are neither known nor filtered out,
+// at most MaxPermutations of them. The known hosts are sorted so that the same
+// candidates are kept from a run to the next.
+func (r *Runner) permutationCandidates(domain string, uniqueMap map[string]resolve.HostEntry) []resolve.HostEntry {
+	var tasks []resolve.HostEntry
+	addCandidates := func(candidates []string, source string) {
+		for _, candidate := range candidates {
+			if _, ok := uniqueMap[candidate]; ok || !r.filterAndMatchSubdomain(candidate) {
+				continue
+			}
+			if r.options.MaxPermutations > 0 && len(tasks) >= r.options.MaxPermutations {
+				return
+			}
+			tasks = append(tasks, resolve.HostEntry{Domain: domain, Host: candidate, Source: source})
+		}
+	}
+	if len(r.wordlist) > 0 {
+		addCandidates(permutation.Bruteforce(domain, r.wordlist), bruteforceSource)
+	}
+	if r.options.Permute {
+		addCandidates(permutation.Alterations(domain, slices.Sorted(maps.Keys(uniqueMap)), permutation.DefaultWords), permutationSource)
+	}
+	return tasks
+}
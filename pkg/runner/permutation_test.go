@@ -0,0 +1,29 @@
+package runner
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/projectdiscovery/subfinder/v2/pkg/resolve"
+)
+
+func TestPermutationCandidatesAreStable(t *testing.T) {
+	runner := &Runner{options: &Options{Permute: true, MaxPermutations: 10}}
+	known := func() map[string]resolve.HostEntry {
+		uniqueMap := make(map[string]resolve.HostEntry)
+		for i := range 50 {
+			host := fmt.Sprintf("host%d.example.com", i)
+			uniqueMap[host] = resolve.HostEntry{Domain: "example.com", Host: host, Source: "crtsh"}
+		}
+		return uniqueMap
+	}
+
+	candidates := runner.permutationCandidates("example.com", known())
+	require.Len(t, candidates, 10)
+	require.Equal(t, "admin-host0.example.com", candidates[0].Host, "the alterations of the first known host in order come first")
+	for range 20 {
+		require.Equal(t, candidates, runner.permutationCandidates("example.com", known()), "the same candidates are kept whatever the order of the map")
+	}
+}
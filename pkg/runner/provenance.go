@@ -0,0 +1,53 @@
+package runner
+
+import (
+	"time"
+
+	"github.com/projectdiscovery/subfinder/v2/pkg/subscraping"
+)
+
+// maxEvidencePerSource is the maximum number of evidences kept for a host
+// per source, as certificate sources can report a host in many certificates
+const maxEvidencePerSource = 10
+
+// sourceProvenance describes when and how a source reported a host
+type sourceProvenance struct {
+	// FoundAt is when the source first reported the host during the enumeration
+	FoundAt time.Time
+	// ObservedAt is the earliest time the source observed the host, zero if unknown
+	ObservedAt time.Time
+	Evidence   []string
+}
+
+// provenanceMap tracks the provenance of every host per source
+type provenanceMap map[string]map[string]*sourceProvenance
+
+// add records a result reported by a source for the host
+func (p provenanceMap) add(host string, result subscraping.Result, foundAt time.Time) {
+	sources, ok := p[host]
+	if !ok {
+		sources = make(map[string]*sourceProvenance)
+		p[host] = sources
+	}
+
+	provenance, ok := sources[result.Source]
+	if !ok {
+		provenance = &sourceProvenance{FoundAt: foundAt}
+		sources[result.Source] = provenance
+	}
+
+	if result.Metadata == nil {
+		return
+	}
+	if timestamp := result.Metadata.Timestamp; !timestamp.IsZero() && (provenance.ObservedAt.IsZero() || timestamp.Before(provenance.ObservedAt)) {
+		provenance.ObservedAt = timestamp
+	}
+	if evidence := result.Metadata.Evidence; evidence != "" && len(provenance.Evidence) < maxEvidencePerSource {
+		for _, existing := range provenance.Evidence {
+			if existing == evidence {
+				return
+			}
+		}
+		provenance.Evidence = append(provenance.Evidence, evidence)
+	}
+}
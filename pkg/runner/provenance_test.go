@@ -0,0 +1,54 @@
+package runner
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/projectdiscovery/subfinder/v2/pkg/subscraping"
+)
+
+func TestProvenanceMap(t *testing.T) {
+	provenance := make(provenanceMap)
+	foundAt := time.Now()
+	older := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	provenance.add("www.example.com", subscraping.Result{Source: "crtsh", Metadata: &subscraping.Metadata{Evidence: "2", Timestamp: newer}}, foundAt)
+	provenance.add("www.example.com", subscraping.Result{Source: "crtsh", Metadata: &subscraping.Metadata{Evidence: "1", Timestamp: older}}, foundAt.Add(time.Second))
+	provenance.add("www.example.com", subscraping.Result{Source: "crtsh", Metadata: &subscraping.Metadata{Evidence: "2"}}, foundAt)
+	provenance.add("www.example.com", subscraping.Result{Source: "alienvault"}, foundAt)
+
+	crtsh := provenance["www.example.com"]["crtsh"]
+	require.Equal(t, foundAt, crtsh.FoundAt, "the first report is kept")
+	require.Equal(t, older, crtsh.ObservedAt, "the earliest observation is kept")
+	require.Equal(t, []string{"2", "1"}, crtsh.Evidence)
+
+	alienvault := provenance["www.example.com"]["alienvault"]
+	require.True(t, alienvault.ObservedAt.IsZero())
+	require.Empty(t, alienvault.Evidence)
+}
+
+func TestWriteSourceProvenanceHost(t *testing.T) {
+	foundAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	provenance := make(provenanceMap)
+	provenance.add("www.example.com", subscraping.Result{Source: "crtsh", Metadata: &subscraping.Metadata{Evidence: "42"}}, foundAt)
+	sourceMap := map[string]map[string]struct{}{
+		"www.example.com": {"crtsh": {}, "bruteforce": {}},
+	}
+
+	var buf bytes.Buffer
+	err := NewOutputWriter(true).WriteSourceProvenanceHost("example.com", sourceMap, provenance, nil, &buf)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"host":"www.example.com","input":"example.com","sources":[{"name":"bruteforce"},{"name":"crtsh","found_at":"2024-01-01T00:00:00Z","evidence":["42"]}]}`, buf.String())
+}
+
+func TestProvenanceValidation(t *testing.T) {
+	options := &Options{Domain: []string{"example.com"}, Threads: 10, Timeout: 10, Provenance: true}
+	require.NoError(t, options.validateOptions())
+
+	options.RemoveWildcard = true
+	require.EqualError(t, options.validateOptions(), "provenance flag cannot be used with active option")
+}
@@ -0,0 +1,107 @@
+package runner
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/projectdiscovery/gologger"
+
+	"github.com/projectdiscovery/subfinder/v2/pkg/passive"
+	"github.com/projectdiscovery/subfinder/v2/pkg/subscraping"
+)
+
+// recursiveResult is a passive result along with the recursive
+// enumeration it was found by
+type recursiveResult struct {
+	subscraping.Result
+	// Parent is the subdomain that was enumerated, empty for the root domain
+	Parent string
+	// Depth is the recursion depth of the enumeration, zero for the root domain
+	Depth int
+}
+
+type recursionTarget struct {
+	name  string
+	depth int
+}
+
+// enumerateRecursively forwards the results of the root domain enumeration and
+// feeds the subdomains found and their parents back into the recursive sources until
+// the configured depth or the maximum number of queries is reached, logging its
+// progress with the given logger.
+func (r *Runner) enumerateRecursively(ctx context.Context, domain string, rootResults <-chan subscraping.Result, enumerateOptions []passive.EnumerateOption, logger *gologger.Logger) <-chan recursiveResult {
+	results := make(chan recursiveResult)
+
+	var recursiveAgent *passive.Agent
+	if r.options.RecursionDepth > 0 {
+		recursiveAgent = r.passiveAgent.RecursiveAgent()
+		if recursiveAgent == nil {
+			logger.Warning().Msgf("No recursive sources selected, skipping recursive enumeration of %s\n", domain)
+		}
+	}
+
+	go func() {
+		defer close(results)
+
+		maxEnumerationTime := time.Duration(r.options.MaxEnumerationTime) * time.Minute
+		ctx, cancel := context.WithTimeout(ctx, maxEnumerationTime)
+		defer cancel()
+
+		// The visited names protect against enumerating the same subdomain twice
+		visited := map[string]struct{}{domain: {}}
+		var queue []recursionTarget
+
+		forward := func(sourceResults <-chan subscraping.Result, parent string, depth int) {
+			for result := range sourceResults {
+				if recursiveAgent != nil && depth < r.options.RecursionDepth && result.Type == subscraping.Subdomain {
+					for _, name := range recursionNames(domain, replacer.Replace(result.Value)) {
+						if _, ok := visited[name]; !ok {
+							visited[name] = struct{}{}
+							queue = append(queue, recursionTarget{name: name, depth: depth + 1})
+						}
+					}
+				}
+				results <- recursiveResult{Result: result, Parent: parent, Depth: depth}
+			}
+		}
+
+		forward(rootResults, "", 0)
+
+		for queries := 0; len(queue) > 0 && ctx.Err() == nil; queries++ {
+			if r.options.MaxRecursiveQueries > 0 && queries >= r.options.MaxRecursiveQueries {
+				logger.Warning().Msgf("Reached the maximum of %d recursive queries for %s, skipping %d subdomains\n", r.options.MaxRecursiveQueries, domain, len(queue))
+				break
+			}
+
+			target := queue[0]
+			queue = queue[1:]
+
+			logger.Verbose().Msgf("Enumerating %s recursively (depth %d)\n", target.name, target.depth)
+			sourceResults := recursiveAgent.EnumerateSubdomainsWithCtx(ctx, target.name, r.options.Proxy, r.options.RateLimit, r.options.Timeout, maxEnumerationTime, enumerateOptions...)
+			forward(sourceResults, target.name, target.depth)
+		}
+	}()
+
+	return results
+}
+
+// recursionNames returns a subdomain and the names between it and its root domain,
+// e.g. a.dev.corp.example.com, dev.corp.example.com and corp.example.com for
+// a.dev.corp.example.com
+func recursionNames(domain, subdomain string) []string {
+	if !strings.HasSuffix(subdomain, "."+domain) {
+		return nil
+	}
+
+	var names []string
+	labels := strings.Split(strings.TrimSuffix(subdomain, "."+domain), ".")
+	if slices.Contains(labels, "") {
+		return nil
+	}
+	for i := range labels {
+		names = append(names, strings.Join(labels[i:], ".")+"."+domain)
+	}
+	return names
+}
@@ -0,0 +1,95 @@
+package runner
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/projectdiscovery/gologger"
+	mapsutil "github.com/projectdiscovery/utils/maps"
+	"github.com/stretchr/testify/require"
+
+	"github.com/projectdiscovery/subfinder/v2/pkg/passive"
+	"github.com/projectdiscovery/subfinder/v2/pkg/subscraping"
+)
+
+func TestRecursionNames(t *testing.T) {
+	tests := []struct {
+		subdomain string
+		expected  []string
+	}{
+		{"a.dev.corp.example.com", []string{"a.dev.corp.example.com", "dev.corp.example.com", "corp.example.com"}},
+		{"www.example.com", []string{"www.example.com"}},
+		{"example.com", nil},
+		{"a..example.com", nil},
+		{"a.b.hackerone.com", nil},
+	}
+	for _, test := range tests {
+		t.Run(test.subdomain, func(t *testing.T) {
+			require.Equal(t, test.expected, recursionNames("example.com", test.subdomain))
+		})
+	}
+}
+
+// recursiveSource returns fixed subdomains for every enumerated name and
+// records the names it was asked for
+type recursiveSource struct {
+	results map[string][]string
+	mu      *sync.Mutex
+	queried *[]string
+}
+
+func (s *recursiveSource) Run(_ context.Context, domain string, _ *subscraping.Session) <-chan subscraping.Result {
+	s.mu.Lock()
+	*s.queried = append(*s.queried, domain)
+	s.mu.Unlock()
+
+	results := make(chan subscraping.Result, len(s.results[domain]))
+	for _, value := range s.results[domain] {
+		results <- subscraping.Result{Source: s.Name(), Type: subscraping.Subdomain, Value: value}
+	}
+	close(results)
+	return results
+}
+
+func (s *recursiveSource) Name() string              { return "recursive" }
+func (s *recursiveSource) IsDefault() bool           { return false }
+func (s *recursiveSource) HasRecursiveSupport() bool { return true }
+func (s *recursiveSource) NeedsKey() bool            { return false }
+func (s *recursiveSource) AddApiKeys(_ []string)     {}
+func (s *recursiveSource) Statistics() subscraping.Statistics {
+	return subscraping.Statistics{}
+}
+
+func TestEnumerateRecursively(t *testing.T) {
+	var mu sync.Mutex
+	var queried []string
+	results := map[string][]string{
+		"example.com":     {"a.b.example.com"},
+		"a.b.example.com": {"x.a.b.example.com"},
+		"b.example.com":   {"y.b.example.com"},
+	}
+	runner := &Runner{
+		options: &Options{Timeout: 10, MaxEnumerationTime: 1, RecursionDepth: 1},
+		passiveAgent: passive.NewAgent([]passive.SourceFactory{func() subscraping.Source {
+			return &recursiveSource{results: results, mu: &mu, queried: &queried}
+		}}),
+		rateLimit: &subscraping.CustomRateLimit{Custom: mapsutil.SyncLockMap[string, uint]{Map: make(map[string]uint)}},
+	}
+
+	ctx := context.Background()
+	enumerateOptions := []passive.EnumerateOption{passive.WithCustomRateLimit(runner.rateLimit)}
+	rootResults := runner.passiveAgent.EnumerateSubdomainsWithCtx(ctx, "example.com", "", 0, 10, time.Minute, enumerateOptions...)
+	found := make(map[string]string)
+	for result := range runner.enumerateRecursively(ctx, "example.com", rootResults, enumerateOptions, gologger.DefaultLogger) {
+		found[result.Value] = result.Parent
+	}
+
+	require.ElementsMatch(t, []string{"example.com", "a.b.example.com", "b.example.com"}, queried, "the found subdomain and its parent are enumerated")
+	require.Equal(t, map[string]string{
+		"a.b.example.com":   "",
+		"x.a.b.example.com": "a.b.example.com",
+		"y.b.example.com":   "b.example.com",
+	}, found)
+}
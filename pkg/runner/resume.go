@@ -0,0 +1,81 @@
+package runner
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"sync"
+)
+
+// resumeCheckpoint tracks the domains whose enumeration completed so that
+// an interrupted run can be continued where it stopped.
+type resumeCheckpoint struct {
+	mu        sync.Mutex
+	path      string
+	file      *os.File
+	completed map[string]struct{}
+}
+
+// openResumeCheckpoint loads the domains already completed from the checkpoint
+// file, creating it if it does not exist yet.
+func openResumeCheckpoint(path string) (*resumeCheckpoint, error) {
+	checkpoint := &resumeCheckpoint{path: path, completed: make(map[string]struct{})}
+
+	file, err := os.Open(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			if domain := preprocessDomain(scanner.Text()); domain != "" {
+				checkpoint.completed[domain] = struct{}{}
+			}
+		}
+		err = scanner.Err()
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	checkpoint.file, err = os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return checkpoint, nil
+}
+
+// isCompleted returns true if the domain was enumerated by a previous run
+func (c *resumeCheckpoint) isCompleted(domain string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, ok := c.completed[domain]
+	return ok
+}
+
+// markCompleted durably records the domain as enumerated
+func (c *resumeCheckpoint) markCompleted(domain string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.completed[domain] = struct{}{}
+	if _, err := c.file.WriteString(domain + "\n"); err != nil {
+		return err
+	}
+	return c.file.Sync()
+}
+
+// close closes the checkpoint file and removes it once all the domains were enumerated
+func (c *resumeCheckpoint) close(finished bool) error {
+	if err := c.file.Close(); err != nil {
+		return err
+	}
+	if finished {
+		return os.Remove(c.path)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package runner
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	fileutil "github.com/projectdiscovery/utils/file"
+	mapsutil "github.com/projectdiscovery/utils/maps"
+	"github.com/stretchr/testify/require"
+
+	"github.com/projectdiscovery/subfinder/v2/pkg/passive"
+	"github.com/projectdiscovery/subfinder/v2/pkg/resolve"
+	"github.com/projectdiscovery/subfinder/v2/pkg/subscraping"
+)
+
+func TestResumeCheckpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "resume.cfg")
+
+	checkpoint, err := openResumeCheckpoint(path)
+	require.Nil(t, err)
+	require.False(t, checkpoint.isCompleted("example.com"))
+	require.Nil(t, checkpoint.markCompleted("example.com"))
+	require.Nil(t, checkpoint.close(false))
+
+	checkpoint, err = openResumeCheckpoint(path)
+	require.Nil(t, err)
+	require.True(t, checkpoint.isCompleted("example.com"))
+	require.False(t, checkpoint.isCompleted("hackerone.com"))
+	require.Nil(t, checkpoint.close(true))
+	require.False(t, fileutil.FileExists(path), "checkpoint should be removed once finished")
+}
+
+func TestRunEnumerationInterrupted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "resume.cfg")
+	runner := &Runner{
+		options:      &Options{Domain: []string{"example.com"}, Resume: path, Output: io.Discard},
+		passiveAgent: passive.New([]string{"crtsh"}, nil, false, false),
+		rateLimit:    &subscraping.CustomRateLimit{Custom: mapsutil.SyncLockMap[string, uint]{Map: make(map[string]uint)}},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := runner.RunEnumerationWithCtx(ctx)
+	require.ErrorIs(t, err, ErrInterrupted)
+	require.True(t, fileutil.FileExists(path), "checkpoint should be kept when interrupted")
+}
+
+func TestResumeDoesNotDuplicateOutput(t *testing.T) {
+	dir := t.TempDir()
+	resume := filepath.Join(dir, "resume.cfg")
+	outputFile := filepath.Join(dir, "output.txt")
+	newRunner := func() *Runner {
+		runner := newStaticSourcesRunner(&staticSource{name: "static", results: []subscraping.Result{
+			{Type: subscraping.Subdomain, Value: "www.example.com"},
+			{Type: subscraping.Subdomain, Value: "www.example.org"},
+			{Type: subscraping.Subdomain, Value: "api.example.org"},
+		}})
+		runner.options.Domain = []string{"example.com", "example.org"}
+		runner.options.Resume = resume
+		runner.options.OutputFile = outputFile
+		runner.options.Output = io.Discard
+		return runner
+	}
+
+	// The run is interrupted once the first subdomain of the second domain is found
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	runner := newRunner()
+	runner.options.ResultCallback = func(result *resolve.HostEntry) {
+		if result.Domain == "example.org" {
+			cancel()
+		}
+	}
+	require.ErrorIs(t, runner.RunEnumerationWithCtx(ctx), ErrInterrupted)
+
+	output, err := os.ReadFile(outputFile)
+	require.NoError(t, err)
+	require.Equal(t, "www.example.com\n", string(output), "the partial results of the interrupted domain are not written")
+
+	require.NoError(t, newRunner().RunEnumerationWithCtx(context.Background()))
+	output, err = os.ReadFile(outputFile)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	require.ElementsMatch(t, []string{"www.example.com", "www.example.org", "api.example.org"}, lines, "the resumed run does not write duplicates")
+	require.False(t, fileutil.FileExists(resume), "checkpoint should be removed once finished")
+}
@@ -3,24 +3,36 @@ package runner
 import (
 	"bufio"
 	"context"
+	"errors"
 	"io"
 	"math"
 	"os"
 	"path"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/projectdiscovery/gologger"
+	"github.com/projectdiscovery/ratelimit"
 	contextutil "github.com/projectdiscovery/utils/context"
 	fileutil "github.com/projectdiscovery/utils/file"
 	mapsutil "github.com/projectdiscovery/utils/maps"
+	syncutil "github.com/projectdiscovery/utils/sync"
 
+	"github.com/projectdiscovery/subfinder/v2/pkg/metrics"
 	"github.com/projectdiscovery/subfinder/v2/pkg/passive"
 	"github.com/projectdiscovery/subfinder/v2/pkg/resolve"
+	"github.com/projectdiscovery/subfinder/v2/pkg/store"
 	"github.com/projectdiscovery/subfinder/v2/pkg/subscraping"
 )
 
+// ErrInterrupted is returned when the enumeration was cancelled before completion,
+// the results found until then having been written to the outputs
+var ErrInterrupted = errors.New("enumeration interrupted")
+
 // Runner is an instance of the subdomain enumeration
 // client used to orchestrate the whole process.
 type Runner struct {
@@ -28,6 +40,19 @@ type Runner struct {
 	passiveAgent   *passive.Agent
 	resolverClient *resolve.Resolver
 	rateLimit      *subscraping.CustomRateLimit
+	store          *store.Store
+	checkpoint     *resumeCheckpoint
+	outputMutex    sync.Mutex
+	responseCache  *subscraping.ResponseCache
+	wordlist       []string
+	// sharedRateLimiter is shared with other runners, e.g. the jobs of the API server
+	sharedRateLimiter *ratelimit.MultiLimiter
+	// retrier is shared by the enumerations for the circuit breakers to span them
+	retrier *subscraping.Retrier
+	// adaptiveLimiter is shared by the enumerations for the learned rate limits to span them
+	adaptiveLimiter *subscraping.AdaptiveLimiter
+	// keyManager is shared by the enumerations for the rejected API keys to stay quarantined
+	keyManager *subscraping.KeyManager
 }
 
 // NewRunner creates a new runner struct instance by parsing
@@ -35,7 +60,7 @@ type Runner struct {
 // and setting up loggers, etc.
 func NewRunner(options *Options) (*Runner, error) {
 	options.ConfigureOutput()
-	runner := &Runner{options: options}
+	runner := &Runner{options: options, keyManager: subscraping.NewKeyManager(time.Duration(options.MaxEnumerationTime) * time.Minute)}
 
 	// Check if the application loading with any provider configuration, then take it
 	// Otherwise load the default provider config
@@ -56,6 +81,38 @@ func NewRunner(options *Options) (*Runner, error) {
 		return nil, err
 	}
 
+	// Load the words used to brute-force subdomains
+	if options.Wordlist != "" {
+		runner.wordlist, err = loadFromFile(options.Wordlist)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Initialize the persistent history store if any history option is used
+	if options.Store || options.NewOnly || options.GoneOutput != "" {
+		err = runner.initializeStore()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Initialize the source response cache unless disabled
+	if !options.NoCache {
+		err = runner.initializeResponseCache()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Initialize the retries of the failed requests of the sources
+	runner.initializeRetrier()
+
+	// Initialize the adaptive rate limits learned from the providers
+	if options.AdaptiveRateLimit {
+		runner.initializeAdaptiveLimiter()
+	}
+
 	// Initialize the custom rate limit
 	runner.rateLimit = &subscraping.CustomRateLimit{
 		Custom: mapsutil.SyncLockMap[string, uint]{
@@ -78,8 +135,63 @@ func (r *Runner) RunEnumeration() error {
 	return r.RunEnumerationWithCtx(ctx)
 }
 
-// RunEnumerationWithCtx runs the subdomain enumeration flow on the targets specified
+// RunEnumerationWithCtx runs the subdomain enumeration flow on the targets specified.
+// When the context is cancelled, the partial results are written and ErrInterrupted is returned.
 func (r *Runner) RunEnumerationWithCtx(ctx context.Context) error {
+	err := r.runEnumerationWithCheckpoint(ctx)
+	r.writeMetricsFile()
+	r.saveRateLimits()
+	if r.options.Statistics {
+		printKeyUsage(r.keyManager.Usage())
+	}
+	if err == nil && errors.Is(ctx.Err(), context.Canceled) {
+		return ErrInterrupted
+	}
+	return err
+}
+
+// writeMetricsFile writes the metrics collected so far to the metrics file if requested
+func (r *Runner) writeMetricsFile() {
+	if r.options.MetricsFile == "" {
+		return
+	}
+	if err := metrics.Default.WriteFile(r.options.MetricsFile); err != nil {
+		gologger.Error().Msgf("Could not write metrics to %s: %s\n", r.options.MetricsFile, err)
+	}
+}
+
+// saveRateLimits saves the learned rate limits for the next runs if requested
+func (r *Runner) saveRateLimits() {
+	if r.adaptiveLimiter == nil || !r.options.SaveRateLimits {
+		return
+	}
+	if err := r.adaptiveLimiter.Save(defaultRateLimitsLocation); err != nil {
+		gologger.Error().Msgf("Could not save the learned rate limits to %s: %s\n", defaultRateLimitsLocation, err)
+	}
+}
+
+func (r *Runner) runEnumerationWithCheckpoint(ctx context.Context) error {
+	if r.options.Resume == "" {
+		return r.runEnumeration(ctx)
+	}
+
+	checkpoint, err := openResumeCheckpoint(r.options.Resume)
+	if err != nil {
+		return err
+	}
+	r.checkpoint = checkpoint
+
+	err = r.runEnumeration(ctx)
+
+	// The checkpoint is only discarded when every domain was enumerated
+	if closeErr := checkpoint.close(err == nil && ctx.Err() == nil); closeErr != nil {
+		gologger.Error().Msgf("Error closing resume file %s: %s", r.options.Resume, closeErr)
+	}
+	r.checkpoint = nil
+	return err
+}
+
+func (r *Runner) runEnumeration(ctx context.Context) error {
 	outputs := []io.Writer{r.options.Output}
 
 	if len(r.options.Domain) > 0 {
@@ -116,10 +228,38 @@ func (r *Runner) EnumerateMultipleDomains(reader io.Reader, writers []io.Writer)
 // EnumerateMultipleDomainsWithCtx enumerates subdomains for multiple domains
 // We keep enumerating subdomains for a given domain until we reach an error
 func (r *Runner) EnumerateMultipleDomainsWithCtx(ctx context.Context, reader io.Reader, writers []io.Writer) error {
-	var err error
+	// All the domains share the same per-source rate limiters so that the
+	// limits hold globally no matter how many domains are enumerated at once
+	multiRateLimiter := r.sharedRateLimiter
+	if multiRateLimiter == nil {
+		var err error
+		multiRateLimiter, err = r.passiveAgent.BuildMultiRateLimiter(ctx, r.options.RateLimit, r.rateLimit)
+		if err != nil {
+			return err
+		}
+		defer multiRateLimiter.Stop()
+	}
+
+	concurrency := max(r.options.DomainConcurrency, 1)
+	swg, err := syncutil.New(syncutil.WithSize(concurrency))
+	if err != nil {
+		return err
+	}
+
+	var (
+		errMutex sync.Mutex
+		firstErr error
+	)
+	failed := func() bool {
+		errMutex.Lock()
+		defer errMutex.Unlock()
+		return firstErr != nil
+	}
+
 	scanner := bufio.NewScanner(reader)
 	ip, _ := regexp.Compile(`^([0-9\.]+$)`)
-	for scanner.Scan() {
+	// A cancelled context stops the enumeration of the remaining domains
+	for ctx.Err() == nil && !failed() && scanner.Scan() {
 		domain := preprocessDomain(scanner.Text())
 		domain = replacer.Replace(domain)
 
@@ -127,49 +267,135 @@ func (r *Runner) EnumerateMultipleDomainsWithCtx(ctx context.Context, reader io.
 			continue
 		}
 
-		var file *os.File
-		// If the user has specified an output file, use that output file instead
-		// of creating a new output file for each domain. Else create a new file
-		// for each domain in the directory.
-		if r.options.OutputFile != "" {
-			outputWriter := NewOutputWriter(r.options.JSON)
-			file, err = outputWriter.createFile(r.options.OutputFile, true)
-			if err != nil {
-				gologger.Error().Msgf("Could not create file %s for %s: %s\n", r.options.OutputFile, r.options.Domain, err)
-				return err
-			}
+		if r.checkpoint != nil && r.checkpoint.isCompleted(domain) {
+			gologger.Info().Msgf("Skipping %s as it was already enumerated\n", domain)
+			continue
+		}
 
-			_, err = r.EnumerateSingleDomainWithCtx(ctx, domain, append(writers, file))
+		swg.Add()
+		go func(domain string) {
+			defer swg.Done()
 
-			if closeErr := file.Close(); closeErr != nil {
-				gologger.Error().Msgf("Error closing file %s: %s", r.options.OutputFile, closeErr)
-			}
-		} else if r.options.OutputDirectory != "" {
-			outputFile := path.Join(r.options.OutputDirectory, domain)
-			if r.options.JSON {
-				outputFile += ".json"
-			} else {
-				outputFile += ".txt"
+			interrupted, err := r.enumerateDomainToOutputs(ctx, domain, writers, multiRateLimiter)
+			if err != nil {
+				errMutex.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				errMutex.Unlock()
+				return
 			}
 
-			outputWriter := NewOutputWriter(r.options.JSON)
-			file, err = outputWriter.createFile(outputFile, false)
-			if err != nil {
-				gologger.Error().Msgf("Could not create file %s for %s: %s\n", r.options.OutputFile, r.options.Domain, err)
-				return err
+			// A cancelled enumeration only returned partial results, so it is not checkpointed
+			if r.checkpoint != nil && !interrupted {
+				if err := r.checkpoint.markCompleted(domain); err != nil {
+					gologger.Warning().Msgf("Could not checkpoint %s: %s\n", domain, err)
+				}
 			}
+		}(domain)
+	}
+	swg.Wait()
+	return firstErr
+}
 
-			_, err = r.EnumerateSingleDomainWithCtx(ctx, domain, append(writers, file))
+// enumerateDomainToOutputs enumerates a single domain writing the results to the
+// given writers as well as to the output file or directory requested by the user.
+// It returns whether the enumeration was interrupted before it completed.
+func (r *Runner) enumerateDomainToOutputs(ctx context.Context, domain string, writers []io.Writer, multiRateLimiter *ratelimit.MultiLimiter) (bool, error) {
+	// The domains interrupted are enumerated again on resume,
+	// so their results are only appended once complete
+	if r.options.OutputFile != "" && r.checkpoint != nil {
+		return r.enumerateDomainToCheckpointedFile(ctx, domain, writers, multiRateLimiter)
+	}
 
-			if closeErr := file.Close(); closeErr != nil {
-				gologger.Error().Msgf("Error closing file %s: %s", outputFile, closeErr)
-			}
+	// If the user has specified an output file, use that output file instead
+	// of creating a new output file for each domain. Else create a new file
+	// for each domain in the directory.
+	if r.options.OutputFile != "" {
+		outputWriter := NewOutputWriter(r.options.JSON)
+		file, err := outputWriter.createFile(r.options.OutputFile, true)
+		if err != nil {
+			gologger.Error().Msgf("Could not create file %s for %s: %s\n", r.options.OutputFile, domain, err)
+			return false, err
+		}
+
+		_, err = r.enumerateSingleDomain(ctx, domain, slices.Concat(writers, []io.Writer{file}), multiRateLimiter, false)
+		interrupted := ctx.Err() != nil
+
+		if closeErr := file.Close(); closeErr != nil {
+			gologger.Error().Msgf("Error closing file %s: %s", r.options.OutputFile, closeErr)
+		}
+		return interrupted, err
+	}
+
+	if r.options.OutputDirectory != "" {
+		outputFile := path.Join(r.options.OutputDirectory, domain)
+		if r.options.JSON {
+			outputFile += ".json"
 		} else {
-			_, err = r.EnumerateSingleDomainWithCtx(ctx, domain, writers)
+			outputFile += ".txt"
 		}
+
+		outputWriter := NewOutputWriter(r.options.JSON)
+		file, err := outputWriter.createFile(outputFile, false)
 		if err != nil {
-			return err
+			gologger.Error().Msgf("Could not create file %s for %s: %s\n", outputFile, domain, err)
+			return false, err
+		}
+
+		_, err = r.enumerateSingleDomain(ctx, domain, slices.Concat(writers, []io.Writer{file}), multiRateLimiter, false)
+		interrupted := ctx.Err() != nil
+
+		if closeErr := file.Close(); closeErr != nil {
+			gologger.Error().Msgf("Error closing file %s: %s", outputFile, closeErr)
 		}
+		return interrupted, err
 	}
-	return nil
+
+	_, err := r.enumerateSingleDomain(ctx, domain, writers, multiRateLimiter, false)
+	return ctx.Err() != nil, err
+}
+
+// enumerateDomainToCheckpointedFile enumerates a single domain writing the results to
+// a temporary file, appended to the output file only if the enumeration completed.
+// The partial results of an interrupted domain are still written to the given writers.
+func (r *Runner) enumerateDomainToCheckpointedFile(ctx context.Context, domain string, writers []io.Writer, multiRateLimiter *ratelimit.MultiLimiter) (bool, error) {
+	pending, err := os.CreateTemp("", "subfinder-"+domain+"-*")
+	if err != nil {
+		gologger.Error().Msgf("Could not create temporary file for %s: %s\n", domain, err)
+		return false, err
+	}
+	defer func() {
+		_ = pending.Close()
+		_ = os.Remove(pending.Name())
+	}()
+
+	if _, err := r.enumerateSingleDomain(ctx, domain, slices.Concat(writers, []io.Writer{pending}), multiRateLimiter, false); err != nil {
+		return false, err
+	}
+	if ctx.Err() != nil {
+		gologger.Info().Msgf("Not writing the partial results of %s to %s as it will be enumerated again on resume\n", domain, r.options.OutputFile)
+		return true, nil
+	}
+	if _, err := pending.Seek(0, io.SeekStart); err != nil {
+		return false, err
+	}
+
+	outputWriter := NewOutputWriter(r.options.JSON)
+	file, err := outputWriter.createFile(r.options.OutputFile, true)
+	if err != nil {
+		gologger.Error().Msgf("Could not create file %s for %s: %s\n", r.options.OutputFile, domain, err)
+		return false, err
+	}
+	// Concurrent enumerations share the output file, so the results are appended as a single block
+	r.outputMutex.Lock()
+	_, err = io.Copy(file, pending)
+	r.outputMutex.Unlock()
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		gologger.Error().Msgf("Could not write results for %s to %s: %s\n", domain, r.options.OutputFile, err)
+	}
+	return false, err
 }
@@ -0,0 +1,87 @@
+package runner
+
+import (
+	"math"
+
+	"github.com/projectdiscovery/subfinder/v2/pkg/resolve"
+)
+
+const (
+	// defaultSourceWeight is the reliability of the sources without a known weight
+	defaultSourceWeight = 0.6
+	// resolutionWeight is the reliability added by a successful resolution
+	resolutionWeight = 0.8
+	// wildcardPenalty scales down the score of the hosts only reported through a wildcard certificate
+	wildcardPenalty = 0.5
+)
+
+// defaultSourceWeights contains the reliability of the sources, between 0 and 1.
+// Certificate transparency sources are nearly always right, while the sources
+// extracting hosts from crawled or indexed content often return garbage labels.
+var defaultSourceWeights = map[string]float64{
+	"certspotter":     0.9,
+	"crtsh":           0.9,
+	"digitorus":       0.9,
+	"facebook":        0.9,
+	"merklemap":       0.9,
+	"censys":          0.85,
+	"commoncrawl":     0.3,
+	"github":          0.3,
+	"waybackarchive":  0.3,
+	bruteforceSource:  0.5,
+	permutationSource: 0.5,
+}
+
+// sourceWeight returns the reliability of a source, the user provided weights taking precedence
+func (options *Options) sourceWeight(source string) float64 {
+	if weight, ok := options.sourceWeights[source]; ok {
+		return weight
+	}
+	if weight, ok := defaultSourceWeights[source]; ok {
+		return weight
+	}
+	return defaultSourceWeight
+}
+
+// confidenceScore computes the confidence that a host exists from the reliability of the
+// sources which reported it, each of them being an independent chance of being right.
+func (options *Options) confidenceScore(sources map[string]struct{}, resolved, wildcardOnly bool) float64 {
+	doubt := 1.0
+	for source := range sources {
+		doubt *= 1 - options.sourceWeight(source)
+	}
+	if resolved {
+		doubt *= 1 - resolutionWeight
+	}
+
+	score := 1 - doubt
+	if wildcardOnly {
+		score *= wildcardPenalty
+	}
+	return math.Round(score*100) / 100
+}
+
+// scoreHosts sets the confidence score of every host and removes
+// the hosts scoring below the minimum score from the results
+func (r *Runner) scoreHosts(uniqueMap map[string]resolve.HostEntry, foundResults map[string]resolve.Result, sourceMap map[string]map[string]struct{}) {
+	for host, entry := range uniqueMap {
+		result, resolved := foundResults[host]
+		// Only the wildcard certificate reported the host when a single source found it
+		wildcardOnly := entry.WildcardCertificate && len(sourceMap[host]) <= 1
+
+		score := r.options.confidenceScore(sourceMap[host], resolved, wildcardOnly)
+		if score < r.options.minScore {
+			delete(uniqueMap, host)
+			delete(foundResults, host)
+			delete(sourceMap, host)
+			continue
+		}
+
+		entry.Score = score
+		uniqueMap[host] = entry
+		if resolved {
+			result.Score = score
+			foundResults[host] = result
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/projectdiscovery/subfinder/v2/pkg/resolve"
+)
+
+func TestConfidenceScore(t *testing.T) {
+	options := &Options{sourceWeights: map[string]float64{"github": 0.1}}
+
+	require.Equal(t, 0.9, options.confidenceScore(map[string]struct{}{"crtsh": {}}, false, false))
+	require.Equal(t, 0.1, options.confidenceScore(map[string]struct{}{"github": {}}, false, false), "configured weights take precedence")
+	require.Equal(t, 0.6, options.confidenceScore(map[string]struct{}{"alienvault": {}}, false, false))
+	require.Equal(t, 0.96, options.confidenceScore(map[string]struct{}{"crtsh": {}, "alienvault": {}}, false, false))
+	require.Equal(t, 0.92, options.confidenceScore(map[string]struct{}{"alienvault": {}}, true, false))
+	require.Equal(t, 0.45, options.confidenceScore(map[string]struct{}{"crtsh": {}}, false, true))
+}
+
+func TestScoreHosts(t *testing.T) {
+	runner := &Runner{options: &Options{minScore: 0.5}}
+	uniqueMap := map[string]resolve.HostEntry{
+		"www.example.com":  {Host: "www.example.com"},
+		"junk.example.com": {Host: "junk.example.com"},
+	}
+	foundResults := map[string]resolve.Result{}
+	sourceMap := map[string]map[string]struct{}{
+		"www.example.com":  {"crtsh": {}},
+		"junk.example.com": {"waybackarchive": {}},
+	}
+
+	runner.scoreHosts(uniqueMap, foundResults, sourceMap)
+
+	require.Equal(t, 0.9, uniqueMap["www.example.com"].Score)
+	require.NotContains(t, uniqueMap, "junk.example.com")
+	require.NotContains(t, sourceMap, "junk.example.com")
+}
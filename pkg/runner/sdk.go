@@ -0,0 +1,328 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/projectdiscovery/gologger"
+	"github.com/projectdiscovery/gologger/levels"
+
+	"github.com/projectdiscovery/subfinder/v2/pkg/passive"
+	"github.com/projectdiscovery/subfinder/v2/pkg/resolve"
+	"github.com/projectdiscovery/subfinder/v2/pkg/subscraping"
+)
+
+// Subdomain is a subdomain yielded by an Enumeration
+type Subdomain struct {
+	Domain string
+	Host   string
+	// Sources are all the sources which returned the host so far
+	Sources []string
+	// DNSRecords are the records of the host in active mode, the extra
+	// ones being only collected when enabled in the options
+	resolve.DNSRecords
+	WildcardCertificate bool
+	// Parent is the subdomain whose recursive enumeration found the host
+	Parent string
+	// Depth is the recursion depth at which the host was found
+	Depth             int
+	TakeoverCandidate bool
+	TakeoverReason    string
+	// Score is the confidence that the host exists given what is known so far
+	Score float64
+	// Update is set when the host was yielded before and a new source
+	// returned it or a source marked it as a wildcard certificate
+	Update bool
+}
+
+// SourceError is an error returned by a source during an enumeration
+type SourceError struct {
+	Source string
+	Err    error
+}
+
+func (e *SourceError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Source, e.Err)
+}
+
+func (e *SourceError) Unwrap() error {
+	return e.Err
+}
+
+// Summary is the outcome of an Enumeration
+type Summary struct {
+	Domain string
+	// Subdomains is the number of distinct subdomains yielded
+	Subdomains int
+	Duration   time.Duration
+	// Statistics are the statistics of the sources run by the enumeration
+	Statistics map[string]subscraping.Statistics
+	// Interrupted is set when the context was done or the
+	// iteration stopped before the enumeration completed
+	Interrupted bool
+}
+
+// Enumeration is the enumeration of the subdomains of a domain for programs
+// embedding subfinder. Nothing is written to the outputs nor logged by the runner,
+// the results being yielded as typed values instead. The sources and their HTTP
+// session still log their debug messages and warnings with gologger.DefaultLogger,
+// whose level the embedding program can lower to silence them.
+type Enumeration struct {
+	runner *Runner
+	ctx    context.Context
+	domain string
+
+	// mu guards the summary, which is written once the results were iterated over
+	mu      sync.Mutex
+	summary Summary
+}
+
+// silentLogger is the logger of the enumerations of the SDK, which log nothing
+var silentLogger = func() *gologger.Logger {
+	logger := &gologger.Logger{}
+	logger.SetMaxLevel(levels.LevelSilent)
+	return logger
+}()
+
+// Enumerate prepares the enumeration of the subdomains of a domain, which is
+// run by iterating over its results. The permutation stage, the history store
+// and the result callback are not used by the enumerations of the SDK.
+func (r *Runner) Enumerate(ctx context.Context, domain string) *Enumeration {
+	domain = replacer.Replace(preprocessDomain(domain))
+	return &Enumeration{runner: r, ctx: ctx, domain: domain, summary: Summary{Domain: domain}}
+}
+
+// Summary returns the summary of the enumeration, complete once its results were iterated over
+func (e *Enumeration) Summary() Summary {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.summary
+}
+
+// sdkEvent is a passive result or a resolution forwarded to the iteration
+type sdkEvent struct {
+	entry      *resolve.HostEntry
+	resolution *resolve.Result
+	err        error
+}
+
+// sdkHost is the state of a host during the iteration
+type sdkHost struct {
+	entry   resolve.HostEntry
+	sources map[string]struct{}
+	result  *resolve.Result
+	yielded bool
+}
+
+// Results runs the enumeration and yields the subdomains as soon as they are
+// found, or resolved in active mode, and again whenever another source returns
+// them. The errors of the sources are yielded as SourceError. Breaking out of
+// the iteration stops the enumeration.
+func (e *Enumeration) Results() iter.Seq2[Subdomain, error] {
+	return func(yield func(Subdomain, error) bool) {
+		r := e.runner
+		start := time.Now()
+		ctx, cancel := context.WithCancel(e.ctx)
+		defer cancel()
+
+		var resolutionPool *resolve.ResolutionPool
+		if r.options.RemoveWildcard {
+			resolutionPool = r.resolverClient.NewResolutionPool(r.options.Threads, r.options.RemoveWildcard)
+			// A domain without wildcards is not an error here
+			_ = resolutionPool.InitWildcards(e.domain)
+		}
+
+		statistics := &passive.SourceStatistics{}
+		events := r.sdkEvents(ctx, e.domain, resolutionPool, statistics)
+
+		hosts := make(map[string]*sdkHost)
+		stopped := false
+		emit := func(host *sdkHost, update bool) {
+			if stopped {
+				return
+			}
+			host.yielded = true
+			if !yield(r.newSubdomain(host, update), nil) {
+				stopped = true
+				cancel()
+			}
+		}
+		for event := range events {
+			if stopped {
+				continue
+			}
+			switch {
+			case event.err != nil:
+				if !yield(Subdomain{}, event.err) {
+					stopped = true
+					cancel()
+				}
+			case event.entry != nil:
+				entry := *event.entry
+				host, ok := hosts[entry.Host]
+				if !ok {
+					host = &sdkHost{entry: entry, sources: make(map[string]struct{})}
+					hosts[entry.Host] = host
+				}
+				_, sourceFound := host.sources[entry.Source]
+				host.sources[entry.Source] = struct{}{}
+				wildcard := !host.entry.WildcardCertificate && entry.WildcardCertificate
+				host.entry.WildcardCertificate = host.entry.WildcardCertificate || entry.WildcardCertificate
+
+				// Hosts are only yielded once resolved in active mode
+				if resolutionPool != nil && host.result == nil {
+					continue
+				}
+				if !ok || !sourceFound || wildcard {
+					emit(host, host.yielded)
+				}
+			case event.resolution != nil:
+				host, ok := hosts[event.resolution.Host]
+				if !ok || host.result != nil {
+					continue
+				}
+				host.result = event.resolution
+				emit(host, false)
+			}
+		}
+
+		summary := Summary{
+			Domain:      e.domain,
+			Duration:    time.Since(start),
+			Interrupted: stopped || e.ctx.Err() != nil,
+			Statistics:  r.withCacheStatistics(statistics.Get()),
+		}
+		for _, host := range hosts {
+			if host.yielded {
+				summary.Subdomains++
+			}
+		}
+		e.mu.Lock()
+		e.summary = summary
+		e.mu.Unlock()
+	}
+}
+
+// sdkEvents runs the passive enumeration of the domain and the resolution of the
+// hosts found, forwarding the results as events until both are done
+func (r *Runner) sdkEvents(ctx context.Context, domain string, resolutionPool *resolve.ResolutionPool, statistics *passive.SourceStatistics) <-chan sdkEvent {
+	events := make(chan sdkEvent)
+
+	// The enumerations of the domain and of its subdomains share the same rate
+	// limiter so that the recursion does not multiply the limits of the sources
+	multiRateLimiter := r.sharedRateLimiter
+	stopRateLimiter := func() {}
+	if multiRateLimiter == nil {
+		var err error
+		multiRateLimiter, err = r.passiveAgent.BuildMultiRateLimiter(ctx, r.options.RateLimit, r.rateLimit)
+		if err != nil {
+			go func() {
+				defer close(events)
+				if resolutionPool != nil {
+					close(resolutionPool.Tasks)
+				}
+				events <- sdkEvent{err: fmt.Errorf("could not init multi rate limiter for %s: %w", domain, err)}
+			}()
+			return events
+		}
+		stopRateLimiter = func() { multiRateLimiter.Stop() }
+	}
+
+	enumerateOptions := []passive.EnumerateOption{passive.WithCustomRateLimit(r.rateLimit), passive.WithMultiRateLimiter(multiRateLimiter), passive.WithStatistics(statistics)}
+	if r.responseCache != nil {
+		enumerateOptions = append(enumerateOptions, passive.WithResponseCache(r.responseCache))
+	}
+	if r.retrier != nil {
+		enumerateOptions = append(enumerateOptions, passive.WithRetrier(r.retrier))
+	}
+	if r.keyManager != nil {
+		enumerateOptions = append(enumerateOptions, passive.WithKeyManager(r.keyManager))
+	}
+	if r.adaptiveLimiter != nil {
+		enumerateOptions = append(enumerateOptions, passive.WithAdaptiveLimiter(r.adaptiveLimiter))
+	}
+	passiveResults := r.passiveAgent.EnumerateSubdomainsWithCtx(ctx, domain, r.options.Proxy, r.options.RateLimit, r.options.Timeout, time.Duration(r.options.MaxEnumerationTime)*time.Minute, enumerateOptions...)
+	results := r.enumerateRecursively(ctx, domain, passiveResults, enumerateOptions, silentLogger)
+
+	passiveDone := make(chan struct{})
+	go func() {
+		defer close(passiveDone)
+		defer stopRateLimiter()
+		if resolutionPool != nil {
+			defer close(resolutionPool.Tasks)
+		}
+
+		seen := make(map[string]struct{})
+		for result := range results {
+			switch result.Type {
+			case subscraping.Error:
+				events <- sdkEvent{err: &SourceError{Source: result.Source, Err: result.Error}}
+			case subscraping.Subdomain:
+				subdomain := replacer.Replace(result.Value)
+				if !strings.HasSuffix(subdomain, "."+domain) || !r.filterAndMatchSubdomain(subdomain) {
+					continue
+				}
+				isWildcard := strings.Contains(result.Value, "*."+subdomain)
+				entry := resolve.HostEntry{Domain: domain, Host: subdomain, Source: result.Source, WildcardCertificate: isWildcard, Parent: result.Parent, Depth: result.Depth}
+				events <- sdkEvent{entry: &entry}
+
+				if _, ok := seen[subdomain]; ok || resolutionPool == nil || ctx.Err() != nil {
+					continue
+				}
+				seen[subdomain] = struct{}{}
+				resolutionPool.Tasks <- entry
+			}
+		}
+	}()
+
+	go func() {
+		defer close(events)
+		if resolutionPool != nil {
+			for result := range resolutionPool.Results {
+				switch result.Type {
+				case resolve.Error:
+					events <- sdkEvent{err: fmt.Errorf("could not resolve %s: %w", result.Host, result.Error)}
+				case resolve.Subdomain:
+					events <- sdkEvent{resolution: &result}
+				}
+			}
+		}
+		<-passiveDone
+	}()
+	return events
+}
+
+// newSubdomain creates the subdomain yielded for a host
+func (r *Runner) newSubdomain(host *sdkHost, update bool) Subdomain {
+	sources := make([]string, 0, len(host.sources))
+	for source := range host.sources {
+		sources = append(sources, source)
+	}
+	slices.Sort(sources)
+
+	subdomain := Subdomain{
+		Domain:              host.entry.Domain,
+		Host:                host.entry.Host,
+		Sources:             sources,
+		WildcardCertificate: host.entry.WildcardCertificate,
+		Parent:              host.entry.Parent,
+		Depth:               host.entry.Depth,
+		Update:              update,
+	}
+	if host.result != nil {
+		subdomain.DNSRecords = host.result.DNSRecords
+		if len(subdomain.IPs) == 0 && host.result.IP != "" {
+			subdomain.IPs = []string{host.result.IP}
+		}
+		subdomain.TakeoverCandidate = host.result.TakeoverCandidate
+		subdomain.TakeoverReason = host.result.TakeoverReason
+	}
+	wildcardOnly := host.entry.WildcardCertificate && len(host.sources) <= 1
+	subdomain.Score = r.options.confidenceScore(host.sources, host.result != nil, wildcardOnly)
+	return subdomain
+}
@@ -0,0 +1,201 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	mapsutil "github.com/projectdiscovery/utils/maps"
+	"github.com/stretchr/testify/require"
+
+	"github.com/projectdiscovery/subfinder/v2/pkg/passive"
+	"github.com/projectdiscovery/subfinder/v2/pkg/subscraping"
+)
+
+// staticSource returns a fixed list of results
+type staticSource struct {
+	name    string
+	results []subscraping.Result
+	count   int
+}
+
+func (s *staticSource) Run(_ context.Context, _ string, _ *subscraping.Session) <-chan subscraping.Result {
+	results := make(chan subscraping.Result, len(s.results))
+	for _, result := range s.results {
+		result.Source = s.name
+		s.count++
+		results <- result
+	}
+	close(results)
+	return results
+}
+
+func (s *staticSource) Name() string              { return s.name }
+func (s *staticSource) IsDefault() bool           { return false }
+func (s *staticSource) HasRecursiveSupport() bool { return false }
+func (s *staticSource) NeedsKey() bool            { return false }
+func (s *staticSource) AddApiKeys(_ []string)     {}
+func (s *staticSource) Statistics() subscraping.Statistics {
+	return subscraping.Statistics{Results: s.count}
+}
+
+// newStaticSourcesRunner creates a runner enumerating with the given sources only
+func newStaticSourcesRunner(sources ...*staticSource) *Runner {
+	var factories []passive.SourceFactory
+	for _, source := range sources {
+		factories = append(factories, func() subscraping.Source {
+			return &staticSource{name: source.name, results: source.results}
+		})
+	}
+	return &Runner{
+		options:      &Options{Timeout: 10, MaxEnumerationTime: 1},
+		passiveAgent: passive.NewAgent(factories),
+		rateLimit:    &subscraping.CustomRateLimit{Custom: mapsutil.SyncLockMap[string, uint]{Map: make(map[string]uint)}},
+	}
+}
+
+func TestEnumerate(t *testing.T) {
+	runner := newStaticSourcesRunner(
+		&staticSource{name: "first", results: []subscraping.Result{
+			{Type: subscraping.Subdomain, Value: "www.example.com"},
+			{Type: subscraping.Subdomain, Value: "www.example.org"},
+			{Type: subscraping.Error, Error: errors.New("quota exceeded")},
+		}},
+	)
+
+	enumeration := runner.Enumerate(context.Background(), "example.com")
+	var subdomains []Subdomain
+	var errs []error
+	for subdomain, err := range enumeration.Results() {
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		subdomains = append(subdomains, subdomain)
+	}
+
+	require.Len(t, subdomains, 1, "hosts outside of the domain are skipped")
+	require.Equal(t, "www.example.com", subdomains[0].Host)
+	require.Equal(t, []string{"first"}, subdomains[0].Sources)
+	require.False(t, subdomains[0].Update)
+
+	require.Len(t, errs, 1)
+	var sourceErr *SourceError
+	require.ErrorAs(t, errs[0], &sourceErr)
+	require.Equal(t, "first", sourceErr.Source)
+
+	summary := enumeration.Summary()
+	require.Equal(t, "example.com", summary.Domain)
+	require.Equal(t, 1, summary.Subdomains)
+	require.False(t, summary.Interrupted)
+	require.Contains(t, summary.Statistics, "first")
+}
+
+func TestEnumerateMergesSources(t *testing.T) {
+	runner := newStaticSourcesRunner(
+		&staticSource{name: "first", results: []subscraping.Result{{Type: subscraping.Subdomain, Value: "www.example.com"}}},
+		&staticSource{name: "second", results: []subscraping.Result{{Type: subscraping.Subdomain, Value: "www.example.com"}}},
+	)
+
+	var subdomains []Subdomain
+	for subdomain, err := range runner.Enumerate(context.Background(), "example.com").Results() {
+		require.NoError(t, err)
+		subdomains = append(subdomains, subdomain)
+	}
+
+	require.Len(t, subdomains, 2, "the host is yielded again when another source returns it")
+	require.False(t, subdomains[0].Update)
+	require.True(t, subdomains[1].Update)
+	require.ElementsMatch(t, []string{"first", "second"}, subdomains[1].Sources)
+}
+
+func TestEnumerateStop(t *testing.T) {
+	runner := newStaticSourcesRunner(
+		&staticSource{name: "first", results: []subscraping.Result{
+			{Type: subscraping.Subdomain, Value: "www.example.com"},
+			{Type: subscraping.Subdomain, Value: "dev.example.com"},
+		}},
+	)
+
+	enumeration := runner.Enumerate(context.Background(), "example.com")
+	for range enumeration.Results() {
+		break
+	}
+	require.True(t, enumeration.Summary().Interrupted)
+	require.Equal(t, 1, enumeration.Summary().Subdomains)
+}
+
+func TestEnumerateConcurrently(t *testing.T) {
+	source := &staticSource{name: "first", results: []subscraping.Result{
+		{Type: subscraping.Subdomain, Value: "www.example.com"},
+		{Type: subscraping.Subdomain, Value: "dev.example.com"},
+	}}
+	runners := []*Runner{newStaticSourcesRunner(source), newStaticSourcesRunner(source)}
+
+	const enumerations = 5
+	summaries := make([]Summary, len(runners)*enumerations)
+	var wg sync.WaitGroup
+	for i, runner := range runners {
+		for j := range enumerations {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				enumeration := runner.Enumerate(context.Background(), "example.com")
+				for range enumeration.Results() {
+				}
+				summaries[i*enumerations+j] = enumeration.Summary()
+			}()
+		}
+	}
+	wg.Wait()
+
+	for _, summary := range summaries {
+		require.Equal(t, 2, summary.Statistics["first"].Results, "every enumeration has its own statistics")
+	}
+
+	for _, runner := range runners {
+		require.Equal(t, 2*enumerations, runner.GetStatistics()["first"].Results, "the runner statistics add up its enumerations")
+	}
+}
+
+// keyedSource returns its API key as a subdomain
+type keyedSource struct {
+	staticSource
+	keys []string
+}
+
+func (s *keyedSource) Run(_ context.Context, domain string, _ *subscraping.Session) <-chan subscraping.Result {
+	results := make(chan subscraping.Result, len(s.keys))
+	for _, key := range s.keys {
+		results <- subscraping.Result{Type: subscraping.Subdomain, Source: s.name, Value: key + "." + domain}
+	}
+	close(results)
+	return results
+}
+
+func (s *keyedSource) NeedsKey() bool           { return true }
+func (s *keyedSource) AddApiKeys(keys []string) { s.keys = keys }
+
+func TestEnumerateRegisteredSource(t *testing.T) {
+	require.NoError(t, passive.Register(func() subscraping.Source {
+		return &keyedSource{staticSource: staticSource{name: "registeredsource"}}
+	}))
+
+	providerConfig := filepath.Join(t.TempDir(), "provider-config.yaml")
+	require.NoError(t, os.WriteFile(providerConfig, []byte("registeredsource:\n  - secret\n"), 0600))
+
+	options := &Options{Sources: []string{"registeredsource"}, Timeout: 10, MaxEnumerationTime: 1}
+	options.loadProvidersFrom(providerConfig)
+	runner := &Runner{options: options, rateLimit: &subscraping.CustomRateLimit{Custom: mapsutil.SyncLockMap[string, uint]{Map: make(map[string]uint)}}}
+	runner.initializePassiveEngine()
+
+	var hosts []string
+	for subdomain, err := range runner.Enumerate(context.Background(), "example.com").Results() {
+		require.NoError(t, err)
+		hosts = append(hosts, subdomain.Host)
+	}
+	require.Equal(t, []string{"secret.example.com"}, hosts, "the keys of registered sources are read from the provider config")
+}
@@ -0,0 +1,575 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/rs/xid"
+
+	"github.com/projectdiscovery/gologger"
+	contextutil "github.com/projectdiscovery/utils/context"
+
+	"github.com/projectdiscovery/subfinder/v2/pkg/metrics"
+	"github.com/projectdiscovery/subfinder/v2/pkg/passive"
+	"github.com/projectdiscovery/subfinder/v2/pkg/subscraping"
+)
+
+// serverShutdownTimeout is the time given to the open connections to
+// complete once the API server is stopped
+const serverShutdownTimeout = 10 * time.Second
+
+// serverReadHeaderTimeout is the time given to the clients to send the headers of a request
+const serverReadHeaderTimeout = 10 * time.Second
+
+// maxJobRequestSize is the largest body of a job submission
+const maxJobRequestSize = 1 << 20
+
+// finishedJobTTL is how long the jobs are kept once done, along with their results
+const finishedJobTTL = time.Hour
+
+const (
+	jobRunning   = "running"
+	jobCompleted = "completed"
+	jobCancelled = "cancelled"
+	jobFailed    = "failed"
+)
+
+// jobRequest is the body of a job submission, its fields mirroring the
+// options of the command line. The zero values keep the server options.
+type jobRequest struct {
+	Domains        []string `json:"domains"`
+	Sources        []string `json:"sources,omitempty"`
+	ExcludeSources []string `json:"exclude_sources,omitempty"`
+	All            bool     `json:"all,omitempty"`
+	Recursive      bool     `json:"recursive,omitempty"`
+	RecursionDepth int      `json:"recursion_depth,omitempty"`
+	Active         bool     `json:"active,omitempty"`
+	IP             bool     `json:"ip,omitempty"`
+	CollectSources bool     `json:"collect_sources,omitempty"`
+	Match          []string `json:"match,omitempty"`
+	Filter         []string `json:"filter,omitempty"`
+	Timeout        int      `json:"timeout,omitempty"`
+	MaxTime        int      `json:"max_time,omitempty"`
+}
+
+// jsonJob is the status of a job returned by the API
+type jsonJob struct {
+	ID         string     `json:"id"`
+	Status     string     `json:"status"`
+	Domains    []string   `json:"domains"`
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Results    int        `json:"results"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// jsonStatistics is the statistics of a source returned by the API
+type jsonStatistics struct {
+	TimeTaken   string `json:"time_taken"`
+	Results     int    `json:"results"`
+	Errors      int    `json:"errors"`
+	Retries     int    `json:"retries,omitempty"`
+	Skipped     bool   `json:"skipped,omitempty"`
+	CacheHits   int    `json:"cache_hits,omitempty"`
+	CacheMisses int    `json:"cache_misses,omitempty"`
+}
+
+// job is an enumeration submitted to the API server. The results are
+// written to the job as JSON lines by its own runner.
+type job struct {
+	id         string
+	domains    []string
+	createdAt  time.Time
+	runner     *Runner
+	cancel     context.CancelFunc
+	mu         sync.Mutex
+	status     string
+	finishedAt time.Time
+	err        error
+	results    [][]byte
+	partial    []byte
+	statistics map[string]subscraping.Statistics
+	// updated is closed and replaced whenever the job changes
+	updated chan struct{}
+}
+
+// Write stores the complete lines written by the runner as results
+func (j *job) Write(p []byte) (int, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.partial = append(j.partial, p...)
+	for {
+		i := bytes.IndexByte(j.partial, '\n')
+		if i < 0 {
+			break
+		}
+		if i > 0 {
+			j.results = append(j.results, bytes.Clone(j.partial[:i]))
+		}
+		j.partial = j.partial[i+1:]
+	}
+	j.notify()
+	return len(p), nil
+}
+
+// finish records the outcome of the job along with the final source statistics
+func (j *job) finish(err error) {
+	statistics := j.runner.GetStatistics()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	switch {
+	case errors.Is(err, ErrInterrupted):
+		j.status = jobCancelled
+	case err != nil:
+		j.status = jobFailed
+		j.err = err
+	default:
+		j.status = jobCompleted
+	}
+	j.finishedAt = time.Now()
+	j.statistics = statistics
+	j.notify()
+}
+
+// notify wakes up the clients following the job, it must be called with the mutex held
+func (j *job) notify() {
+	close(j.updated)
+	j.updated = make(chan struct{})
+}
+
+func (j *job) toJSON() jsonJob {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	result := jsonJob{ID: j.id, Status: j.status, Domains: j.domains, CreatedAt: j.createdAt, Results: len(j.results)}
+	if !j.finishedAt.IsZero() {
+		finishedAt := j.finishedAt
+		result.FinishedAt = &finishedAt
+	}
+	if j.err != nil {
+		result.Error = j.err.Error()
+	}
+	return result
+}
+
+// next returns the results following the first ones already read,
+// whether the job is done and a channel closed on the next change
+func (j *job) next(read int) ([][]byte, bool, <-chan struct{}) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.results[read:], j.status != jobRunning, j.updated
+}
+
+// apiServer runs the enumeration jobs submitted over HTTP. The jobs share
+// the provider configuration, the resolver, the response cache and the
+// per-source rate limiters of the runner the server was started from.
+// The jobs done for longer than the TTL are removed.
+type apiServer struct {
+	runner *Runner
+	ctx    context.Context
+	jobTTL time.Duration
+	wg     sync.WaitGroup
+	mu     sync.Mutex
+	jobs   map[string]*job
+}
+
+// Serve runs the HTTP API server on the given address until the context is done,
+// cancelling the running jobs on the way out. The server has no authentication,
+// anyone reaching the address can submit jobs with the keys of the provider config.
+func (r *Runner) Serve(ctx context.Context, addr string) error {
+	// The rate limiters hold a bucket for every source so that
+	// the jobs share them whichever sources they use
+	multiRateLimiter, err := passive.New(nil, nil, true, false).BuildMultiRateLimiter(ctx, r.options.RateLimit, r.rateLimit)
+	if err != nil {
+		return err
+	}
+	defer multiRateLimiter.Stop()
+	r.sharedRateLimiter = multiRateLimiter
+
+	server := r.newAPIServer(ctx)
+	httpServer := &http.Server{Addr: addr, Handler: server.handler(), ReadHeaderTimeout: serverReadHeaderTimeout}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), serverShutdownTimeout)
+		defer cancel()
+		_ = httpServer.Shutdown(shutdownCtx)
+	}()
+
+	gologger.Info().Msgf("Listening for enumeration jobs on %s\n", addr)
+	err = httpServer.ListenAndServe()
+	server.wg.Wait()
+	// The rate limits learned by the jobs are only saved once the server stopped
+	r.writeMetricsFile()
+	r.saveRateLimits()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+func (r *Runner) newAPIServer(ctx context.Context) *apiServer {
+	return &apiServer{runner: r, ctx: ctx, jobTTL: finishedJobTTL, jobs: make(map[string]*job)}
+}
+
+// evictJobs removes the jobs done for longer than the TTL, it must be called with the mutex held
+func (s *apiServer) evictJobs() {
+	for id, j := range s.jobs {
+		j.mu.Lock()
+		expired := j.status != jobRunning && time.Since(j.finishedAt) > s.jobTTL
+		j.mu.Unlock()
+		if expired {
+			delete(s.jobs, id)
+		}
+	}
+}
+
+func (s *apiServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /jobs", s.createJob)
+	mux.HandleFunc("GET /jobs", s.listJobs)
+	mux.HandleFunc("GET /jobs/{id}", s.getJob)
+	mux.HandleFunc("DELETE /jobs/{id}", s.deleteJob)
+	mux.HandleFunc("GET /jobs/{id}/results", s.getJobResults)
+	mux.HandleFunc("GET /jobs/{id}/stats", s.getJobStatistics)
+	mux.Handle("GET /metrics", metrics.Default.Handler())
+	return mux
+}
+
+func (s *apiServer) createJob(w http.ResponseWriter, req *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, req.Body, maxJobRequestSize))
+	if err != nil {
+		status := http.StatusBadRequest
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		writeJSONError(w, status, fmt.Errorf("invalid job: %w", err))
+		return
+	}
+	var request jobRequest
+	decoder := jsoniter.NewDecoder(bytes.NewReader(body))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&request); err != nil {
+		writeJSONError(w, http.StatusBadRequest, fmt.Errorf("invalid job: %w", err))
+		return
+	}
+
+	j := &job{id: xid.New().String(), createdAt: time.Now(), status: jobRunning, updated: make(chan struct{})}
+	runner, err := s.runner.newJobRunner(&request, j)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+	j.runner = runner
+	j.domains = runner.options.Domain
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	j.cancel = cancel
+	ctx, _ = contextutil.WithValues(ctx, contextutil.ContextArg("All"), contextutil.ContextArg(strconv.FormatBool(request.All)))
+
+	s.mu.Lock()
+	s.evictJobs()
+	s.jobs[j.id] = j
+	s.mu.Unlock()
+
+	gologger.Info().Msgf("Starting job %s for %s\n", j.id, strings.Join(j.domains, ", "))
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer cancel()
+
+		j.finish(runner.RunEnumerationWithCtx(ctx))
+	}()
+
+	writeJSON(w, http.StatusCreated, j.toJSON())
+}
+
+func (s *apiServer) listJobs(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	s.evictJobs()
+	jobs := make([]jsonJob, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		jobs = append(jobs, j.toJSON())
+	}
+	s.mu.Unlock()
+
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.Before(jobs[j].CreatedAt)
+	})
+	writeJSON(w, http.StatusOK, jobs)
+}
+
+func (s *apiServer) getJob(w http.ResponseWriter, req *http.Request) {
+	j, ok := s.job(w, req)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, j.toJSON())
+}
+
+// deleteJob cancels a running job, the partial results being kept,
+// and removes a job which is done
+func (s *apiServer) deleteJob(w http.ResponseWriter, req *http.Request) {
+	j, ok := s.job(w, req)
+	if !ok {
+		return
+	}
+
+	if _, done, _ := j.next(0); !done {
+		j.cancel()
+		writeJSON(w, http.StatusAccepted, j.toJSON())
+		return
+	}
+
+	s.mu.Lock()
+	delete(s.jobs, j.id)
+	s.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getJobResults writes the results of a job as JSON lines, or as server-sent
+// events when requested, following the job until it is done
+func (s *apiServer) getJobResults(w http.ResponseWriter, req *http.Request) {
+	j, ok := s.job(w, req)
+	if !ok {
+		return
+	}
+
+	events := strings.Contains(req.Header.Get("Accept"), "text/event-stream")
+	if events {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	}
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+
+	read := 0
+	for {
+		results, done, updated := j.next(read)
+		for _, result := range results {
+			var err error
+			if events {
+				_, err = fmt.Fprintf(w, "event: result\ndata: %s\n\n", result)
+			} else {
+				_, err = fmt.Fprintf(w, "%s\n", result)
+			}
+			if err != nil {
+				return
+			}
+		}
+		read += len(results)
+
+		// The results are read along with the status, so none is left once done
+		if done {
+			if events {
+				status := j.toJSON()
+				data, _ := jsoniter.Marshal(status)
+				_, _ = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", status.Status, data)
+			}
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		select {
+		case <-req.Context().Done():
+			return
+		case <-updated:
+		}
+	}
+}
+
+// getJobStatistics writes the statistics of the sources used by a job,
+// the ones of a running job covering the sources done so far
+func (s *apiServer) getJobStatistics(w http.ResponseWriter, req *http.Request) {
+	j, ok := s.job(w, req)
+	if !ok {
+		return
+	}
+
+	j.mu.Lock()
+	statistics := j.statistics
+	j.mu.Unlock()
+	if statistics == nil {
+		statistics = j.runner.GetStatistics()
+	}
+
+	result := make(map[string]jsonStatistics, len(statistics))
+	for source, stat := range statistics {
+		result[source] = jsonStatistics{
+			TimeTaken:   stat.TimeTaken.Round(time.Millisecond).String(),
+			Results:     stat.Results,
+			Errors:      stat.Errors,
+			Retries:     stat.Retries,
+			Skipped:     stat.Skipped,
+			CacheHits:   stat.CacheHits,
+			CacheMisses: stat.CacheMisses,
+		}
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// job returns the job of the request, writing a not found error when it does not exist
+func (s *apiServer) job(w http.ResponseWriter, req *http.Request) (*job, bool) {
+	s.mu.Lock()
+	s.evictJobs()
+	j, ok := s.jobs[req.PathValue("id")]
+	s.mu.Unlock()
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, errors.New("job not found"))
+	}
+	return j, ok
+}
+
+// newJobRunner creates the runner of a job from the options of the server
+// runner, the results being written to the job as JSON lines
+func (r *Runner) newJobRunner(request *jobRequest, j *job) (*Runner, error) {
+	if len(request.Domains) == 0 {
+		return nil, errors.New("no domains provided")
+	}
+	// The source names are case insensitive as on the command line
+	request.Sources = lowercase(request.Sources)
+	request.ExcludeSources = lowercase(request.ExcludeSources)
+	if err := validateJobSources(request); err != nil {
+		return nil, err
+	}
+
+	options := *r.options
+	options.Serve = ""
+	options.Domain = request.Domains
+	options.DomainsFile = ""
+	options.Stdin = false
+	options.Sources = request.Sources
+	options.ExcludeSources = request.ExcludeSources
+	options.All = request.All
+	options.OnlyRecursive = request.Recursive
+	options.RemoveWildcard = request.Active
+	options.HostIP = request.IP
+	options.CaptureSources = request.CollectSources
+	options.Match = request.Match
+	options.Filter = request.Filter
+	options.matchRegexes, options.filterRegexes = nil, nil
+	if request.RecursionDepth > 0 {
+		options.RecursionDepth = request.RecursionDepth
+	}
+	if request.Timeout > 0 {
+		options.Timeout = request.Timeout
+	}
+	if request.MaxTime > 0 {
+		options.MaxEnumerationTime = request.MaxTime
+	}
+
+	// The results are streamed to the job, nothing being written to the filesystem
+	options.JSON = true
+	options.Stream = true
+	options.Output = j
+	options.OutputFile = ""
+	options.OutputDirectory = ""
+	options.Resume = ""
+	options.NewOnly = false
+	options.GoneOutput = ""
+	options.MinScore = ""
+	options.Statistics = false
+	options.MetricsFile = ""
+	// The shared adaptive limiter is saved once by the server
+	options.SaveRateLimits = false
+
+	if err := options.validateOptions(); err != nil {
+		return nil, err
+	}
+
+	runner := &Runner{
+		options:           &options,
+		resolverClient:    r.resolverClient,
+		rateLimit:         r.rateLimit,
+		store:             r.store,
+		responseCache:     r.responseCache,
+		wordlist:          r.wordlist,
+		sharedRateLimiter: r.sharedRateLimiter,
+		retrier:           r.retrier,
+		adaptiveLimiter:   r.adaptiveLimiter,
+		keyManager:        r.keyManager,
+	}
+	runner.initializePassiveEngine()
+	return runner, nil
+}
+
+// validateJobSources checks that the sources of a job exist and that
+// at least one of them is left once the excluded ones are removed
+func validateJobSources(request *jobRequest) error {
+	selected := make(map[string]struct{})
+	for _, source := range request.Sources {
+		if passive.NameSourceMap[source] == nil {
+			return fmt.Errorf("invalid source %s", source)
+		}
+		selected[source] = struct{}{}
+	}
+	for _, source := range request.ExcludeSources {
+		if passive.NameSourceMap[source] == nil {
+			return fmt.Errorf("invalid source %s", source)
+		}
+	}
+
+	for _, source := range passive.AllSources {
+		name := source.Name()
+		_, ok := selected[name]
+		switch {
+		case request.All:
+		case len(request.Sources) > 0 && !ok:
+			continue
+		case len(request.Sources) == 0 && !source.IsDefault():
+			continue
+		}
+		if request.Recursive && !source.HasRecursiveSupport() {
+			continue
+		}
+		excluded := false
+		for _, exclude := range request.ExcludeSources {
+			if exclude == name {
+				excluded = true
+				break
+			}
+		}
+		if !excluded {
+			return nil
+		}
+	}
+	return errors.New("no sources selected for this job")
+}
+
+func lowercase(values []string) []string {
+	var lowered []string
+	for _, value := range values {
+		lowered = append(lowered, strings.ToLower(value))
+	}
+	return lowered
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := jsoniter.NewEncoder(w).Encode(v); err != nil {
+		gologger.Warning().Msgf("Could not write response: %s\n", err)
+	}
+}
+
+func writeJSONError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
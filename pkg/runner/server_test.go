@@ -0,0 +1,177 @@
+package runner
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/projectdiscovery/subfinder/v2/pkg/subscraping"
+)
+
+func TestJobWrite(t *testing.T) {
+	j := &job{status: jobRunning, updated: make(chan struct{})}
+	updated := j.updated
+
+	_, err := j.Write([]byte(`{"host":"www.example.com"}` + "\n" + `{"host":"dev.`))
+	require.NoError(t, err)
+	results, done, _ := j.next(0)
+	require.False(t, done)
+	require.Equal(t, [][]byte{[]byte(`{"host":"www.example.com"}`)}, results, "only complete lines are results")
+
+	select {
+	case <-updated:
+	default:
+		require.Fail(t, "the followers are notified of the write")
+	}
+
+	_, err = j.Write([]byte(`example.com"}` + "\n"))
+	require.NoError(t, err)
+	results, _, _ = j.next(1)
+	require.Equal(t, [][]byte{[]byte(`{"host":"dev.example.com"}`)}, results)
+}
+
+func TestNewJobRunner(t *testing.T) {
+	runner := &Runner{options: &Options{Threads: 10, Timeout: 30, OutputFile: "out.txt", Statistics: true, Serve: ":8080", AdaptiveRateLimit: true, SaveRateLimits: true}}
+	j := &job{}
+
+	jobRunner, err := runner.newJobRunner(&jobRequest{Domains: []string{"example.com"}, Sources: []string{"crtsh"}, Timeout: 5}, j)
+	require.NoError(t, err)
+	require.Equal(t, []string{"example.com"}, []string(jobRunner.options.Domain))
+	require.Equal(t, 5, jobRunner.options.Timeout)
+	require.True(t, jobRunner.options.JSON)
+	require.True(t, jobRunner.options.Stream)
+	require.Empty(t, jobRunner.options.OutputFile, "the jobs do not write to the filesystem")
+	require.Equal(t, j, jobRunner.options.Output)
+	require.Equal(t, ":8080", runner.options.Serve, "the server options are left untouched")
+	require.False(t, jobRunner.options.SaveRateLimits, "the rate limits are saved by the server")
+	require.Contains(t, jobRunner.passiveAgent.GetStatistics(), "crtsh")
+
+	jobRunner, err = runner.newJobRunner(&jobRequest{Domains: []string{"example.com"}, Sources: []string{"Crtsh", "HackerTarget"}, ExcludeSources: []string{"HACKERTARGET"}}, j)
+	require.NoError(t, err, "the source names are case insensitive")
+	require.Equal(t, []string{"crtsh", "hackertarget"}, []string(jobRunner.options.Sources))
+	require.Equal(t, []string{"hackertarget"}, []string(jobRunner.options.ExcludeSources))
+	require.Contains(t, jobRunner.passiveAgent.GetStatistics(), "crtsh")
+	require.NotContains(t, jobRunner.passiveAgent.GetStatistics(), "hackertarget")
+
+	_, err = runner.newJobRunner(&jobRequest{}, j)
+	require.EqualError(t, err, "no domains provided")
+	_, err = runner.newJobRunner(&jobRequest{Domains: []string{"example.com"}, Sources: []string{"unknown"}}, j)
+	require.EqualError(t, err, "invalid source unknown")
+	_, err = runner.newJobRunner(&jobRequest{Domains: []string{"example.com"}, Sources: []string{"crtsh"}, ExcludeSources: []string{"crtsh"}}, j)
+	require.EqualError(t, err, "no sources selected for this job")
+	_, err = runner.newJobRunner(&jobRequest{Domains: []string{"example.com"}, IP: true}, j)
+	require.Error(t, err, "the job options are validated")
+}
+
+func TestAPIServer(t *testing.T) {
+	runner := &Runner{options: &Options{Threads: 10, Timeout: 30}}
+	server := runner.newAPIServer(context.Background())
+	ts := httptest.NewServer(server.handler())
+	defer ts.Close()
+
+	j := &job{id: "done", domains: []string{"example.com"}, createdAt: time.Now(), status: jobRunning, updated: make(chan struct{})}
+	_, err := j.Write([]byte("{\"host\":\"www.example.com\"}\n{\"host\":\"dev.example.com\"}\n"))
+	require.NoError(t, err)
+	j.status = jobCompleted
+	j.finishedAt = time.Now()
+	j.statistics = map[string]subscraping.Statistics{"crtsh": {Results: 2, TimeTaken: time.Second}}
+	server.jobs[j.id] = j
+
+	get := func(path, accept string) (int, string) {
+		req, err := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+		require.NoError(t, err)
+		req.Header.Set("Accept", accept)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(body)
+	}
+
+	status, body := get("/jobs/done", "")
+	require.Equal(t, http.StatusOK, status)
+	require.Contains(t, body, `"status":"completed"`)
+	require.Contains(t, body, `"results":2`)
+
+	status, body = get("/jobs/done/results", "")
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "{\"host\":\"www.example.com\"}\n{\"host\":\"dev.example.com\"}\n", body)
+
+	_, body = get("/jobs/done/results", "text/event-stream")
+	require.True(t, strings.HasPrefix(body, "event: result\ndata: {\"host\":\"www.example.com\"}\n\n"))
+	require.Contains(t, body, "event: completed\n")
+
+	status, body = get("/jobs/done/stats", "")
+	require.Equal(t, http.StatusOK, status)
+	require.JSONEq(t, `{"crtsh":{"time_taken":"1s","results":2,"errors":0}}`, body)
+
+	status, _ = get("/jobs/unknown", "")
+	require.Equal(t, http.StatusNotFound, status)
+
+	post := func(body string) (int, string) {
+		resp, err := http.Post(ts.URL+"/jobs", "application/json", strings.NewReader(body))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(data)
+	}
+
+	status, _ = post(`{"domains":[]}`)
+	require.Equal(t, http.StatusBadRequest, status)
+	status, body = post(`{"domains":["example.com"],"source":["crtsh"]}`)
+	require.Equal(t, http.StatusBadRequest, status, "the unknown fields are rejected")
+	require.Contains(t, body, "source")
+	status, _ = post(`{"domains":["` + strings.Repeat("a", maxJobRequestSize) + `.com"]}`)
+	require.Equal(t, http.StatusRequestEntityTooLarge, status)
+
+	req, err := http.NewRequest(http.MethodDelete, ts.URL+"/jobs/done", nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	require.NotContains(t, server.jobs, "done", "a finished job is removed")
+
+	expired := &job{id: "expired", status: jobCompleted, finishedAt: time.Now().Add(-2 * server.jobTTL), updated: make(chan struct{})}
+	running := &job{id: "running", status: jobRunning, createdAt: time.Now().Add(-2 * server.jobTTL), updated: make(chan struct{})}
+	server.jobs[expired.id] = expired
+	server.jobs[running.id] = running
+	status, _ = get("/jobs/expired", "")
+	require.Equal(t, http.StatusNotFound, status, "the jobs done for longer than the TTL are removed")
+	status, _ = get("/jobs/running", "")
+	require.Equal(t, http.StatusOK, status, "the running jobs are kept")
+}
+
+func TestAPIServerFollowResults(t *testing.T) {
+	runner := &Runner{options: &Options{}}
+	server := runner.newAPIServer(context.Background())
+	ts := httptest.NewServer(server.handler())
+	defer ts.Close()
+
+	j := &job{id: "running", status: jobRunning, updated: make(chan struct{})}
+	server.jobs[j.id] = j
+
+	resp, err := http.Get(ts.URL + "/jobs/running/results")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	go func() {
+		_, _ = j.Write([]byte("{\"host\":\"www.example.com\"}\n"))
+		j.mu.Lock()
+		j.status = jobCompleted
+		j.notify()
+		j.mu.Unlock()
+	}()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "{\"host\":\"www.example.com\"}\n", string(body), "the results are followed until the job is done")
+}
@@ -11,7 +11,7 @@ import (
 	"golang.org/x/exp/maps"
 )
 
-func printStatistics(stats map[string]subscraping.Statistics) {
+func printStatistics(stats map[string]subscraping.Statistics, showCache bool) {
 
 	sources := maps.Keys(stats)
 	sort.Strings(sources)
@@ -23,13 +23,19 @@ func printStatistics(stats map[string]subscraping.Statistics) {
 		sourceStats := stats[source]
 		if sourceStats.Skipped {
 			skipped = append(skipped, fmt.Sprintf(" %s", source))
+		} else if showCache {
+			lines = append(lines, fmt.Sprintf(" %-20s %-10s %10d %10d %10d %10d %10d", source, sourceStats.TimeTaken.Round(time.Millisecond).String(), sourceStats.Results, sourceStats.Errors, sourceStats.Retries, sourceStats.CacheHits, sourceStats.CacheMisses))
 		} else {
-			lines = append(lines, fmt.Sprintf(" %-20s %-10s %10d %10d", source, sourceStats.TimeTaken.Round(time.Millisecond).String(), sourceStats.Results, sourceStats.Errors))
+			lines = append(lines, fmt.Sprintf(" %-20s %-10s %10d %10d %10d", source, sourceStats.TimeTaken.Round(time.Millisecond).String(), sourceStats.Results, sourceStats.Errors, sourceStats.Retries))
 		}
 	}
 
-	if len(lines) > 0 {
-		gologger.Print().Msgf("\n Source               Duration      Results     Errors\n%s\n", strings.Repeat("─", 56))
+	if len(lines) > 0 && showCache {
+		gologger.Print().Msgf("\n Source               Duration      Results     Errors    Retries Cache hits Cache miss\n%s\n", strings.Repeat("─", 87))
+		gologger.Print().Msg(strings.Join(lines, "\n"))
+		gologger.Print().Msgf("\n")
+	} else if len(lines) > 0 {
+		gologger.Print().Msgf("\n Source               Duration      Results     Errors    Retries\n%s\n", strings.Repeat("─", 67))
 		gologger.Print().Msg(strings.Join(lines, "\n"))
 		gologger.Print().Msgf("\n")
 	}
@@ -41,6 +47,37 @@ func printStatistics(stats map[string]subscraping.Statistics) {
 	}
 }
 
+// printKeyUsage prints the usage of the API keys of the sources during the run
+func printKeyUsage(usage []subscraping.KeyUsage) {
+	if len(usage) == 0 {
+		return
+	}
+	lines := make([]string, 0, len(usage))
+	for _, key := range usage {
+		lines = append(lines, fmt.Sprintf(" %-20s %-10s %10d %10d %10s", key.Source, key.Key, key.Requests, key.Failures, key.Status))
+	}
+	gologger.Print().Msgf("\n%s\n%s\n", fmt.Sprintf(" %-20s %-10s %10s %10s %10s", "Source", "Key", "Requests", "Failures", "Status"), strings.Repeat("─", 67))
+	gologger.Print().Msg(strings.Join(lines, "\n"))
+	gologger.Print().Msgf("\n")
+}
+
+// GetStatistics returns the statistics of every source accumulated since the
+// runner was created, including the response cache usage
 func (r *Runner) GetStatistics() map[string]subscraping.Statistics {
-	return r.passiveAgent.GetStatistics()
+	return r.withCacheStatistics(r.passiveAgent.GetStatistics())
+}
+
+// withCacheStatistics adds the response cache usage to the statistics of the sources
+func (r *Runner) withCacheStatistics(statistics map[string]subscraping.Statistics) map[string]subscraping.Statistics {
+	if r.responseCache == nil {
+		return statistics
+	}
+	for source, cacheStatistics := range r.responseCache.Statistics() {
+		if stat, ok := statistics[source]; ok {
+			stat.CacheHits = cacheStatistics.Hits
+			stat.CacheMisses = cacheStatistics.Misses
+			statistics[source] = stat
+		}
+	}
+	return statistics
 }
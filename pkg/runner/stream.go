@@ -0,0 +1,119 @@
+package runner
+
+import (
+	"io"
+
+	"github.com/projectdiscovery/gologger"
+
+	"github.com/projectdiscovery/subfinder/v2/pkg/resolve"
+)
+
+// hostStreamer writes the hosts of a domain to the outputs as soon as
+// they are deduplicated, or resolved in active mode, instead of at the end
+// of the enumeration.
+type hostStreamer struct {
+	runner       *Runner
+	domain       string
+	writers      []io.Writer
+	outputWriter *OutputWriter
+	hosts        *hostStore
+	streamed     map[string]struct{}
+	err          error
+}
+
+func (r *Runner) newHostStreamer(domain string, writers []io.Writer, hosts *hostStore) *hostStreamer {
+	return &hostStreamer{
+		runner:       r,
+		domain:       domain,
+		writers:      writers,
+		hosts:        hosts,
+		outputWriter: NewOutputWriter(r.options.JSON),
+		streamed:     make(map[string]struct{}),
+	}
+}
+
+// writeHost writes a host found by the passive enumeration
+func (s *hostStreamer) writeHost(entry resolve.HostEntry) {
+	s.runner.outputMutex.Lock()
+	defer s.runner.outputMutex.Unlock()
+
+	s.write(entry.Host, func(writer io.Writer) error {
+		return s.outputWriter.WriteHost(s.domain, map[string]resolve.HostEntry{entry.Host: entry}, writer)
+	})
+}
+
+// writeResult writes a host resolved in active mode
+func (s *hostStreamer) writeResult(result resolve.Result) {
+	s.runner.outputMutex.Lock()
+	defer s.runner.outputMutex.Unlock()
+
+	s.writeResultLocked(result)
+}
+
+func (s *hostStreamer) writeResultLocked(result resolve.Result) {
+	s.write(result.Host, func(writer io.Writer) error {
+		results := map[string]resolve.Result{result.Host: result}
+		if s.runner.options.HostIP {
+			return s.outputWriter.WriteHostIP(s.domain, results, writer)
+		}
+		return s.outputWriter.WriteHostNoWildcard(s.domain, results, writer)
+	})
+}
+
+// write writes a host to every output, the writes of the files not being
+// buffered the host is on disk as soon as the write returns
+func (s *hostStreamer) write(host string, write func(io.Writer) error) {
+	// The host store only reports new hosts once they are on disk, so
+	// they are not tracked anymore to keep the memory usage bounded
+	if s.hosts.onDisk() {
+		s.streamed = nil
+	} else {
+		if _, ok := s.streamed[host]; ok {
+			return
+		}
+		s.streamed[host] = struct{}{}
+	}
+
+	for _, writer := range s.writers {
+		if err := write(writer); err != nil {
+			gologger.Error().Msgf("Could not write %s for %s: %s\n", host, s.domain, err)
+			if s.err == nil {
+				s.err = err
+			}
+		}
+	}
+}
+
+// finish writes the hosts which were not streamed yet, e.g. the ones found by
+// the permutation stage, followed by the merged sources of every host when
+// requested. It must be called with the output mutex held.
+func (s *hostStreamer) finish(uniqueMap map[string]resolve.HostEntry, foundResults map[string]resolve.Result, sourceMap map[string]map[string]struct{}, provenance provenanceMap) error {
+	if s.runner.options.RemoveWildcard {
+		for _, result := range foundResults {
+			s.writeResultLocked(result)
+		}
+		return s.err
+	}
+
+	for host, entry := range uniqueMap {
+		s.write(host, func(writer io.Writer) error {
+			return s.outputWriter.WriteHost(s.domain, map[string]resolve.HostEntry{host: entry}, writer)
+		})
+	}
+
+	// The sources of a host are only all known at the end of the enumeration
+	if s.runner.options.CaptureSources || s.runner.options.Provenance {
+		for _, writer := range s.writers {
+			var err error
+			if s.runner.options.Provenance {
+				err = s.outputWriter.WriteSourceProvenanceHost(s.domain, sourceMap, provenance, uniqueMap, writer)
+			} else {
+				err = s.outputWriter.WriteSourceHostEntries(s.domain, sourceMap, uniqueMap, writer)
+			}
+			if err != nil && s.err == nil {
+				s.err = err
+			}
+		}
+	}
+	return s.err
+}
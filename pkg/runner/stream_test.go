@@ -0,0 +1,42 @@
+package runner
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/projectdiscovery/subfinder/v2/pkg/resolve"
+)
+
+func TestHostStreamer(t *testing.T) {
+	runner := &Runner{options: &Options{Stream: true, CaptureSources: true}}
+	var buf bytes.Buffer
+	hosts, err := newHostStore(0, false)
+	require.NoError(t, err)
+	streamer := runner.newHostStreamer("example.com", []io.Writer{&buf}, hosts)
+
+	www := resolve.HostEntry{Domain: "example.com", Host: "www.example.com", Source: "crtsh"}
+	streamer.writeHost(www)
+	streamer.writeHost(www)
+	require.Equal(t, "www.example.com\n", buf.String(), "hosts are written once as soon as they are found")
+
+	uniqueMap := map[string]resolve.HostEntry{
+		"www.example.com": www,
+		"dev.example.com": {Domain: "example.com", Host: "dev.example.com", Source: bruteforceSource},
+	}
+	sourceMap := map[string]map[string]struct{}{
+		"www.example.com": {"crtsh": {}},
+		"dev.example.com": {bruteforceSource: {}},
+	}
+	runner.outputMutex.Lock()
+	err = streamer.finish(uniqueMap, nil, sourceMap, nil)
+	runner.outputMutex.Unlock()
+	require.NoError(t, err)
+
+	require.True(t, strings.HasPrefix(buf.String(), "www.example.com\ndev.example.com\n"), "the remaining hosts are written before the summary")
+	require.Contains(t, buf.String(), "www.example.com,[crtsh]\n", "the merged sources are written at the end")
+	require.Contains(t, buf.String(), "dev.example.com,[bruteforce]\n")
+}
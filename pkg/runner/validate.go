@@ -4,12 +4,15 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/projectdiscovery/gologger"
 	"github.com/projectdiscovery/gologger/formatter"
 	"github.com/projectdiscovery/gologger/levels"
 	"github.com/projectdiscovery/subfinder/v2/pkg/passive"
+	"github.com/projectdiscovery/subfinder/v2/pkg/resolve"
 	mapsutil "github.com/projectdiscovery/utils/maps"
 	sliceutil "github.com/projectdiscovery/utils/slice"
 )
@@ -18,7 +21,9 @@ import (
 func (options *Options) validateOptions() error {
 	// Check if domain, list of domains, or stdin info was provided.
 	// If none was provided, then return.
-	if len(options.Domain) == 0 && options.DomainsFile == "" && !options.Stdin {
+	// The domains of the API server are given with each job.
+	// No domain is needed to verify the keys.
+	if len(options.Domain) == 0 && options.DomainsFile == "" && !options.Stdin && options.Serve == "" && !options.VerifyKeys {
 		return errors.New("no input list provided")
 	}
 
@@ -34,12 +39,53 @@ func (options *Options) validateOptions() error {
 	if options.Timeout == 0 {
 		return errors.New("timeout cannot be zero")
 	}
+	if options.RecursionDepth < 0 {
+		return errors.New("recursion depth cannot be negative")
+	}
+	if options.DomainConcurrency < 0 {
+		return errors.New("domain concurrency cannot be negative")
+	}
 
 	// Always remove wildcard with hostip
 	if options.HostIP && !options.RemoveWildcard {
 		return errors.New("hostip flag must be used with RemoveWildcard option")
 	}
 
+	// These features need all the subdomains of a domain in memory
+	if options.DedupeDisk && (options.Wordlist != "" || options.Permute || options.Store || options.NewOnly || options.GoneOutput != "" || options.Provenance) {
+		return errors.New("dedupe-disk flag cannot be used with permutation, history or provenance options")
+	}
+	if options.DedupeThreshold < 0 {
+		return errors.New("dedupe threshold cannot be negative")
+	}
+
+	// Takeover candidates are detected during the active resolution
+	if options.Takeover && !options.RemoveWildcard {
+		return errors.New("takeover flag must be used with active option")
+	}
+	if options.TakeoverFingerprints != "" && !options.Takeover {
+		return errors.New("takeover-fingerprints flag must be used with takeover option")
+	}
+
+	// The resolved hosts are written without their sources
+	if options.Provenance && options.RemoveWildcard {
+		return errors.New("provenance flag cannot be used with active option")
+	}
+
+	// Streamed hosts are written before the enumeration ends, so they cannot be filtered afterwards
+	if options.Stream && (options.NewOnly || options.MinScore != "") {
+		return errors.New("stream flag cannot be used with new-only or min-score options")
+	}
+
+	if len(options.RecordTypes) > 0 {
+		if !options.CollectRecords {
+			return errors.New("record-types flag must be used with dns-records option")
+		}
+		if _, err := resolve.QuestionTypes(options.RecordTypes); err != nil {
+			return err
+		}
+	}
+
 	if options.Match != nil {
 		options.matchRegexes = make([]*regexp.Regexp, len(options.Match))
 		var err error
@@ -65,6 +111,72 @@ func (options *Options) validateOptions() error {
 			return fmt.Errorf("invalid source %s specified in -rls flag", source)
 		}
 	}
+
+	options.cacheTTLs = make(map[string]time.Duration, len(options.CacheTTLs))
+	for _, sourceTTL := range options.CacheTTLs {
+		source, ttl, ok := strings.Cut(sourceTTL, "=")
+		if !ok {
+			return fmt.Errorf("invalid value %s specified in -cache-ttls flag", sourceTTL)
+		}
+		if !sliceutil.Contains(sources, source) {
+			return fmt.Errorf("invalid source %s specified in -cache-ttls flag", source)
+		}
+		duration, err := time.ParseDuration(ttl)
+		if err != nil || duration < 0 {
+			return fmt.Errorf("invalid duration %s specified in -cache-ttls flag", ttl)
+		}
+		options.cacheTTLs[source] = duration
+	}
+
+	if options.Retries < 0 {
+		return errors.New("retries cannot be negative")
+	}
+	if options.CircuitBreaker < 0 {
+		return errors.New("circuit breaker threshold cannot be negative")
+	}
+	options.sourceRetries = make(map[string]int, len(options.SourceRetries))
+	for _, sourceRetries := range options.SourceRetries {
+		source, value, ok := strings.Cut(sourceRetries, "=")
+		if !ok {
+			return fmt.Errorf("invalid value %s specified in -source-retries flag", sourceRetries)
+		}
+		if !sliceutil.Contains(sources, source) {
+			return fmt.Errorf("invalid source %s specified in -source-retries flag", source)
+		}
+		retries, err := strconv.Atoi(value)
+		if err != nil || retries < 0 {
+			return fmt.Errorf("invalid number of retries %s specified in -source-retries flag", value)
+		}
+		options.sourceRetries[source] = retries
+	}
+
+	if options.SaveRateLimits && !options.AdaptiveRateLimit {
+		return errors.New("save-rate-limits flag must be used with adaptive-rate-limit option")
+	}
+
+	options.sourceWeights = make(map[string]float64, len(options.SourceWeights))
+	for _, sourceWeight := range options.SourceWeights {
+		source, value, ok := strings.Cut(sourceWeight, "=")
+		if !ok {
+			return fmt.Errorf("invalid value %s specified in -source-weights flag", sourceWeight)
+		}
+		if !sliceutil.Contains(sources, source) && source != bruteforceSource && source != permutationSource {
+			return fmt.Errorf("invalid source %s specified in -source-weights flag", source)
+		}
+		weight, err := strconv.ParseFloat(value, 64)
+		if err != nil || weight < 0 || weight > 1 {
+			return fmt.Errorf("invalid weight %s specified in -source-weights flag", value)
+		}
+		options.sourceWeights[source] = weight
+	}
+
+	if options.MinScore != "" {
+		minScore, err := strconv.ParseFloat(options.MinScore, 64)
+		if err != nil || minScore < 0 || minScore > 1 {
+			return fmt.Errorf("invalid value %s specified in -min-score flag", options.MinScore)
+		}
+		options.minScore = minScore
+	}
 	return nil
 }
 func stripRegexString(val string) string {
@@ -0,0 +1,73 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/projectdiscovery/gologger"
+	"github.com/projectdiscovery/subfinder/v2/pkg/passive"
+	"github.com/projectdiscovery/subfinder/v2/pkg/subscraping"
+)
+
+// VerifyKeys verifies the API keys of the sources, all of them unless some were
+// selected, writing the verification of every key to the output as a table or as
+// JSON lines. An error is returned when any key is invalid or expired.
+func (r *Runner) VerifyKeys(ctx context.Context) error {
+	agent := passive.New(r.options.Sources, r.options.ExcludeSources, len(r.options.Sources) == 0, false, passive.WithAPIKeys(r.options.providerKeys))
+	verifications := agent.VerifyKeys(ctx, r.options.Proxy, r.options.Timeout)
+	if len(verifications) == 0 {
+		gologger.Warning().Msgf("No API keys are configured in %s\n", r.options.ProviderConfig)
+		return nil
+	}
+
+	var err error
+	if r.options.JSON {
+		err = writeKeyVerificationsJSON(r.options.Output, verifications)
+	} else {
+		err = writeKeyVerifications(r.options.Output, verifications)
+	}
+	if err != nil {
+		return err
+	}
+
+	var failed int
+	for _, verification := range verifications {
+		if verification.Status == subscraping.KeyInvalid || verification.Status == subscraping.KeyExpired {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of the %d API keys are invalid or expired", failed, len(verifications))
+	}
+	return nil
+}
+
+// writeKeyVerifications writes the verifications of the keys as a table
+func writeKeyVerifications(writer io.Writer, verifications []passive.KeyVerification) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, " %-20s %-10s %-10s %10s  %s\n%s\n", "Source", "Key", "Status", "Quota", "Error", strings.Repeat("─", 67))
+	for _, verification := range verifications {
+		quota := "-"
+		if verification.Remaining != nil {
+			quota = strconv.Itoa(*verification.Remaining)
+		}
+		fmt.Fprintf(&sb, " %-20s %-10s %-10s %10s  %s\n", verification.Source, verification.Key, verification.Status, quota, verification.Error)
+	}
+	_, err := io.WriteString(writer, sb.String())
+	return err
+}
+
+// writeKeyVerificationsJSON writes the verifications of the keys as JSON lines
+func writeKeyVerificationsJSON(writer io.Writer, verifications []passive.KeyVerification) error {
+	encoder := json.NewEncoder(writer)
+	for _, verification := range verifications {
+		if err := encoder.Encode(verification); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,3 @@
+// Package store implements a persistent on-disk history of the
+// subdomains found across enumeration runs.
+package store
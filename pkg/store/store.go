@@ -0,0 +1,241 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// Record is the history entry of a single host
+type Record struct {
+	Host      string    `json:"host"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	Sources   []string  `json:"sources"`
+}
+
+// Diff contains the changes between a run and the history preceding it
+type Diff struct {
+	// New contains the hosts that were never seen before
+	New map[string]struct{}
+	// Gone contains the hosts that were seen in the previous run but not in the current one
+	Gone []Record
+}
+
+// Store is a JSONL backed history of hosts, one file per root domain along with
+// a file holding the time of the last run of the domain
+type Store struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// New creates a new store rooted at the given directory
+func New(dir string) (*Store, error) {
+	if dir == "" {
+		return nil, errors.New("empty store directory")
+	}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	return &Store{dir: dir}, nil
+}
+
+func (s *Store) path(domain string) string {
+	return filepath.Join(s.dir, domain+".jsonl")
+}
+
+func (s *Store) lastRunPath(domain string) string {
+	return filepath.Join(s.dir, domain+".last-run")
+}
+
+// Records returns the history of all hosts seen for a domain sorted by host
+func (s *Store) Records(domain string) ([]Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records, err := s.load(domain)
+	if err != nil {
+		return nil, err
+	}
+	return sortedRecords(records), nil
+}
+
+// Update merges the hosts found in a run into the history of the domain and
+// returns the difference against the previous run. A host is only reported gone
+// by the first run which does not find it, even when the runs in between found nothing.
+func (s *Store) Update(domain string, hosts map[string]map[string]struct{}, seenAt time.Time) (*Diff, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records, err := s.load(domain)
+	if err != nil {
+		return nil, err
+	}
+
+	previousRun, ok, err := s.loadLastRun(domain)
+	if err != nil {
+		return nil, err
+	}
+	// The histories written before the time of the runs was recorded
+	// fall back to the time the hosts were last seen
+	if !ok {
+		for _, record := range records {
+			if record.LastSeen.After(previousRun) {
+				previousRun = record.LastSeen
+			}
+		}
+	}
+
+	diff := &Diff{New: make(map[string]struct{})}
+	for host, record := range records {
+		if _, ok := hosts[host]; !ok && record.LastSeen.Equal(previousRun) {
+			diff.Gone = append(diff.Gone, *record)
+		}
+	}
+	sort.Slice(diff.Gone, func(i, j int) bool {
+		return diff.Gone[i].Host < diff.Gone[j].Host
+	})
+
+	for host, sources := range hosts {
+		record, ok := records[host]
+		if !ok {
+			diff.New[host] = struct{}{}
+			record = &Record{Host: host, FirstSeen: seenAt}
+			records[host] = record
+		}
+		record.LastSeen = seenAt
+		record.Sources = mergeSources(record.Sources, sources)
+	}
+
+	if err := s.save(domain, records); err != nil {
+		return nil, err
+	}
+	return diff, s.saveLastRun(domain, seenAt)
+}
+
+// loadLastRun returns the time of the last run of the domain and whether it was recorded
+func (s *Store) loadLastRun(domain string) (time.Time, bool, error) {
+	var lastRun time.Time
+	data, err := os.ReadFile(s.lastRunPath(domain))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return lastRun, false, nil
+		}
+		return lastRun, false, err
+	}
+	if err := lastRun.UnmarshalText(bytes.TrimSpace(data)); err != nil {
+		return lastRun, false, err
+	}
+	return lastRun, true, nil
+}
+
+func (s *Store) saveLastRun(domain string, lastRun time.Time) error {
+	data, err := lastRun.MarshalText()
+	if err != nil {
+		return err
+	}
+	tmpFile, err := os.CreateTemp(s.dir, domain+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmpFile.Write(append(data, '\n'))
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmpFile.Name())
+		return err
+	}
+	return os.Rename(tmpFile.Name(), s.lastRunPath(domain))
+}
+
+func (s *Store) load(domain string) (map[string]*Record, error) {
+	records := make(map[string]*Record)
+
+	file, err := os.Open(s.path(domain))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return records, nil
+		}
+		return nil, err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var record Record
+		if err := jsoniter.Unmarshal(line, &record); err != nil {
+			return nil, err
+		}
+		records[record.Host] = &record
+	}
+	return records, scanner.Err()
+}
+
+func (s *Store) save(domain string, records map[string]*Record) error {
+	// Write to a temporary file first so that a crash never leaves a truncated history behind
+	tmpFile, err := os.CreateTemp(s.dir, domain+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	bufwriter := bufio.NewWriter(tmpFile)
+	encoder := jsoniter.NewEncoder(bufwriter)
+	for _, record := range sortedRecords(records) {
+		if err = encoder.Encode(&record); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = bufwriter.Flush()
+	}
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmpFile.Name())
+		return err
+	}
+	return os.Rename(tmpFile.Name(), s.path(domain))
+}
+
+func sortedRecords(records map[string]*Record) []Record {
+	result := make([]Record, 0, len(records))
+	for _, record := range records {
+		result = append(result, *record)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Host < result[j].Host
+	})
+	return result
+}
+
+func mergeSources(existing []string, sources map[string]struct{}) []string {
+	merged := make(map[string]struct{}, len(existing)+len(sources))
+	for _, source := range existing {
+		merged[source] = struct{}{}
+	}
+	for source := range sources {
+		merged[source] = struct{}{}
+	}
+	result := make([]string, 0, len(merged))
+	for source := range merged {
+		result = append(result, source)
+	}
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,66 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreUpdate(t *testing.T) {
+	s, err := New(t.TempDir())
+	require.Nil(t, err)
+
+	firstRun := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	diff, err := s.Update("example.com", map[string]map[string]struct{}{
+		"a.example.com": {"crtsh": {}},
+		"b.example.com": {"alienvault": {}},
+	}, firstRun)
+	require.Nil(t, err)
+	require.Len(t, diff.New, 2)
+	require.Empty(t, diff.Gone)
+
+	secondRun := firstRun.Add(24 * time.Hour)
+	diff, err = s.Update("example.com", map[string]map[string]struct{}{
+		"a.example.com": {"virustotal": {}},
+		"c.example.com": {"crtsh": {}},
+	}, secondRun)
+	require.Nil(t, err)
+	require.Equal(t, map[string]struct{}{"c.example.com": {}}, diff.New)
+	require.Len(t, diff.Gone, 1)
+	require.Equal(t, "b.example.com", diff.Gone[0].Host)
+
+	records, err := s.Records("example.com")
+	require.Nil(t, err)
+	require.Len(t, records, 3)
+	require.Equal(t, "a.example.com", records[0].Host)
+	require.Equal(t, firstRun, records[0].FirstSeen)
+	require.Equal(t, secondRun, records[0].LastSeen)
+	require.Equal(t, []string{"crtsh", "virustotal"}, records[0].Sources)
+
+	// hosts gone in an older run are not reported again
+	diff, err = s.Update("example.com", map[string]map[string]struct{}{
+		"a.example.com": {"crtsh": {}},
+		"c.example.com": {"crtsh": {}},
+	}, secondRun.Add(24*time.Hour))
+	require.Nil(t, err)
+	require.Empty(t, diff.New)
+	require.Empty(t, diff.Gone)
+}
+
+func TestStoreUpdateAfterEmptyRun(t *testing.T) {
+	s, err := New(t.TempDir())
+	require.Nil(t, err)
+
+	firstRun := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	_, err = s.Update("example.com", map[string]map[string]struct{}{"a.example.com": {"crtsh": {}}}, firstRun)
+	require.Nil(t, err)
+
+	diff, err := s.Update("example.com", nil, firstRun.Add(24*time.Hour))
+	require.Nil(t, err)
+	require.Len(t, diff.Gone, 1, "the hosts of the previous run are gone after an empty run")
+
+	diff, err = s.Update("example.com", nil, firstRun.Add(48*time.Hour))
+	require.Nil(t, err)
+	require.Empty(t, diff.Gone, "hosts are only reported gone once")
+}
@@ -0,0 +1,203 @@
+package subscraping
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// AdaptiveOptions configure how the adaptive limiter learns the rate limits
+type AdaptiveOptions struct {
+	// MinRate and MaxRate bound the rate of the sources in requests per second
+	MinRate float64
+	MaxRate float64
+	// Increase is added to the rate of a source on each successful request
+	Increase float64
+	// Decrease multiplies the rate of a source when it is rate limited
+	Decrease float64
+}
+
+// DefaultAdaptiveOptions are the adaptive options used when none are given
+var DefaultAdaptiveOptions = AdaptiveOptions{MinRate: 1.0 / 60, MaxRate: 50, Increase: 0.1, Decrease: 0.5}
+
+// AdaptiveLimiter paces the requests of the sources on top of their static rate
+// limits, learning the limits of the providers: the rate of a source is cut when
+// the provider rate limits it, with a 429 status or an X-RateLimit-Remaining
+// header at 0, and increased additively on every successful request. It can be
+// shared by the sessions of a run. A nil AdaptiveLimiter does not limit.
+type AdaptiveLimiter struct {
+	options AdaptiveOptions
+
+	mu      sync.Mutex
+	sources map[string]*adaptiveRate
+	// initial are the rates the sources start at instead of the maximum rate
+	initial map[string]float64
+}
+
+// adaptiveRate is the learned rate of a source
+type adaptiveRate struct {
+	rate float64
+	next time.Time
+	// learned is set once a response of the source adjusted its rate or the rate was loaded
+	learned bool
+}
+
+// NewAdaptiveLimiter creates an adaptive limiter starting the sources at the given
+// rates in requests per second, e.g. their static rate limits, or at the maximum rate
+func NewAdaptiveLimiter(options AdaptiveOptions, initial map[string]float64) *AdaptiveLimiter {
+	return &AdaptiveLimiter{options: options, sources: make(map[string]*adaptiveRate), initial: maps.Clone(initial)}
+}
+
+// source returns the learned rate of a source, which must be called with the lock held
+func (l *AdaptiveLimiter) source(name string) *adaptiveRate {
+	source, ok := l.sources[name]
+	if !ok {
+		rate, ok := l.initial[name]
+		if !ok || rate <= 0 {
+			rate = l.options.MaxRate
+		}
+		source = &adaptiveRate{rate: l.clamp(rate)}
+		l.sources[name] = source
+	}
+	return source
+}
+
+func (l *AdaptiveLimiter) clamp(rate float64) float64 {
+	return min(max(rate, l.options.MinRate), l.options.MaxRate)
+}
+
+// Wait waits for the next request of the source to be allowed by its learned rate
+func (l *AdaptiveLimiter) Wait(ctx context.Context, name string) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	source := l.source(name)
+	slot := time.Now()
+	if source.next.After(slot) {
+		slot = source.next
+	}
+	source.next = slot.Add(time.Duration(float64(time.Second) / source.rate))
+	l.mu.Unlock()
+
+	wait := time.Until(slot)
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Observe adjusts the rate of the source to the response of one of its requests,
+// a Retry-After delay of a rate limited response also pausing the source
+func (l *AdaptiveLimiter) Observe(name string, response *http.Response) {
+	if l == nil || response == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	source := l.source(name)
+	switch {
+	case response.StatusCode == http.StatusTooManyRequests || response.Header.Get("X-RateLimit-Remaining") == "0":
+		source.rate = l.clamp(source.rate * l.options.Decrease)
+		source.learned = true
+		if retryAfter, ok := parseRetryAfter(response); ok {
+			if resume := time.Now().Add(retryAfter); resume.After(source.next) {
+				source.next = resume
+			}
+		}
+	case response.StatusCode < http.StatusBadRequest:
+		source.rate = l.clamp(source.rate + l.options.Increase)
+		source.learned = true
+	}
+}
+
+// Rate returns the current rate of a source in requests per second
+func (l *AdaptiveLimiter) Rate(name string) float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.source(name).rate
+}
+
+// Learned returns the rates of the sources which were adjusted by their responses or loaded
+func (l *AdaptiveLimiter) Learned() map[string]float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	learned := make(map[string]float64)
+	for name, source := range l.sources {
+		if source.learned {
+			learned[name] = source.rate
+		}
+	}
+	return learned
+}
+
+// Load starts the sources at the rates saved to a file by a previous run, a
+// missing file being ignored, and marks them as learned
+func (l *AdaptiveLimiter) Load(file string) error {
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var rates map[string]float64
+	if err := json.Unmarshal(data, &rates); err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for name, rate := range rates {
+		if rate > 0 {
+			l.sources[name] = &adaptiveRate{rate: l.clamp(rate), learned: true}
+		}
+	}
+	return nil
+}
+
+// Save writes the learned rates to a file, keeping the ones of the sources which
+// were not run and replacing the file atomically
+func (l *AdaptiveLimiter) Save(file string) error {
+	rates := make(map[string]float64)
+	if data, err := os.ReadFile(file); err == nil {
+		_ = json.Unmarshal(data, &rates)
+	}
+	maps.Copy(rates, l.Learned())
+
+	data, err := json.MarshalIndent(rates, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	temp, err := os.CreateTemp(filepath.Dir(file), filepath.Base(file)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(temp.Name()) }()
+	if _, err := temp.Write(append(data, '\n')); err != nil {
+		_ = temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), file)
+}
@@ -0,0 +1,79 @@
+package subscraping
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// rateLimitedResponse returns a response with the status and headers
+func rateLimitedResponse(status int, headers map[string]string) *http.Response {
+	response := &http.Response{StatusCode: status, Header: make(http.Header)}
+	for name, value := range headers {
+		response.Header.Set(name, value)
+	}
+	return response
+}
+
+func TestAdaptiveLimiterRates(t *testing.T) {
+	limiter := NewAdaptiveLimiter(AdaptiveOptions{MinRate: 1, MaxRate: 10, Increase: 0.5, Decrease: 0.5}, map[string]float64{"static": 4, "fast": 100})
+	require.Equal(t, 4.0, limiter.Rate("static"), "the static rate limit is the initial rate")
+	require.Equal(t, 10.0, limiter.Rate("fast"), "the initial rate is capped")
+	require.Equal(t, 10.0, limiter.Rate("unknown"), "the sources without a rate limit start at the maximum rate")
+
+	limiter.Observe("static", rateLimitedResponse(http.StatusTooManyRequests, nil))
+	require.Equal(t, 2.0, limiter.Rate("static"), "the rate is cut when rate limited")
+	limiter.Observe("static", rateLimitedResponse(http.StatusOK, map[string]string{"X-RateLimit-Remaining": "0"}))
+	require.Equal(t, 1.0, limiter.Rate("static"), "an exhausted quota is a rate limit")
+	limiter.Observe("static", rateLimitedResponse(http.StatusTooManyRequests, nil))
+	require.Equal(t, 1.0, limiter.Rate("static"), "the rate does not go below the minimum")
+
+	limiter.Observe("static", rateLimitedResponse(http.StatusOK, map[string]string{"X-RateLimit-Remaining": "10"}))
+	require.Equal(t, 1.5, limiter.Rate("static"), "the rate increases on success")
+	limiter.Observe("static", rateLimitedResponse(http.StatusNotFound, nil))
+	require.Equal(t, 1.5, limiter.Rate("static"), "other failures do not change the rate")
+
+	limiter.Observe("ramped", rateLimitedResponse(http.StatusOK, nil))
+	require.Equal(t, map[string]float64{"static": 1.5, "ramped": 10}, limiter.Learned(), "the sources whose rate was only increased are learned too")
+
+	var disabled *AdaptiveLimiter
+	disabled.Observe("static", rateLimitedResponse(http.StatusTooManyRequests, nil))
+	require.NoError(t, disabled.Wait(context.Background(), "static"))
+}
+
+func TestAdaptiveLimiterWait(t *testing.T) {
+	limiter := NewAdaptiveLimiter(AdaptiveOptions{MinRate: 1, MaxRate: 20, Decrease: 0.5}, nil)
+
+	start := time.Now()
+	for range 3 {
+		require.NoError(t, limiter.Wait(context.Background(), "paced"))
+	}
+	require.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond, "the requests are paced at the rate")
+
+	limiter.Observe("paced", rateLimitedResponse(http.StatusTooManyRequests, map[string]string{"Retry-After": "3600"}))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, limiter.Wait(ctx, "paced"), context.DeadlineExceeded, "the source is paused for the Retry-After delay")
+}
+
+func TestAdaptiveLimiterPersistence(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "rate-limits.json")
+	require.NoError(t, os.WriteFile(file, []byte(`{"other": 0.5, "learned": 3}`), 0600))
+
+	limiter := NewAdaptiveLimiter(AdaptiveOptions{MinRate: 0.1, MaxRate: 10, Decrease: 0.5}, nil)
+	require.NoError(t, limiter.Load(file))
+	require.Equal(t, 3.0, limiter.Rate("learned"), "the saved rate is the initial rate")
+	limiter.Observe("learned", rateLimitedResponse(http.StatusTooManyRequests, nil))
+	require.NoError(t, limiter.Save(file))
+
+	limiter = NewAdaptiveLimiter(AdaptiveOptions{MinRate: 0.1, MaxRate: 10}, nil)
+	require.NoError(t, limiter.Load(file))
+	require.Equal(t, map[string]float64{"other": 0.5, "learned": 1.5}, limiter.Learned())
+
+	require.NoError(t, limiter.Load(filepath.Join(t.TempDir(), "missing.json")), "a missing file is ignored")
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/projectdiscovery/ratelimit"
 
 	"github.com/projectdiscovery/gologger"
+	"github.com/projectdiscovery/subfinder/v2/pkg/metrics"
 )
 
 // NewSession creates a new session object for a domain
@@ -46,7 +47,7 @@ func NewSession(domain string, proxy string, multiRateLimiter *ratelimit.MultiLi
 		Timeout:   time.Duration(timeout) * time.Second,
 	}
 
-	session := &Session{Client: client}
+	session := &Session{Client: client, domain: domain}
 
 	// Initiate rate limit instance
 	session.MultiRateLimiter = multiRateLimiter
@@ -80,6 +81,108 @@ func (s *Session) SimplePost(ctx context.Context, postURL, contentType string, b
 
 // HTTPRequest makes any HTTP request to a URL with extended parameters
 func (s *Session) HTTPRequest(ctx context.Context, method, requestURL, cookies string, headers map[string]string, body io.Reader, basicAuth BasicAuth) (*http.Response, error) {
+	sourceName := ctx.Value(CtxSourceArg).(string)
+
+	// the body is buffered to be part of the cache key and to be sent again on retries
+	var bodyBytes []byte
+	if body != nil {
+		var err error
+		if bodyBytes, err = io.ReadAll(body); err != nil {
+			return nil, err
+		}
+	}
+
+	var cacheKey string
+	if s.Cache != nil && s.Cache.TTL(sourceName) > 0 {
+		cacheKey = s.Cache.Key(sourceName, s.domain, method, requestURL, bodyBytes)
+		if response, ok := s.Cache.Get(sourceName, cacheKey); ok {
+			return response, nil
+		}
+	}
+
+	key, _ := ctx.Value(CtxKeyArg).(string)
+	policy := s.Retrier.policy(sourceName)
+	for retry := 0; ; retry++ {
+		if err := s.Retrier.allow(sourceName); err != nil {
+			return nil, err
+		}
+
+		var requestBody io.Reader
+		if bodyBytes != nil {
+			requestBody = bytes.NewReader(bodyBytes)
+		}
+		response, err := s.sendRequest(ctx, sourceName, method, requestURL, cookies, headers, requestBody, basicAuth)
+		// a key quarantined for the rest of the run is not sent again
+		if s.Keys.report(sourceName, key, response, err) || ctx.Err() != nil {
+			return response, err
+		}
+		retryable := err != nil && isRetryable(response, err)
+		s.Retrier.record(sourceName, retryable)
+		if err == nil && cacheKey != "" {
+			return s.Cache.Put(sourceName, cacheKey, response)
+		}
+		if !retryable || retry >= policy.MaxRetries {
+			return response, err
+		}
+		wait, ok := policy.backoff(retry+1, response)
+		if !ok {
+			return response, err
+		}
+
+		s.DiscardHTTPResponse(response)
+		s.addRetry(sourceName)
+		gologger.Debug().Msgf("Retrying request to %s for %s in %s: %s", requestURL, sourceName, wait.Round(time.Millisecond), err)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// Key returns the API key of the next requests of the source of the context among
+// its keys, the next healthy key in turn, along with the context to send these
+// requests with for their responses to be reported to the key manager. A random
+// key is returned without a key manager, and an empty key when none is healthy.
+func (s *Session) Key(ctx context.Context, keys []string) (context.Context, string) {
+	sourceName := ctx.Value(CtxSourceArg).(string)
+	if s.Keys == nil {
+		return ctx, PickRandom(keys, sourceName)
+	}
+	s.Keys.Register(sourceName, keys)
+	key, ok := s.Keys.next(sourceName, nil)
+	if !ok && len(keys) > 0 {
+		gologger.Debug().Msgf("Cannot use the %s source because all its API keys are quarantined", sourceName)
+	}
+	return context.WithValue(ctx, CtxKeyArg, key), key
+}
+
+// WithKey sends a request of the source of the context built with the next
+// healthy API key among its keys, building and sending it again with the next
+// healthy key while the provider rejects the key
+func (s *Session) WithKey(ctx context.Context, keys []string, send func(ctx context.Context, key string) (*http.Response, error)) (*http.Response, error) {
+	sourceName := ctx.Value(CtxSourceArg).(string)
+	keyCtx, key := s.Key(ctx, keys)
+	var tried []string
+	for {
+		response, err := send(keyCtx, key)
+		if key == "" || ctx.Err() != nil || s.Keys.healthy(sourceName, key) {
+			return response, err
+		}
+		tried = append(tried, key)
+		next, ok := s.Keys.next(sourceName, tried)
+		if !ok {
+			return response, err
+		}
+		s.DiscardHTTPResponse(response)
+		gologger.Debug().Msgf("Sending request of %s again with the next API key: %s", sourceName, err)
+		key = next
+		keyCtx = context.WithValue(ctx, CtxKeyArg, key)
+	}
+}
+
+// sendRequest sends a single request of a source once its rate limit allows it
+func (s *Session) sendRequest(ctx context.Context, sourceName, method, requestURL, cookies string, headers map[string]string, body io.Reader, basicAuth BasicAuth) (*http.Response, error) {
 	req, err := http.NewRequestWithContext(ctx, method, requestURL, body)
 	if err != nil {
 		return nil, err
@@ -102,13 +205,43 @@ func (s *Session) HTTPRequest(ctx context.Context, method, requestURL, cookies s
 		req.Header.Set(key, value)
 	}
 
-	sourceName := ctx.Value(CtxSourceArg).(string)
+	waitStart := time.Now()
 	mrlErr := s.MultiRateLimiter.Take(sourceName)
+	if mrlErr == nil {
+		mrlErr = s.AdaptiveLimiter.Wait(ctx, sourceName)
+	}
+	metrics.Default.ObserveRateLimitWait(sourceName, time.Since(waitStart))
 	if mrlErr != nil {
 		return nil, mrlErr
 	}
 
-	return httpRequestWrapper(s.Client, req)
+	requestStart := time.Now()
+	response, err := httpRequestWrapper(s.Client, req)
+	var statusCode int
+	if response != nil {
+		statusCode = response.StatusCode
+	}
+	metrics.Default.ObserveRequest(sourceName, statusCode, time.Since(requestStart))
+	s.AdaptiveLimiter.Observe(sourceName, response)
+	return response, err
+}
+
+// addRetry counts a retry of a request of a source
+func (s *Session) addRetry(sourceName string) {
+	s.retriesMu.Lock()
+	defer s.retriesMu.Unlock()
+
+	if s.retries == nil {
+		s.retries = make(map[string]int)
+	}
+	s.retries[sourceName]++
+}
+
+// Retries returns the number of requests of a source which were retried by the session
+func (s *Session) Retries(sourceName string) int {
+	s.retriesMu.Lock()
+	defer s.retriesMu.Unlock()
+	return s.retries[sourceName]
 }
 
 // DiscardHTTPResponse discards the response content by demand
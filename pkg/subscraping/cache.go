@@ -0,0 +1,219 @@
+package subscraping
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+
+	"github.com/projectdiscovery/gologger"
+)
+
+// CacheStatistics contains the cache usage of a source
+type CacheStatistics struct {
+	Hits   int
+	Misses int
+}
+
+// maxCachedBodySize is the size of the largest response body cached, the larger
+// ones being streamed to the sources instead of being held in memory
+const maxCachedBodySize = 16 << 20
+
+// ResponseCache is an on-disk cache of the successful responses returned
+// to the sources, used to avoid repeating (paid) API calls.
+type ResponseCache struct {
+	dir         string
+	defaultTTL  time.Duration
+	ttls        map[string]time.Duration
+	refresh     bool
+	maxBodySize int64
+
+	mu         sync.Mutex
+	statistics map[string]*CacheStatistics
+}
+
+type cachedResponse struct {
+	StoredAt   time.Time   `json:"stored_at"`
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+}
+
+// NewResponseCache creates a response cache in the given directory. The per-source
+// TTLs override the default one, a TTL of zero disables the caching for a source.
+// When refresh is true the cached responses are ignored but still updated.
+func NewResponseCache(dir string, defaultTTL time.Duration, ttls map[string]time.Duration, refresh bool) (*ResponseCache, error) {
+	if dir == "" {
+		return nil, errors.New("empty cache directory")
+	}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	return &ResponseCache{
+		dir:         dir,
+		defaultTTL:  defaultTTL,
+		ttls:        ttls,
+		refresh:     refresh,
+		maxBodySize: maxCachedBodySize,
+		statistics:  make(map[string]*CacheStatistics),
+	}, nil
+}
+
+// TTL returns how long the responses of the source are cached for
+func (c *ResponseCache) TTL(source string) time.Duration {
+	if ttl, ok := c.ttls[source]; ok {
+		return ttl
+	}
+	return c.defaultTTL
+}
+
+// Key returns the cache key of a request made by a source for a domain
+func (c *ResponseCache) Key(source, domain, method, requestURL string, body []byte) string {
+	hash := sha256.New()
+	for _, part := range []string{source, domain, method, requestURL} {
+		hash.Write([]byte(part))
+		hash.Write([]byte{0})
+	}
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func (c *ResponseCache) path(source, key string) string {
+	return filepath.Join(c.dir, source, key+".json")
+}
+
+// Get returns the cached response for the key if it has not expired yet
+func (c *ResponseCache) Get(source, key string) (*http.Response, bool) {
+	response, ok := c.get(source, key)
+	c.count(source, ok)
+	return response, ok
+}
+
+func (c *ResponseCache) get(source, key string) (*http.Response, bool) {
+	if c.refresh {
+		return nil, false
+	}
+
+	data, err := os.ReadFile(c.path(source, key))
+	if err != nil {
+		return nil, false
+	}
+
+	var cached cachedResponse
+	if err := jsoniter.Unmarshal(data, &cached); err != nil {
+		return nil, false
+	}
+	if time.Since(cached.StoredAt) > c.TTL(source) {
+		return nil, false
+	}
+
+	return &http.Response{
+		Status:        http.StatusText(cached.StatusCode),
+		StatusCode:    cached.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        cached.Header,
+		Body:          io.NopCloser(bytes.NewReader(cached.Body)),
+		ContentLength: int64(len(cached.Body)),
+	}, true
+}
+
+// Put stores the response in the cache. As the body is consumed in the process,
+// the returned response must be used in place of the original one. Only a failure
+// to read the body is returned, a response that cannot be stored is just not cached.
+// The responses whose body is larger than the maximum size are not cached.
+func (c *ResponseCache) Put(source, key string, response *http.Response) (*http.Response, error) {
+	if response.ContentLength > c.maxBodySize {
+		return response, nil
+	}
+	body, err := io.ReadAll(io.LimitReader(response.Body, c.maxBodySize+1))
+	if err != nil {
+		_ = response.Body.Close()
+		return response, err
+	}
+	if int64(len(body)) > c.maxBodySize {
+		// the rest of the body is streamed to the source after the part already read
+		response.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), response.Body), response.Body}
+		return response, nil
+	}
+	if err := response.Body.Close(); err != nil {
+		return response, err
+	}
+	response.Body = io.NopCloser(bytes.NewReader(body))
+
+	if err := c.store(source, key, response, body); err != nil {
+		gologger.Debug().Msgf("Could not cache response for %s: %s\n", source, err)
+	}
+	return response, nil
+}
+
+func (c *ResponseCache) store(source, key string, response *http.Response, body []byte) error {
+	data, err := jsoniter.Marshal(&cachedResponse{
+		StoredAt:   time.Now(),
+		StatusCode: response.StatusCode,
+		Header:     response.Header,
+		Body:       body,
+	})
+	if err != nil {
+		return err
+	}
+
+	path := c.path(source, key)
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	// write to a temporary file first so that concurrent readers never see a partial entry
+	tmpFile, err := os.CreateTemp(filepath.Dir(path), key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmpFile.Write(data)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmpFile.Name())
+		return err
+	}
+	return os.Rename(tmpFile.Name(), path)
+}
+
+func (c *ResponseCache) count(source string, hit bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	statistics, ok := c.statistics[source]
+	if !ok {
+		statistics = &CacheStatistics{}
+		c.statistics[source] = statistics
+	}
+	if hit {
+		statistics.Hits++
+	} else {
+		statistics.Misses++
+	}
+}
+
+// Statistics returns the cache hits and misses of every source since the cache was created
+func (c *ResponseCache) Statistics() map[string]CacheStatistics {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	statistics := make(map[string]CacheStatistics, len(c.statistics))
+	for source, sourceStatistics := range c.statistics {
+		statistics[source] = *sourceStatistics
+	}
+	return statistics
+}
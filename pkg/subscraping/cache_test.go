@@ -0,0 +1,58 @@
+package subscraping
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/projectdiscovery/ratelimit"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponseCache(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		_, _ = w.Write([]byte("a.example.com"))
+	}))
+	defer server.Close()
+
+	cache, err := NewResponseCache(t.TempDir(), time.Hour, map[string]time.Duration{"uncached": 0}, false)
+	require.Nil(t, err)
+
+	ctx := context.Background()
+	multiRateLimiter, err := ratelimit.NewMultiLimiter(ctx, &ratelimit.Options{Key: "cached", IsUnlimited: true, MaxCount: math.MaxUint32})
+	require.Nil(t, err)
+	require.Nil(t, multiRateLimiter.Add(&ratelimit.Options{Key: "uncached", IsUnlimited: true, MaxCount: math.MaxUint32}))
+
+	session := &Session{Client: server.Client(), MultiRateLimiter: multiRateLimiter, Cache: cache, domain: "example.com"}
+
+	get := func(source string) string {
+		response, err := session.SimpleGet(context.WithValue(ctx, CtxSourceArg, source), server.URL)
+		require.Nil(t, err)
+		body, err := io.ReadAll(response.Body)
+		require.Nil(t, err)
+		session.DiscardHTTPResponse(response)
+		return string(body)
+	}
+
+	require.Equal(t, "a.example.com", get("cached"))
+	require.Equal(t, "a.example.com", get("cached"))
+	require.Equal(t, int32(1), requests.Load(), "second request should be served from the cache")
+	require.Equal(t, CacheStatistics{Hits: 1, Misses: 1}, cache.Statistics()["cached"])
+
+	get("uncached")
+	get("uncached")
+	require.Equal(t, int32(3), requests.Load(), "sources with a zero ttl should not be cached")
+
+	cache.maxBodySize = 4
+	require.Nil(t, multiRateLimiter.Add(&ratelimit.Options{Key: "large", IsUnlimited: true, MaxCount: math.MaxUint32}))
+	require.Equal(t, "a.example.com", get("large"), "large bodies are returned whole")
+	require.Equal(t, "a.example.com", get("large"))
+	require.Equal(t, int32(5), requests.Load(), "bodies larger than the maximum size should not be cached")
+}
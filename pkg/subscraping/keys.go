@@ -0,0 +1,329 @@
+package subscraping
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/projectdiscovery/gologger"
+	"github.com/projectdiscovery/subfinder/v2/pkg/metrics"
+)
+
+// KeyStatus is the health of an API key
+type KeyStatus string
+
+const (
+	// KeyHealthy keys were not rejected by the provider
+	KeyHealthy KeyStatus = "healthy"
+	// KeyInvalid keys were rejected as unauthorized or forbidden
+	KeyInvalid KeyStatus = "invalid"
+	// KeyExhausted keys were rate limited or ran out of quota, until the provider resets them
+	KeyExhausted KeyStatus = "exhausted"
+	// KeyValid, KeyExpired and KeyUnverified are the outcomes of the verification of a key
+	KeyValid      KeyStatus = "valid"
+	KeyExpired    KeyStatus = "expired"
+	KeyUnverified KeyStatus = "unverified"
+)
+
+// ErrKeyFormat is the error of the keys which are not in the format expected by their source
+var ErrKeyFormat = errors.New("the key is not in the format expected by the source")
+
+// KeyCheck is the outcome of the verification of an API key
+type KeyCheck struct {
+	Status KeyStatus
+	// Remaining is the remaining quota of the key when the provider reports it
+	Remaining *int
+	Error     error
+}
+
+// CheckKeyResponse verifies an API key from the response to a request sent with
+// it, an expired subscription being reported with a 402 status by the providers.
+// A successful response is not a valid key when its JSON body holds an error, as
+// some providers reject a key with a 200 status. The body is left unread.
+func CheckKeyResponse(response *http.Response, err error) KeyCheck {
+	if response == nil {
+		if err == nil {
+			err = errors.New("no response received")
+		}
+		return KeyCheck{Status: KeyUnverified, Error: err}
+	}
+	var remaining *int
+	if value, err := strconv.Atoi(response.Header.Get("X-RateLimit-Remaining")); err == nil {
+		remaining = &value
+	}
+	if err == nil {
+		err = fmt.Errorf("unexpected status code %d", response.StatusCode)
+	}
+
+	switch status := keyStatus(response); {
+	case status != KeyHealthy:
+		return KeyCheck{Status: status, Remaining: remaining, Error: err}
+	case response.StatusCode == http.StatusPaymentRequired:
+		return KeyCheck{Status: KeyExpired, Error: err}
+	case response.StatusCode >= http.StatusOK && response.StatusCode < http.StatusMultipleChoices:
+		message, ok := responseError(peekBody(response, 4096))
+		if !ok {
+			return KeyCheck{Status: KeyValid, Remaining: remaining}
+		}
+		if isKeyProblem(message) {
+			return KeyCheck{Status: KeyInvalid, Error: errors.New(message)}
+		}
+		return KeyCheck{Status: KeyUnverified, Remaining: remaining, Error: errors.New(message)}
+	}
+	return KeyCheck{Status: KeyUnverified, Remaining: remaining, Error: err}
+}
+
+// KeyUsage is the usage of an API key of a source during a run
+type KeyUsage struct {
+	Source string
+	// Key is the masked key
+	Key      string
+	Requests int
+	Failures int
+	Status   KeyStatus
+}
+
+// KeyManager tracks the health of the API keys of the sources. The sources ask
+// the session for the key of their next requests, which hands out the healthy
+// keys of a source in turn, and the responses to the requests sent with a key are
+// reported to it. A key rejected by the provider is quarantined: an invalid key
+// for the rest of the run and an exhausted one until the provider resets its
+// quota when the reset is within the run, for the rest of the run otherwise. It
+// can be shared by the sessions of a run. A nil KeyManager does not track the keys.
+type KeyManager struct {
+	// maxQuarantine is the longest wait for the reset of an exhausted key, beyond
+	// which the key is quarantined for the rest of the run
+	maxQuarantine time.Duration
+
+	mu      sync.Mutex
+	sources map[string][]*managedKey
+	// turns are the index of the next key of the sources
+	turns map[string]int
+}
+
+// managedKey is an API key of a source along with its usage
+type managedKey struct {
+	key      string
+	requests int
+	failures int
+	status   KeyStatus
+	// exhaustedUntil is when an exhausted key can be used again, zero for the rest of the run
+	exhaustedUntil time.Time
+}
+
+// NewKeyManager creates an empty key manager for a run lasting at most maxQuarantine
+func NewKeyManager(maxQuarantine time.Duration) *KeyManager {
+	return &KeyManager{maxQuarantine: maxQuarantine, sources: make(map[string][]*managedKey), turns: make(map[string]int)}
+}
+
+// Register adds the keys of a source which are not managed yet
+func (m *KeyManager) Register(source string, keys []string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, key := range keys {
+		if key == "" || slices.ContainsFunc(m.sources[source], func(managed *managedKey) bool { return managed.key == key }) {
+			continue
+		}
+		m.sources[source] = append(m.sources[source], &managedKey{key: key, status: KeyHealthy})
+	}
+}
+
+// next returns the next healthy key of a source in turn which is not excluded,
+// or false when there is none
+func (m *KeyManager) next(source string, exclude []string) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	keys := m.sources[source]
+	for i := range keys {
+		index := (m.turns[source] + i) % len(keys)
+		if key := keys[index]; isHealthy(key) && !slices.Contains(exclude, key.key) {
+			m.turns[source] = index + 1
+			return key.key, true
+		}
+	}
+	return "", false
+}
+
+// healthy returns whether a key of a source can be used, the keys which are not
+// managed being healthy
+func (m *KeyManager) healthy(source, key string) bool {
+	if m == nil {
+		return true
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	index := slices.IndexFunc(m.sources[source], func(managed *managedKey) bool { return managed.key == key })
+	return index < 0 || isHealthy(m.sources[source][index])
+}
+
+// report records the response to a request of a source sent with a key and
+// returns whether the provider rejected the key for the rest of the run
+func (m *KeyManager) report(source, key string, response *http.Response, err error) bool {
+	if m == nil || key == "" {
+		return false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	index := slices.IndexFunc(m.sources[source], func(managed *managedKey) bool { return managed.key == key })
+	if index < 0 {
+		return false
+	}
+	managed := m.sources[source][index]
+	managed.requests++
+	if err != nil {
+		managed.failures++
+	}
+	status := keyStatus(response)
+	if status == KeyHealthy {
+		isHealthy(managed)
+		metrics.Default.ObserveKeyRequest(source, keyLabel(key, index), err != nil, string(managed.status))
+		return false
+	}
+	if isHealthy(managed) {
+		gologger.Warning().Msgf("API key %s of %s is %s (status code %d)", MaskKey(key), source, status, response.StatusCode)
+	}
+	if managed.status != KeyInvalid {
+		managed.status = status
+		managed.exhaustedUntil = time.Time{}
+		if wait, ok := rateLimitWait(response); ok && status == KeyExhausted && wait <= m.maxQuarantine {
+			managed.exhaustedUntil = time.Now().Add(wait)
+		}
+	}
+	metrics.Default.ObserveKeyRequest(source, keyLabel(key, index), err != nil, string(managed.status))
+	return managed.exhaustedUntil.IsZero()
+}
+
+// Usage returns the usage of the keys of the sources sorted by source
+func (m *KeyManager) Usage() []KeyUsage {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var usage []KeyUsage
+	for _, source := range slices.Sorted(maps.Keys(m.sources)) {
+		for _, key := range m.sources[source] {
+			isHealthy(key)
+			usage = append(usage, KeyUsage{Source: source, Key: MaskKey(key.key), Requests: key.requests, Failures: key.failures, Status: key.status})
+		}
+	}
+	return usage
+}
+
+// keyLabel returns the label of a key in the metrics, the masked key followed by
+// its position among the keys of its source as the masks of two keys can be equal
+func keyLabel(key string, index int) string {
+	return MaskKey(key) + "#" + strconv.Itoa(index+1)
+}
+
+// isHealthy returns whether a key can be used, an exhausted key being healthy
+// again once the provider reset its quota. It must be called with the lock held.
+func isHealthy(key *managedKey) bool {
+	if key.status == KeyExhausted && !key.exhaustedUntil.IsZero() && !time.Now().Before(key.exhaustedUntil) {
+		key.status = KeyHealthy
+	}
+	return key.status == KeyHealthy
+}
+
+// keyProblems are the phrases of the 403 responses whose reason is the key
+var keyProblems = []string{"api key", "apikey", "api_key", "api-key", "token", "credential", "unauthorized", "not authorized", "subscription"}
+
+// keyStatus returns the status of a key given the response to a request sent
+// with it. A 403 is only put down to the key when the provider tells so, as the
+// firewalls in front of the providers block the requests with a 403 whatever their key.
+func keyStatus(response *http.Response) KeyStatus {
+	if response == nil {
+		return KeyHealthy
+	}
+	switch response.StatusCode {
+	case http.StatusUnauthorized:
+		return KeyInvalid
+	case http.StatusForbidden:
+		// GitHub and others answer with a 403 once the quota is exhausted
+		if isRateLimited(response) {
+			return KeyExhausted
+		}
+		if response.Header.Get("WWW-Authenticate") != "" {
+			return KeyInvalid
+		}
+		if isKeyProblem(string(peekBody(response, 4096))) {
+			return KeyInvalid
+		}
+	case http.StatusTooManyRequests:
+		return KeyExhausted
+	}
+	return KeyHealthy
+}
+
+// isKeyProblem returns whether a message of a provider puts an error down to the key
+func isKeyProblem(message string) bool {
+	message = strings.ToLower(message)
+	return slices.ContainsFunc(keyProblems, func(problem string) bool { return strings.Contains(message, problem) })
+}
+
+// responseError returns the error held by the JSON body of a successful response,
+// e.g. {"error": true, "errmsg": "..."} or {"error": "..."}
+func responseError(body []byte) (string, bool) {
+	var data map[string]any
+	if json.Unmarshal(body, &data) != nil {
+		return "", false
+	}
+	switch value := data["error"].(type) {
+	case string:
+		return value, value != ""
+	case bool:
+		if !value {
+			return "", false
+		}
+		for _, field := range []string{"errmsg", "message", "error_message"} {
+			if message, ok := data[field].(string); ok && message != "" {
+				return message, true
+			}
+		}
+		return "the provider answered with an error", true
+	case map[string]any:
+		if message, ok := value["message"].(string); ok && message != "" {
+			return message, true
+		}
+		return "the provider answered with an error", true
+	}
+	return "", false
+}
+
+// peekBody returns the start of the body of a response, which is left unread
+func peekBody(response *http.Response, size int64) []byte {
+	if response.Body == nil {
+		return nil
+	}
+	start, _ := io.ReadAll(io.LimitReader(response.Body, size))
+	response.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(start), response.Body), response.Body}
+	return start
+}
+
+// MaskKey hides all but the start of a key for it to be reported
+func MaskKey(key string) string {
+	if len(key) <= 8 {
+		return strings.Repeat("*", len(key))
+	}
+	return key[:4] + strings.Repeat("*", 4)
+}
@@ -0,0 +1,247 @@
+package subscraping
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/projectdiscovery/ratelimit"
+	"github.com/projectdiscovery/subfinder/v2/pkg/metrics"
+	"github.com/stretchr/testify/require"
+)
+
+// newKeySession creates a session managing the keys of the keyed source
+func newKeySession(t *testing.T) *Session {
+	multiRateLimiter, err := ratelimit.NewMultiLimiter(context.Background(), &ratelimit.Options{Key: "keyed", IsUnlimited: true, MaxCount: math.MaxUint32})
+	require.NoError(t, err)
+	t.Cleanup(func() { multiRateLimiter.Stop() })
+	return &Session{Client: http.DefaultClient, MultiRateLimiter: multiRateLimiter, Keys: NewKeyManager(time.Minute)}
+}
+
+var keyedCtx = context.WithValue(context.Background(), CtxSourceArg, "keyed")
+
+// sendWithKey sends a request to the server with the key as a query parameter
+func sendWithKey(session *Session, serverURL string) func(ctx context.Context, key string) (*http.Response, error) {
+	return func(ctx context.Context, key string) (*http.Response, error) {
+		return session.SimpleGet(ctx, serverURL+"?key="+key)
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	var mu sync.Mutex
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.URL.Query().Get("key"))
+		mu.Unlock()
+		if r.URL.Query().Get("key") == "revoked-key-2" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	defer func(registry *metrics.Registry) { metrics.Default = registry }(metrics.Default)
+	metrics.Default = metrics.New()
+
+	session := newKeySession(t)
+	keys := []string{"healthy-key-1", "revoked-key-2", "healthy-key-3"}
+	for range 5 {
+		ctx, key := session.Key(keyedCtx, keys)
+		resp, _ := sendWithKey(session, server.URL)(ctx, key)
+		session.DiscardHTTPResponse(resp)
+	}
+	mu.Lock()
+	require.Equal(t, []string{"healthy-key-1", "revoked-key-2", "healthy-key-3", "healthy-key-1", "healthy-key-3"}, requests, "the healthy keys are handed out in turn")
+	mu.Unlock()
+
+	require.Equal(t, []KeyUsage{
+		{Source: "keyed", Key: "heal****", Requests: 2, Status: KeyHealthy},
+		{Source: "keyed", Key: "revo****", Requests: 1, Failures: 1, Status: KeyInvalid},
+		{Source: "keyed", Key: "heal****", Requests: 2, Status: KeyHealthy},
+	}, session.Keys.Usage())
+
+	resp, err := session.SimpleGet(keyedCtx, server.URL+"?key=revoked-key-2")
+	require.Error(t, err)
+	session.DiscardHTTPResponse(resp)
+	require.Equal(t, 1, session.Keys.Usage()[1].Requests, "the requests sent without a key from the session are not reported")
+
+	var output strings.Builder
+	_, err = metrics.Default.WriteTo(&output)
+	require.NoError(t, err)
+	require.Contains(t, output.String(), `subfinder_key_requests_total{source="keyed",key="heal****#1"} 2`+"\n")
+	require.Contains(t, output.String(), `subfinder_key_requests_total{source="keyed",key="heal****#3"} 2`+"\n", "the keys sharing a mask have their own series")
+	require.Contains(t, output.String(), `subfinder_key_failures_total{source="keyed",key="revo****#2"} 1`+"\n")
+	require.Contains(t, output.String(), `subfinder_key_status{source="keyed",key="revo****#2",status="invalid"} 1`+"\n")
+
+	var unmanaged Session
+	ctx, key := unmanaged.Key(keyedCtx, []string{"only-key"})
+	require.Equal(t, "only-key", key, "a random key is picked without a key manager")
+	require.Nil(t, ctx.Value(CtxKeyArg))
+}
+
+func TestKeyFailover(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		switch r.URL.Query().Get("key") {
+		case "revoked-key-1":
+			w.WriteHeader(http.StatusUnauthorized)
+		case "exhausted-key":
+			w.WriteHeader(http.StatusTooManyRequests)
+		case "healthy-key-3":
+			_, _ = w.Write([]byte("www.example.com"))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	session := newKeySession(t)
+	session.Retrier = NewRetrier(RetryOptions{Default: RetryPolicy{MaxRetries: 2, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond}})
+	keys := []string{"revoked-key-1", "exhausted-key", "healthy-key-3"}
+	resp, err := session.WithKey(keyedCtx, keys, sendWithKey(session, server.URL))
+	require.NoError(t, err, "the request is built again with the next healthy keys")
+	session.DiscardHTTPResponse(resp)
+	require.Equal(t, int32(3), requests.Load(), "the requests sent with a key quarantined for the run are not retried")
+
+	resp, err = session.WithKey(keyedCtx, keys, sendWithKey(session, server.URL))
+	require.NoError(t, err)
+	session.DiscardHTTPResponse(resp)
+	require.Equal(t, int32(4), requests.Load(), "the quarantined keys are not handed out")
+
+	require.Equal(t, []KeyUsage{
+		{Source: "keyed", Key: "revo****", Requests: 1, Failures: 1, Status: KeyInvalid},
+		{Source: "keyed", Key: "exha****", Requests: 1, Failures: 1, Status: KeyExhausted},
+		{Source: "keyed", Key: "heal****", Requests: 2, Status: KeyHealthy},
+	}, session.Keys.Usage())
+}
+
+func TestKeyFailoverAllRejected(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"error": "Invalid API key"}`))
+	}))
+	defer server.Close()
+
+	session := newKeySession(t)
+	resp, err := session.WithKey(keyedCtx, []string{"user1:secret1", "user2:secret2"}, func(ctx context.Context, key string) (*http.Response, error) {
+		username, password, _ := strings.Cut(key, ":")
+		return session.HTTPRequest(ctx, http.MethodGet, server.URL, "", nil, nil, BasicAuth{Username: username, Password: password})
+	})
+	require.Error(t, err, "the response is returned once every key was rejected")
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	session.DiscardHTTPResponse(resp)
+	require.Equal(t, int32(2), requests.Load(), "the request is sent once with each key")
+
+	for _, usage := range session.Keys.Usage() {
+		require.Equal(t, KeyInvalid, usage.Status)
+	}
+	_, key := session.Key(keyedCtx, []string{"user1:secret1", "user2:secret2"})
+	require.Empty(t, key, "no key is handed out once every key is quarantined")
+}
+
+func TestKeyQuarantine(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		switch r.URL.Query().Get("key") {
+		case "blocked-key-3":
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte("Access denied by the firewall"))
+		case "limited-key-1":
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case "daily-key-4":
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			_, _ = w.Write([]byte("www.example.com"))
+		}
+	}))
+	defer server.Close()
+
+	session := newKeySession(t)
+	keys := []string{"limited-key-1", "healthy-key-2", "blocked-key-3", "daily-key-4"}
+	session.Keys.Register("keyed", keys)
+
+	resp, err := session.SimpleGet(context.WithValue(keyedCtx, CtxKeyArg, "blocked-key-3"), server.URL+"?key=blocked-key-3")
+	require.Error(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "Access denied by the firewall", string(body), "the body read to find the reason of the 403 is left to the source")
+	session.DiscardHTTPResponse(resp)
+
+	for _, key := range []string{"limited-key-1", "daily-key-4"} {
+		resp, err = session.SimpleGet(context.WithValue(keyedCtx, CtxKeyArg, key), server.URL+"?key="+key)
+		require.Error(t, err)
+		session.DiscardHTTPResponse(resp)
+	}
+	require.Equal(t, int32(3), requests.Load())
+
+	require.Equal(t, []KeyUsage{
+		{Source: "keyed", Key: "limi****", Requests: 1, Failures: 1, Status: KeyHealthy},
+		{Source: "keyed", Key: "heal****", Status: KeyHealthy},
+		{Source: "keyed", Key: "bloc****", Requests: 1, Failures: 1, Status: KeyHealthy},
+		{Source: "keyed", Key: "dail****", Requests: 1, Failures: 1, Status: KeyExhausted},
+	}, session.Keys.Usage(), "a rate limited key is healthy again after a Retry-After within the run, and quarantined for the run beyond it")
+}
+
+func TestKeyStatus(t *testing.T) {
+	for _, test := range []struct {
+		status    int
+		headers   map[string]string
+		body      string
+		keyStatus KeyStatus
+	}{
+		{status: http.StatusOK, keyStatus: KeyHealthy},
+		{status: http.StatusUnauthorized, keyStatus: KeyInvalid},
+		{status: http.StatusForbidden, body: "Forbidden", keyStatus: KeyHealthy},
+		{status: http.StatusForbidden, body: `{"message": "Your subscription has ended"}`, keyStatus: KeyInvalid},
+		{status: http.StatusForbidden, headers: map[string]string{"WWW-Authenticate": "Bearer"}, keyStatus: KeyInvalid},
+		{status: http.StatusForbidden, headers: map[string]string{"Retry-After": "60"}, keyStatus: KeyExhausted},
+		{status: http.StatusTooManyRequests, headers: map[string]string{"Retry-After": "30"}, keyStatus: KeyExhausted},
+		{status: http.StatusTooManyRequests, keyStatus: KeyExhausted},
+	} {
+		response := rateLimitedResponse(test.status, test.headers)
+		response.Body = io.NopCloser(strings.NewReader(test.body))
+		require.Equal(t, test.keyStatus, keyStatus(response), "status %d with %v %q", test.status, test.headers, test.body)
+	}
+}
+
+func TestCheckKeyResponse(t *testing.T) {
+	for _, test := range []struct {
+		status int
+		body   string
+		check  KeyStatus
+		err    string
+	}{
+		{status: http.StatusOK, body: `{"remaining": 10}`, check: KeyValid},
+		{status: http.StatusOK, body: `{"error": false, "remaining": 10}`, check: KeyValid},
+		{status: http.StatusOK, body: `{"error": true, "errmsg": "[-700] Invalid API key"}`, check: KeyInvalid, err: "[-700] Invalid API key"},
+		{status: http.StatusOK, body: `{"error": "Internal error"}`, check: KeyUnverified, err: "Internal error"},
+		{status: http.StatusPaymentRequired, check: KeyExpired, err: "unexpected status code 402"},
+	} {
+		response := rateLimitedResponse(test.status, nil)
+		response.Body = io.NopCloser(strings.NewReader(test.body))
+		check := CheckKeyResponse(response, nil)
+		require.Equal(t, test.check, check.Status, "status %d with %q", test.status, test.body)
+		if test.err == "" {
+			require.NoError(t, check.Error)
+		} else {
+			require.EqualError(t, check.Error, test.err)
+		}
+
+		body, err := io.ReadAll(response.Body)
+		require.NoError(t, err)
+		require.Equal(t, test.body, string(body), "the body is left unread")
+	}
+}
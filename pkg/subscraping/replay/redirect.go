@@ -0,0 +1,38 @@
+package replay
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// Redirect is a transport sending all the requests to a target server, e.g. an
+// httptest server, whatever their hard-coded base URL. The path and the query
+// are kept, the original host being available to the handlers as the request host.
+type Redirect struct {
+	target    *url.URL
+	transport http.RoundTripper
+}
+
+// NewRedirect creates a transport redirecting the requests to the target URL
+func NewRedirect(target string) (*Redirect, error) {
+	targetURL, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+	if targetURL.Scheme == "" || targetURL.Host == "" {
+		return nil, fmt.Errorf("invalid target %q, expected an absolute url", target)
+	}
+	return &Redirect{target: targetURL, transport: http.DefaultTransport}, nil
+}
+
+// RoundTrip sends the request to the target server
+func (r *Redirect) RoundTrip(req *http.Request) (*http.Response, error) {
+	redirected := req.Clone(req.Context())
+	if redirected.Host == "" {
+		redirected.Host = req.URL.Host
+	}
+	redirected.URL.Scheme = r.target.Scheme
+	redirected.URL.Host = r.target.Host
+	return r.transport.RoundTrip(redirected)
+}
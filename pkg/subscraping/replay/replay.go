@@ -0,0 +1,237 @@
+// Package replay records the HTTP responses of the sources to fixture files and
+// replays them, so that the sources can be tested offline. The transports of the
+// package are set as the transport of the client of a session:
+//
+//	session.Client.Transport = replay.NewReplayer(fixture)
+//
+// The requests are matched on their method, URL and body, the secrets being
+// replaced by placeholders when recording so that no API key ends up in the fixtures.
+package replay
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// ErrNoInteraction is returned when no recorded response matches a request
+var ErrNoInteraction = errors.New("no recorded response")
+
+// Fixture holds the interactions of a source with its provider for a domain
+type Fixture struct {
+	Source string `json:"source"`
+	Domain string `json:"domain"`
+	// Keys are the placeholders of the API keys used when recording
+	Keys         []string      `json:"keys,omitempty"`
+	Interactions []Interaction `json:"interactions"`
+	// Results are the subdomains expected from the source
+	Results []string `json:"results,omitempty"`
+}
+
+// Interaction is a request and its recorded response
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Request identifies a request
+type Request struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	Body   string `json:"body,omitempty"`
+}
+
+// Response is a recorded response
+type Response struct {
+	Status  int         `json:"status"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    string      `json:"body"`
+}
+
+// LoadFixture reads a fixture file
+func LoadFixture(file string) (*Fixture, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	fixture := &Fixture{}
+	if err := json.Unmarshal(data, fixture); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return fixture, nil
+}
+
+// Save writes the fixture to a file
+func (f *Fixture) Save(file string) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, append(data, '\n'), 0644)
+}
+
+// newRequest reads the request identifying an HTTP request, whose body is restored
+func newRequest(req *http.Request) (Request, error) {
+	request := Request{Method: req.Method, URL: req.URL.String()}
+	if req.Body != nil && req.Body != http.NoBody {
+		body, err := io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return request, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		request.Body = string(body)
+	}
+	return request, nil
+}
+
+// toHTTP creates the HTTP response of a recorded response
+func (r Response) toHTTP(req *http.Request) *http.Response {
+	headers := r.Headers.Clone()
+	if headers == nil {
+		headers = make(http.Header)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", r.Status, http.StatusText(r.Status)),
+		StatusCode:    r.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        headers,
+		Body:          io.NopCloser(strings.NewReader(r.Body)),
+		ContentLength: int64(len(r.Body)),
+		Request:       req,
+	}
+}
+
+// Replayer is a transport answering the requests with the responses of a fixture
+type Replayer struct {
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool
+}
+
+// NewReplayer creates a transport replaying the interactions of a fixture
+func NewReplayer(fixture *Fixture) *Replayer {
+	return &Replayer{interactions: fixture.Interactions, used: make([]bool, len(fixture.Interactions))}
+}
+
+// RoundTrip returns the first response not replayed yet of the interactions matching
+// the request, the last one being replayed again once all of them were
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	request, err := newRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	match := -1
+	for i, interaction := range r.interactions {
+		if interaction.Request != request {
+			continue
+		}
+		match = i
+		if !r.used[i] {
+			break
+		}
+	}
+	if match < 0 {
+		return nil, fmt.Errorf("%w for %s %s", ErrNoInteraction, request.Method, request.URL)
+	}
+	r.used[match] = true
+	return r.interactions[match].Response.toHTTP(req), nil
+}
+
+// Unused returns the requests of the interactions which were not replayed
+func (r *Replayer) Unused() []Request {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var unused []Request
+	for i, interaction := range r.interactions {
+		if !r.used[i] {
+			unused = append(unused, interaction.Request)
+		}
+	}
+	return unused
+}
+
+// Recorder is a transport recording the interactions of the requests it sends
+type Recorder struct {
+	transport http.RoundTripper
+	replacer  *strings.Replacer
+
+	mu           sync.Mutex
+	interactions []Interaction
+}
+
+// NewRecorder creates a transport recording the requests sent with the given
+// transport, the keys of the secrets being replaced by their values
+func NewRecorder(transport http.RoundTripper, secrets map[string]string) *Recorder {
+	var replacements []string
+	for secret, placeholder := range secrets {
+		if secret != "" {
+			replacements = append(replacements, secret, placeholder)
+		}
+	}
+	return &Recorder{transport: transport, replacer: strings.NewReplacer(replacements...)}
+}
+
+// RoundTrip sends the request and records its response
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	request, err := newRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	headers := make(http.Header)
+	for name, values := range resp.Header {
+		if recordedHeader(name) {
+			for _, value := range values {
+				headers.Add(name, r.replacer.Replace(value))
+			}
+		}
+	}
+	request.URL = r.replacer.Replace(request.URL)
+	request.Body = r.replacer.Replace(request.Body)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.interactions = append(r.interactions, Interaction{
+		Request:  request,
+		Response: Response{Status: resp.StatusCode, Headers: headers, Body: r.replacer.Replace(string(body))},
+	})
+	return resp, nil
+}
+
+// recordedHeader returns whether a response header is recorded, the headers read
+// by the sources and the session, e.g. to paginate or to wait for the rate limits
+func recordedHeader(name string) bool {
+	name = http.CanonicalHeaderKey(name)
+	return name == "Content-Type" || name == "Link" || name == "Retry-After" || strings.HasPrefix(name, "X-Ratelimit-")
+}
+
+// Interactions returns the interactions recorded so far
+func (r *Recorder) Interactions() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Interaction(nil), r.interactions...)
+}
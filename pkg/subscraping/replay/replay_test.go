@@ -0,0 +1,98 @@
+package replay
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Request-Id", "1234")
+		w.Header().Set("X-RateLimit-Remaining", "10")
+		w.Header().Set("Link", `<http://`+r.Host+`/search?q=example.com&page=2&key=s3cr3t>; rel="next"`)
+		body, _ := io.ReadAll(r.Body)
+		_, _ = io.WriteString(w, `{"query":"`+r.URL.Query().Get("q")+`","body":"`+string(body)+`"}`)
+	}))
+	defer server.Close()
+
+	recorder := NewRecorder(http.DefaultTransport, map[string]string{"s3cr3t": "key1"})
+	client := &http.Client{Transport: recorder}
+	resp, err := client.Post(server.URL+"/search?q=example.com&key=s3cr3t", "text/plain", strings.NewReader("token=s3cr3t"))
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, `{"query":"example.com","body":"token=s3cr3t"}`, string(body), "the recorded response is returned unaltered")
+
+	file := filepath.Join(t.TempDir(), "fixture.json")
+	fixture := &Fixture{Source: "test", Domain: "example.com", Keys: []string{"key1"}, Interactions: recorder.Interactions()}
+	require.NoError(t, fixture.Save(file))
+	fixture, err = LoadFixture(file)
+	require.NoError(t, err)
+
+	require.Len(t, fixture.Interactions, 1)
+	interaction := fixture.Interactions[0]
+	require.Equal(t, Request{Method: http.MethodPost, URL: server.URL + "/search?q=example.com&key=key1", Body: "token=key1"}, interaction.Request, "the secrets are replaced")
+	require.Equal(t, `{"query":"example.com","body":"token=key1"}`, interaction.Response.Body)
+	require.Equal(t, http.Header{
+		"Content-Type":          {"application/json"},
+		"X-Ratelimit-Remaining": {"10"},
+		"Link":                  {`<` + server.URL + `/search?q=example.com&page=2&key=key1>; rel="next"`},
+	}, interaction.Response.Headers, "only the headers read by the sources are recorded, without the secrets")
+
+	replayer := NewReplayer(fixture)
+	client = &http.Client{Transport: replayer}
+	_, err = client.Get(server.URL + "/search?q=example.org")
+	require.ErrorIs(t, err, ErrNoInteraction)
+	require.Len(t, replayer.Unused(), 1)
+
+	resp, err = client.Post(server.URL+"/search?q=example.com&key=key1", "text/plain", strings.NewReader("token=key1"))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	body, err = io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, interaction.Response.Body, string(body))
+	require.Empty(t, replayer.Unused())
+}
+
+func TestReplayOrder(t *testing.T) {
+	request := Request{Method: http.MethodGet, URL: "https://api.example.com/poll"}
+	replayer := NewReplayer(&Fixture{Interactions: []Interaction{
+		{Request: request, Response: Response{Status: http.StatusTooManyRequests, Body: "slow down"}},
+		{Request: request, Response: Response{Status: http.StatusOK, Body: "done"}},
+	}})
+	client := &http.Client{Transport: replayer}
+
+	var statuses []int
+	for range 3 {
+		resp, err := client.Get(request.URL)
+		require.NoError(t, err)
+		statuses = append(statuses, resp.StatusCode)
+	}
+	require.Equal(t, []int{http.StatusTooManyRequests, http.StatusOK, http.StatusOK}, statuses, "identical requests are replayed in order, the last response being repeated")
+}
+
+func TestRedirect(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, r.Host+" "+r.URL.RequestURI())
+	}))
+	defer server.Close()
+
+	redirect, err := NewRedirect(server.URL)
+	require.NoError(t, err)
+	client := &http.Client{Transport: redirect}
+	resp, err := client.Get("https://api.provider.com/v1/subdomains?domain=example.com")
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "api.provider.com /v1/subdomains?domain=example.com", string(body))
+
+	_, err = NewRedirect("localhost:8080")
+	require.Error(t, err)
+}
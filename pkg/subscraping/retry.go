@@ -0,0 +1,180 @@
+package subscraping
+
+import (
+	"errors"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned for the requests of a source paused after too many failed requests
+var ErrCircuitOpen = errors.New("circuit breaker open after repeated failed requests")
+
+// RetryPolicy is how the failed requests of a source are retried
+type RetryPolicy struct {
+	// MaxRetries is the number of retries after the first attempt, 0 disabling them
+	MaxRetries int
+	// MinBackoff is the wait before the first retry, doubled for each of the next ones
+	MinBackoff time.Duration
+	// MaxBackoff caps the wait between attempts, a longer Retry-After ending the retries
+	MaxBackoff time.Duration
+}
+
+// RetryOptions configure the retries and the circuit breakers of the sources
+type RetryOptions struct {
+	Default RetryPolicy
+	// Sources are the policies of the sources which differ from the default
+	Sources map[string]RetryPolicy
+	// BreakerThreshold is the number of consecutive failed requests after which the
+	// requests of a source fail for BreakerCooldown, 0 disabling the circuit breakers
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+}
+
+// DefaultRetryOptions are the retry options used when none are given
+var DefaultRetryOptions = RetryOptions{
+	Default:          RetryPolicy{MaxRetries: 2, MinBackoff: time.Second, MaxBackoff: time.Minute},
+	BreakerThreshold: 5,
+	BreakerCooldown:  time.Minute,
+}
+
+// Retrier retries the failed requests of the sources and pauses the sources whose
+// provider keeps failing. It can be shared by the sessions of a run. A nil Retrier
+// sends every request once.
+type Retrier struct {
+	options RetryOptions
+
+	mu       sync.Mutex
+	breakers map[string]*breaker
+}
+
+// breaker is the circuit breaker of a source
+type breaker struct {
+	failures  int
+	openUntil time.Time
+}
+
+// NewRetrier creates a retrier with the given options
+func NewRetrier(options RetryOptions) *Retrier {
+	return &Retrier{options: options, breakers: make(map[string]*breaker)}
+}
+
+// policy returns the retry policy of a source
+func (r *Retrier) policy(source string) RetryPolicy {
+	if r == nil {
+		return RetryPolicy{}
+	}
+	if policy, ok := r.options.Sources[source]; ok {
+		return policy
+	}
+	return r.options.Default
+}
+
+// allow returns ErrCircuitOpen while the circuit breaker of the source is open
+func (r *Retrier) allow(source string) error {
+	if r == nil || r.options.BreakerThreshold <= 0 {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if b, ok := r.breakers[source]; ok && time.Now().Before(b.openUntil) {
+		return ErrCircuitOpen
+	}
+	return nil
+}
+
+// record records the outcome of a request of a source, opening its circuit
+// breaker once the consecutive failures reach the threshold. A request failing
+// once the breaker closed again opens it right away.
+func (r *Retrier) record(source string, failed bool) {
+	if r == nil || r.options.BreakerThreshold <= 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b, ok := r.breakers[source]
+	if !ok {
+		b = &breaker{}
+		r.breakers[source] = b
+	}
+	if !failed {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= r.options.BreakerThreshold {
+		b.openUntil = time.Now().Add(r.options.BreakerCooldown)
+	}
+}
+
+// backoff returns the wait before the given retry, starting at 1, and whether to retry
+// at all. The wait asked for by the provider is used when present.
+func (p RetryPolicy) backoff(retry int, response *http.Response) (time.Duration, bool) {
+	if retryAfter, ok := rateLimitWait(response); ok {
+		return retryAfter, retryAfter <= p.MaxBackoff
+	}
+	wait := p.MinBackoff << (retry - 1)
+	if wait > p.MaxBackoff || wait <= 0 {
+		wait = p.MaxBackoff
+	}
+	// full jitter on the upper half keeps the sources from retrying in lockstep
+	if half := int64(wait / 2); half > 0 {
+		wait = time.Duration(half + rand.Int63n(half+1))
+	}
+	return wait, true
+}
+
+// parseRetryAfter reads the Retry-After header in seconds or as a date
+func parseRetryAfter(response *http.Response) (time.Duration, bool) {
+	if response == nil {
+		return 0, false
+	}
+	value := response.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0), true
+	}
+	return 0, false
+}
+
+// rateLimitWait returns how long the provider asks to wait before the next request,
+// read from the Retry-After header or, once the quota is exhausted, the reset time
+// of the X-RateLimit-Reset header in seconds since the epoch
+func rateLimitWait(response *http.Response) (time.Duration, bool) {
+	if retryAfter, ok := parseRetryAfter(response); ok {
+		return retryAfter, true
+	}
+	if response == nil || response.Header.Get("X-RateLimit-Remaining") != "0" {
+		return 0, false
+	}
+	reset, err := strconv.ParseInt(response.Header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return max(time.Until(time.Unix(reset, 0)), 0), true
+}
+
+// isRateLimited returns whether a 403 response is a rate limit, as GitHub and
+// others answer with a 403 holding their rate limit headers
+func isRateLimited(response *http.Response) bool {
+	return response.StatusCode == http.StatusForbidden && (response.Header.Get("X-RateLimit-Remaining") == "0" || response.Header.Get("Retry-After") != "")
+}
+
+// isRetryable returns whether a request failed with a rate limit, server error or timeout
+func isRetryable(response *http.Response, err error) bool {
+	if response != nil {
+		return response.StatusCode == http.StatusTooManyRequests || isRateLimited(response) || response.StatusCode >= http.StatusInternalServerError
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
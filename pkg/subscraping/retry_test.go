@@ -0,0 +1,135 @@
+package subscraping
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/projectdiscovery/ratelimit"
+	"github.com/stretchr/testify/require"
+)
+
+// newRetrySession creates a session retrying the requests of the retried source
+func newRetrySession(t *testing.T, options RetryOptions) *Session {
+	multiRateLimiter, err := ratelimit.NewMultiLimiter(context.Background(), &ratelimit.Options{Key: "retried", IsUnlimited: true, MaxCount: math.MaxUint32})
+	require.NoError(t, err)
+	t.Cleanup(func() { multiRateLimiter.Stop() })
+	return &Session{Client: http.DefaultClient, MultiRateLimiter: multiRateLimiter, Retrier: NewRetrier(options)}
+}
+
+// failingServer answers with the status until the given number of requests were received
+func failingServer(t *testing.T, status int, failures int32, headers map[string]string) (*httptest.Server, *atomic.Int32) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) <= failures {
+			for name, value := range headers {
+				w.Header().Set(name, value)
+			}
+			w.WriteHeader(status)
+			return
+		}
+		_, _ = w.Write([]byte("www.example.com"))
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+var retriedCtx = context.WithValue(context.Background(), CtxSourceArg, "retried")
+
+func TestRetries(t *testing.T) {
+	policy := RetryPolicy{MaxRetries: 2, MinBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond}
+
+	server, requests := failingServer(t, http.StatusServiceUnavailable, 2, nil)
+	session := newRetrySession(t, RetryOptions{Default: policy})
+	resp, err := session.SimpleGet(retriedCtx, server.URL)
+	require.NoError(t, err)
+	session.DiscardHTTPResponse(resp)
+	require.Equal(t, int32(3), requests.Load())
+	require.Equal(t, 2, session.Retries("retried"))
+
+	server, requests = failingServer(t, http.StatusTooManyRequests, 5, nil)
+	session = newRetrySession(t, RetryOptions{Default: policy})
+	resp, err = session.SimpleGet(retriedCtx, server.URL)
+	require.Error(t, err, "the error is returned once the retries are exhausted")
+	require.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	session.DiscardHTTPResponse(resp)
+	require.Equal(t, int32(3), requests.Load())
+
+	server, requests = failingServer(t, http.StatusNotFound, 1, nil)
+	session = newRetrySession(t, RetryOptions{Default: policy})
+	resp, err = session.SimpleGet(retriedCtx, server.URL)
+	require.Error(t, err)
+	session.DiscardHTTPResponse(resp)
+	require.Equal(t, int32(1), requests.Load(), "client errors are not retried")
+
+	server, requests = failingServer(t, http.StatusServiceUnavailable, 1, nil)
+	session = newRetrySession(t, RetryOptions{Default: policy, Sources: map[string]RetryPolicy{"retried": {}}})
+	resp, err = session.SimpleGet(retriedCtx, server.URL)
+	require.Error(t, err)
+	session.DiscardHTTPResponse(resp)
+	require.Equal(t, int32(1), requests.Load(), "the policy of the source is used")
+}
+
+func TestRetryAfter(t *testing.T) {
+	policy := RetryPolicy{MaxRetries: 1, MinBackoff: time.Millisecond, MaxBackoff: 2 * time.Second}
+
+	server, requests := failingServer(t, http.StatusTooManyRequests, 1, map[string]string{"Retry-After": "1"})
+	session := newRetrySession(t, RetryOptions{Default: policy})
+	start := time.Now()
+	resp, err := session.SimpleGet(retriedCtx, server.URL)
+	require.NoError(t, err)
+	session.DiscardHTTPResponse(resp)
+	require.GreaterOrEqual(t, time.Since(start), time.Second, "the Retry-After header is respected")
+	require.Equal(t, int32(2), requests.Load())
+
+	server, requests = failingServer(t, http.StatusTooManyRequests, 1, map[string]string{"Retry-After": strconv.Itoa(3600)})
+	session = newRetrySession(t, RetryOptions{Default: policy})
+	resp, err = session.SimpleGet(retriedCtx, server.URL)
+	require.Error(t, err)
+	session.DiscardHTTPResponse(resp)
+	require.Equal(t, int32(1), requests.Load(), "a Retry-After longer than the maximum backoff is not waited for")
+
+	reset := strconv.FormatInt(time.Now().Add(time.Second).Unix(), 10)
+	server, requests = failingServer(t, http.StatusForbidden, 1, map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": reset})
+	session = newRetrySession(t, RetryOptions{Default: policy})
+	resp, err = session.SimpleGet(retriedCtx, server.URL)
+	require.NoError(t, err, "a 403 with an exhausted quota is retried once the quota is reset")
+	session.DiscardHTTPResponse(resp)
+	require.Equal(t, int32(2), requests.Load())
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	server, requests := failingServer(t, http.StatusBadGateway, math.MaxInt32, nil)
+	session := newRetrySession(t, RetryOptions{BreakerThreshold: 3, BreakerCooldown: time.Hour})
+
+	for range 3 {
+		resp, err := session.SimpleGet(retriedCtx, server.URL)
+		require.Error(t, err)
+		require.NotErrorIs(t, err, ErrCircuitOpen)
+		session.DiscardHTTPResponse(resp)
+	}
+	_, err := session.SimpleGet(retriedCtx, server.URL)
+	require.ErrorIs(t, err, ErrCircuitOpen)
+	require.Equal(t, int32(3), requests.Load(), "no request is sent while the breaker is open")
+
+	otherCtx := context.WithValue(context.Background(), CtxSourceArg, "other")
+	require.NoError(t, session.MultiRateLimiter.Add(&ratelimit.Options{Key: "other", IsUnlimited: true, MaxCount: math.MaxUint32}))
+	resp, err := session.SimpleGet(otherCtx, server.URL)
+	require.NotErrorIs(t, err, ErrCircuitOpen, "the breakers are per source")
+	session.DiscardHTTPResponse(resp)
+}
+
+func TestBackoff(t *testing.T) {
+	policy := RetryPolicy{MinBackoff: time.Second, MaxBackoff: 5 * time.Second}
+	for retry, maxWait := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 10: 5 * time.Second} {
+		wait, ok := policy.backoff(retry, nil)
+		require.True(t, ok)
+		require.LessOrEqual(t, wait, maxWait)
+		require.GreaterOrEqual(t, wait, maxWait/2, "the jitter keeps half of the backoff")
+	}
+}
@@ -50,7 +50,7 @@ func (s *Source) Run(ctx context.Context, domain string, session *subscraping.Se
 			close(results)
 		}(time.Now())
 
-		randomApiKey := subscraping.PickRandom(s.apiKeys, s.Name())
+		ctx, randomApiKey := session.Key(ctx, s.apiKeys)
 		if randomApiKey == "" {
 			return
 		}
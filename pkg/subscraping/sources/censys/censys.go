@@ -90,11 +90,16 @@ func (s *Source) Run(ctx context.Context, domain string, session *subscraping.Se
 			close(results)
 		}(time.Now())
 
-		// PickRandom selects a random API key from configured keys.
-		// This enables load balancing when users configure multiple PATs
+		// The session hands out the healthy keys in turn, which balances the
+		// requests when users configure multiple PATs
 		// (e.g., CENSYS_API_KEY=pat1:org1,pat2:org2) to distribute requests
 		// and avoid hitting rate limits on a single key.
-		randomApiKey := subscraping.PickRandom(s.apiKeys, s.Name())
+		ctx, randomApiKey := subscraping.PickKey(ctx, session, s.apiKeys, func(key apiKey) string {
+			if key.orgID == "" {
+				return key.pat
+			}
+			return key.pat + ":" + key.orgID
+		})
 		if randomApiKey.pat == "" {
 			s.skipped = true
 			return
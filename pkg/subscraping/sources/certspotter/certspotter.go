@@ -12,8 +12,9 @@ import (
 )
 
 type certspotterObject struct {
-	ID       string   `json:"id"`
-	DNSNames []string `json:"dns_names"`
+	ID        string    `json:"id"`
+	DNSNames  []string  `json:"dns_names"`
+	NotBefore time.Time `json:"not_before"`
 }
 
 // Source is the passive scraping agent
@@ -37,7 +38,7 @@ func (s *Source) Run(ctx context.Context, domain string, session *subscraping.Se
 			close(results)
 		}(time.Now())
 
-		randomApiKey := subscraping.PickRandom(s.apiKeys, s.Name())
+		ctx, randomApiKey := session.Key(ctx, s.apiKeys)
 		if randomApiKey == "" {
 			s.skipped = true
 			return
@@ -69,7 +70,7 @@ func (s *Source) Run(ctx context.Context, domain string, session *subscraping.Se
 				select {
 				case <-ctx.Done():
 					return
-				case results <- subscraping.Result{Source: s.Name(), Type: subscraping.Subdomain, Value: subdomain}:
+				case results <- subscraping.Result{Source: s.Name(), Type: subscraping.Subdomain, Value: subdomain, Metadata: &subscraping.Metadata{Evidence: cert.ID, Timestamp: cert.NotBefore}}:
 					s.results++
 				}
 			}
@@ -114,7 +115,7 @@ func (s *Source) Run(ctx context.Context, domain string, session *subscraping.Se
 					select {
 					case <-ctx.Done():
 						return
-					case results <- subscraping.Result{Source: s.Name(), Type: subscraping.Subdomain, Value: subdomain}:
+					case results <- subscraping.Result{Source: s.Name(), Type: subscraping.Subdomain, Value: subdomain, Metadata: &subscraping.Metadata{Evidence: cert.ID, Timestamp: cert.NotBefore}}:
 						s.results++
 					}
 				}
@@ -20,7 +20,7 @@ type Source struct {
 }
 
 // Run function returns all subdomains found with the service
-func (s *Source) Run(ctx context.Context, domain string, _ *subscraping.Session) <-chan subscraping.Result {
+func (s *Source) Run(ctx context.Context, domain string, session *subscraping.Session) <-chan subscraping.Result {
 	results := make(chan subscraping.Result)
 	s.errors = 0
 	s.results = 0
@@ -31,7 +31,7 @@ func (s *Source) Run(ctx context.Context, domain string, _ *subscraping.Session)
 			close(results)
 		}(time.Now())
 
-		randomApiKey := subscraping.PickRandom(s.apiKeys, s.Name())
+		ctx, randomApiKey := session.Key(ctx, s.apiKeys)
 		if randomApiKey == "" {
 			s.skipped = true
 			return
@@ -18,10 +18,19 @@ import (
 const (
 	indexURL     = "https://index.commoncrawl.org/collinfo.json"
 	maxYearsBack = 5
+	// timestampLayout is the layout of the capture timestamps of the index
+	timestampLayout = "20060102150405"
 )
 
 var year = time.Now().Year()
 
+// capture is the JSON part of the index lines, which are
+// formatted as "<url key> <timestamp> <capture>"
+type capture struct {
+	URL       string `json:"url"`
+	Timestamp string `json:"timestamp"`
+}
+
 type indexResponse struct {
 	ID     string `json:"id"`
 	APIURL string `json:"cdx-api"`
@@ -148,6 +157,7 @@ func (s *Source) getSubdomains(ctx context.Context, searchURL, domain string, se
 				if line == "" {
 					continue
 				}
+				metadata := captureMetadata(line)
 				line, _ = url.QueryUnescape(line)
 				for _, subdomain := range session.Extractor.Extract(line) {
 					if subdomain != "" {
@@ -159,7 +169,7 @@ func (s *Source) getSubdomains(ctx context.Context, searchURL, domain string, se
 						case <-ctx.Done():
 							session.DiscardHTTPResponse(resp)
 							return false
-						case results <- subscraping.Result{Source: s.Name(), Type: subscraping.Subdomain, Value: subdomain}:
+						case results <- subscraping.Result{Source: s.Name(), Type: subscraping.Subdomain, Value: subdomain, Metadata: metadata}:
 							s.results++
 						}
 					}
@@ -170,3 +180,19 @@ func (s *Source) getSubdomains(ctx context.Context, searchURL, domain string, se
 		}
 	}
 }
+
+// captureMetadata returns the URL and time of the capture of an index line, nil if it cannot be parsed
+func captureMetadata(line string) *subscraping.Metadata {
+	fields := strings.SplitN(line, " ", 3)
+	if len(fields) != 3 {
+		return nil
+	}
+
+	var data capture
+	if err := jsoniter.UnmarshalFromString(fields[2], &data); err != nil || data.URL == "" {
+		return nil
+	}
+	metadata := &subscraping.Metadata{Evidence: data.URL}
+	metadata.Timestamp, _ = time.Parse(timestampLayout, data.Timestamp)
+	return metadata
+}
@@ -19,9 +19,18 @@ import (
 	contextutil "github.com/projectdiscovery/utils/context"
 )
 
+// entryTimestampLayout is the layout of the CT log entry timestamps, which have no time zone
+const entryTimestampLayout = "2006-01-02T15:04:05.999999999"
+
 type subdomain struct {
-	ID        int    `json:"id"`
-	NameValue string `json:"name_value"`
+	ID             int    `json:"id"`
+	NameValue      string `json:"name_value"`
+	EntryTimestamp string `json:"entry_timestamp"`
+}
+
+// metadata returns the certificate ID and the CT log entry time of a result
+func metadata(id int, entryTimestamp time.Time) *subscraping.Metadata {
+	return &subscraping.Metadata{Evidence: strconv.Itoa(id), Timestamp: entryTimestamp}
 }
 
 // Source is the passive scraping agent
@@ -90,7 +99,7 @@ func (s *Source) getSubdomainsFromSQL(ctx context.Context, domain string, sessio
 						) sub
 					GROUP BY sub.CERTIFICATE
 			)
-			SELECT array_to_string(ci.NAME_VALUES, chr(10)) NAME_VALUE
+			SELECT ci.ID, array_to_string(ci.NAME_VALUES, chr(10)) NAME_VALUE, le.ENTRY_TIMESTAMP
 				FROM ci
 						LEFT JOIN LATERAL (
 							SELECT min(ctle.ENTRY_TIMESTAMP) ENTRY_TIMESTAMP
@@ -113,14 +122,18 @@ func (s *Source) getSubdomainsFromSQL(ctx context.Context, domain string, sessio
 	}
 
 	var count int
-	var data string
+	var (
+		id             int
+		data           string
+		entryTimestamp sql.NullTime
+	)
 	for rows.Next() {
 		select {
 		case <-ctx.Done():
 			return count
 		default:
 		}
-		err := rows.Scan(&data)
+		err := rows.Scan(&id, &data, &entryTimestamp)
 		if err != nil {
 			results <- subscraping.Result{Source: s.Name(), Type: subscraping.Error, Error: err}
 			s.errors++
@@ -134,7 +147,7 @@ func (s *Source) getSubdomainsFromSQL(ctx context.Context, domain string, sessio
 					select {
 					case <-ctx.Done():
 						return count
-					case results <- subscraping.Result{Source: s.Name(), Type: subscraping.Subdomain, Value: value}:
+					case results <- subscraping.Result{Source: s.Name(), Type: subscraping.Subdomain, Value: value, Metadata: metadata(id, entryTimestamp.Time)}:
 						s.results++
 					}
 				}
@@ -170,13 +183,14 @@ func (s *Source) getSubdomainsFromHTTP(ctx context.Context, domain string, sessi
 			return true
 		default:
 		}
+		entryTimestamp, _ := time.Parse(entryTimestampLayout, subdomain.EntryTimestamp)
 		for sub := range strings.SplitSeq(subdomain.NameValue, "\n") {
 			for _, value := range session.Extractor.Extract(sub) {
 				if value != "" {
 					select {
 					case <-ctx.Done():
 						return true
-					case results <- subscraping.Result{Source: s.Name(), Type: subscraping.Subdomain, Value: value}:
+					case results <- subscraping.Result{Source: s.Name(), Type: subscraping.Subdomain, Value: value, Metadata: metadata(subdomain.ID, entryTimestamp)}:
 						s.results++
 					}
 				}
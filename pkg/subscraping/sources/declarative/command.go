@@ -0,0 +1,114 @@
+package declarative
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const (
+	// maxLineSize is the maximum size of a line of the output of a command
+	maxLineSize = 1024 * 1024
+	// maxStderrSize is the size of the end of the error output kept to report failures
+	maxStderrSize = 1024
+	// commandWaitDelay is the time given to a cancelled command to release its output
+	commandWaitDelay = 5 * time.Second
+)
+
+// runCommand runs the command of the definition and sends the subdomains of its output lines
+func (s *Source) runCommand(ctx context.Context, domain, apiKey string, send func([]string) (int, bool)) error {
+	d := s.definition
+	expand := func(template string) string {
+		return placeholderPattern.ReplaceAllStringFunc(template, func(placeholder string) string {
+			switch placeholderPattern.FindStringSubmatch(placeholder)[1] {
+			case "domain":
+				return domain
+			case "key":
+				return apiKey
+			}
+			return ""
+		})
+	}
+
+	args := make([]string, 0, len(d.Command))
+	for _, arg := range d.Command {
+		args = append(args, expand(arg))
+	}
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Env = append(os.Environ(), "SUBFINDER_DOMAIN="+domain)
+	if apiKey != "" {
+		cmd.Env = append(cmd.Env, "SUBFINDER_API_KEY="+apiKey)
+	}
+	for name, value := range d.Env {
+		cmd.Env = append(cmd.Env, name+"="+expand(value))
+	}
+	cmd.WaitDelay = commandWaitDelay
+	stderr := &tailBuffer{}
+	cmd.Stderr = stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), maxLineSize)
+	var recordErr error
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var document any
+		if d.json != nil {
+			if err := json.Unmarshal(line, &document); err != nil {
+				recordErr = fmt.Errorf("invalid JSON record %q: %w", line, err)
+				continue
+			}
+		}
+		if _, ok := send(d.extract(line, document)); !ok {
+			break
+		}
+	}
+	scanErr := scanner.Err()
+	if scanErr != nil {
+		// the command is stopped rather than blocked on its output
+		_ = cmd.Cancel()
+	}
+
+	if err := cmd.Wait(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return fmt.Errorf("%w: %s", err, message)
+		}
+		return err
+	}
+	if scanErr != nil {
+		return scanErr
+	}
+	return recordErr
+}
+
+// tailBuffer keeps the end of what is written to it
+type tailBuffer struct {
+	data []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.data = append(b.data, p...)
+	if len(b.data) > maxStderrSize {
+		b.data = b.data[len(b.data)-maxStderrSize:]
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	return string(b.data)
+}
@@ -0,0 +1,92 @@
+package declarative
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func skipWithoutShell(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the command sources are tested with sh")
+	}
+}
+
+func TestCommandLines(t *testing.T) {
+	skipWithoutShell(t)
+
+	source, subdomains, errs := runSource(t, `
+name: lines
+type: command
+needs-key: true
+command: [sh, -c, 'echo www.$SUBFINDER_DOMAIN; echo other.example.org; echo dev.{{domain}}; echo $SUBFINDER_API_KEY.$SUFFIX']
+env:
+  SUFFIX: "{{domain}}"
+`, "key")
+	require.Empty(t, errs)
+	require.Equal(t, []string{"www.example.com", "dev.example.com", "key.example.com"}, subdomains)
+	require.Equal(t, 3, source.Statistics().Results)
+}
+
+func TestCommandJSONRecords(t *testing.T) {
+	skipWithoutShell(t)
+
+	source, subdomains, errs := runSource(t, `
+name: records
+type: command
+command: [sh, -c, 'echo "{\"host\":\"www.$1\"}"; echo "not json"; echo "{\"host\":\"api.$1\"}"', sh, "{{domain}}"]
+extract:
+  json: host
+`)
+	require.Equal(t, []string{"www.example.com", "api.example.com"}, subdomains)
+	require.Len(t, errs, 1, "the invalid records are reported")
+	require.ErrorContains(t, errs[0], "invalid JSON record")
+	require.Equal(t, 1, source.Statistics().Errors)
+}
+
+func TestCommandFailure(t *testing.T) {
+	skipWithoutShell(t)
+
+	_, subdomains, errs := runSource(t, `
+name: failing
+type: command
+command: [sh, -c, 'echo www.example.com; echo quota exceeded >&2; exit 3']
+`)
+	require.Equal(t, []string{"www.example.com"}, subdomains)
+	require.Len(t, errs, 1)
+	require.ErrorContains(t, errs[0], "exit status 3: quota exceeded")
+}
+
+func TestCommandTimeout(t *testing.T) {
+	skipWithoutShell(t)
+
+	start := time.Now()
+	source, _, errs := runSource(t, `
+name: slow
+type: command
+command: [sh, -c, 'echo www.example.com; exec sleep 10']
+timeout: 200ms
+`)
+	require.Less(t, time.Since(start), 5*time.Second)
+	require.Len(t, errs, 1)
+	require.ErrorContains(t, errs[0], "timed out after 200ms")
+	require.Equal(t, 1, source.Statistics().Results, "the results found before the timeout are kept")
+}
+
+func TestCommandCancellation(t *testing.T) {
+	skipWithoutShell(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, _, errs := runSourceWithCtx(ctx, t, `
+name: cancelled
+type: command
+command: [sleep, "10"]
+`)
+	require.Less(t, time.Since(start), 5*time.Second)
+	require.Empty(t, errs, "the end of the enumeration is not an error of the source")
+}
@@ -0,0 +1,262 @@
+package declarative
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/projectdiscovery/subfinder/v2/pkg/subscraping"
+)
+
+// Source is the passive scraping agent of a definition
+type Source struct {
+	definition *Definition
+	apiKeys    []string
+	timeTaken  time.Duration
+	errors     int
+	results    int
+	skipped    bool
+}
+
+// New creates a source running a validated definition
+func New(definition *Definition) *Source {
+	return &Source{definition: definition}
+}
+
+// Run function returns all subdomains found with the service
+func (s *Source) Run(ctx context.Context, domain string, session *subscraping.Session) <-chan subscraping.Result {
+	results := make(chan subscraping.Result)
+	s.errors = 0
+	s.results = 0
+
+	go func() {
+		defer func(startTime time.Time) {
+			s.timeTaken = time.Since(startTime)
+			close(results)
+		}(time.Now())
+
+		var apiKey string
+		if s.definition.NeedsKey {
+			ctx, apiKey = session.Key(ctx, s.apiKeys)
+			if apiKey == "" {
+				s.skipped = true
+				return
+			}
+		}
+
+		enumerationCtx := ctx
+		if s.definition.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, s.definition.Timeout)
+			defer cancel()
+		}
+
+		seen := make(map[string]struct{})
+		send := func(values []string) (int, bool) {
+			return s.send(ctx, domain, values, seen, session, results)
+		}
+		var err error
+		if s.definition.Type == TypeCommand {
+			err = s.runCommand(ctx, domain, apiKey, send)
+		} else {
+			err = s.runHTTP(ctx, domain, apiKey, session, send)
+		}
+		if enumerationCtx.Err() != nil {
+			return
+		}
+		if ctx.Err() != nil {
+			err = fmt.Errorf("timed out after %s", s.definition.Timeout)
+		}
+		if err != nil {
+			results <- subscraping.Result{Source: s.Name(), Type: subscraping.Error, Error: err}
+			s.errors++
+		}
+	}()
+
+	return results
+}
+
+// runHTTP requests the pages of the definition and sends their subdomains
+func (s *Source) runHTTP(ctx context.Context, domain, apiKey string, session *subscraping.Session, send func([]string) (int, bool)) error {
+	pagination := s.definition.Pagination
+	page := pagination.Start
+	var cursor string
+	for requests := 1; ; requests++ {
+		values, nextCursor, err := s.request(ctx, domain, apiKey, strconv.Itoa(page), cursor, session)
+		if err != nil {
+			return err
+		}
+		found, ok := send(values)
+		if !ok || requests >= s.definition.maxPages {
+			return nil
+		}
+		switch pagination.Type {
+		case PaginationPage:
+			if found == 0 {
+				return nil
+			}
+			page += pagination.Step
+		case PaginationCursor:
+			if nextCursor == "" || nextCursor == cursor {
+				return nil
+			}
+			cursor = nextCursor
+		default:
+			return nil
+		}
+	}
+}
+
+// send sends the subdomains found in the values which were not seen yet, returning
+// their number and whether the enumeration goes on
+func (s *Source) send(ctx context.Context, domain string, values []string, seen map[string]struct{}, session *subscraping.Session, results chan subscraping.Result) (int, bool) {
+	found := 0
+	for _, value := range values {
+		if s.definition.Extract.AppendDomain {
+			value += "." + domain
+		}
+		for _, subdomain := range session.Extractor.Extract(value) {
+			if _, ok := seen[subdomain]; ok {
+				continue
+			}
+			seen[subdomain] = struct{}{}
+			found++
+			select {
+			case <-ctx.Done():
+				return found, false
+			case results <- subscraping.Result{Source: s.Name(), Type: subscraping.Subdomain, Value: subdomain}:
+				s.results++
+			}
+		}
+	}
+	return found, true
+}
+
+// request requests a page and returns the values to extract the subdomains
+// from along with the cursor of the next page for cursor pagination
+func (s *Source) request(ctx context.Context, domain, apiKey, page, cursor string, session *subscraping.Session) ([]string, string, error) {
+	d := s.definition
+	expand := func(template string, escape bool) string {
+		return placeholderPattern.ReplaceAllStringFunc(template, func(placeholder string) string {
+			var value string
+			switch placeholderPattern.FindStringSubmatch(placeholder)[1] {
+			case "domain":
+				value = domain
+			case "key":
+				value = apiKey
+			case "page":
+				value = page
+			case "cursor":
+				value = cursor
+			}
+			if escape {
+				return url.QueryEscape(value)
+			}
+			return value
+		})
+	}
+
+	requestURL := expand(d.URL, true)
+	headers := make(map[string]string, len(d.Headers)+1)
+	for name, value := range d.Headers {
+		headers[name] = expand(value, false)
+	}
+	var basicAuth subscraping.BasicAuth
+	switch d.Auth.In {
+	case AuthHeader:
+		headers[d.Auth.Name] = d.Auth.Prefix + apiKey
+	case AuthQuery:
+		separator := "?"
+		if strings.Contains(requestURL, "?") {
+			separator = "&"
+		}
+		requestURL += separator + url.QueryEscape(d.Auth.Name) + "=" + url.QueryEscape(d.Auth.Prefix+apiKey)
+	case AuthBasic:
+		basicAuth.Username, basicAuth.Password, _ = strings.Cut(apiKey, ":")
+	}
+	var body io.Reader
+	if d.Body != "" {
+		body = strings.NewReader(expand(d.Body, false))
+	}
+
+	resp, err := session.HTTPRequest(ctx, d.Method, requestURL, "", headers, body, basicAuth)
+	if err != nil {
+		session.DiscardHTTPResponse(resp)
+		return nil, "", err
+	}
+	data, err := io.ReadAll(resp.Body)
+	session.DiscardHTTPResponse(resp)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var document any
+	if d.json != nil || d.cursor != nil {
+		if err := json.Unmarshal(data, &document); err != nil {
+			return nil, "", err
+		}
+	}
+	var nextCursor string
+	if d.cursor != nil {
+		if cursors := d.cursor.values(document); len(cursors) > 0 {
+			nextCursor = cursors[0]
+		}
+	}
+	return d.extract(data, document), nextCursor, nil
+}
+
+// extract returns the values to search for subdomains in the data, the
+// document being the data decoded when a JSON path is extracted
+func (d *Definition) extract(data []byte, document any) []string {
+	switch {
+	case d.json != nil:
+		return d.json.values(document)
+	case d.regex != nil:
+		var values []string
+		for _, match := range d.regex.FindAllSubmatch(data, -1) {
+			if len(match) > 1 {
+				values = append(values, string(match[1]))
+			} else {
+				values = append(values, string(match[0]))
+			}
+		}
+		return values
+	default:
+		return []string{string(data)}
+	}
+}
+
+// Name returns the name of the source
+func (s *Source) Name() string {
+	return s.definition.Name
+}
+
+func (s *Source) IsDefault() bool {
+	return s.definition.Default
+}
+
+func (s *Source) HasRecursiveSupport() bool {
+	return s.definition.Recursive
+}
+
+func (s *Source) NeedsKey() bool {
+	return s.definition.NeedsKey
+}
+
+func (s *Source) AddApiKeys(keys []string) {
+	s.apiKeys = keys
+}
+
+func (s *Source) Statistics() subscraping.Statistics {
+	return subscraping.Statistics{
+		Errors:    s.errors,
+		Results:   s.results,
+		TimeTaken: s.timeTaken,
+		Skipped:   s.skipped,
+	}
+}
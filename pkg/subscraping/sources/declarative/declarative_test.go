@@ -0,0 +1,237 @@
+package declarative
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/projectdiscovery/ratelimit"
+	"github.com/projectdiscovery/subfinder/v2/pkg/subscraping"
+	"github.com/stretchr/testify/require"
+)
+
+// runSource runs the source defined by the YAML definition against example.com
+func runSource(t *testing.T, definition string, keys ...string) (*Source, []string, []error) {
+	t.Helper()
+	return runSourceWithCtx(context.Background(), t, definition, keys...)
+}
+
+func runSourceWithCtx(ctx context.Context, t *testing.T, definition string, keys ...string) (*Source, []string, []error) {
+	t.Helper()
+
+	file := filepath.Join(t.TempDir(), "source.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(definition), 0600))
+	loaded, err := Load(file)
+	require.NoError(t, err)
+
+	extractor, err := subscraping.NewSubdomainExtractor("example.com")
+	require.NoError(t, err)
+	multiRateLimiter, err := ratelimit.NewMultiLimiter(context.Background(), &ratelimit.Options{Key: loaded.Name, IsUnlimited: true, MaxCount: math.MaxUint32})
+	require.NoError(t, err)
+	session := &subscraping.Session{Extractor: extractor, Client: http.DefaultClient, MultiRateLimiter: multiRateLimiter}
+	defer multiRateLimiter.Stop()
+
+	source := New(loaded)
+	source.AddApiKeys(keys)
+	ctx = context.WithValue(ctx, subscraping.CtxSourceArg, source.Name())
+	var subdomains []string
+	var errs []error
+	for result := range source.Run(ctx, "example.com", session) {
+		switch result.Type {
+		case subscraping.Subdomain:
+			subdomains = append(subdomains, result.Value)
+		case subscraping.Error:
+			errs = append(errs, result.Error)
+		}
+	}
+	return source, subdomains, errs
+}
+
+func TestPagePagination(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		require.Equal(t, "example.com", r.URL.Query().Get("domain"))
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		hosts := []map[string]string{}
+		if page <= 2 {
+			hosts = append(hosts, map[string]string{"hostname": fmt.Sprintf("page%d.example.com", page)})
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": hosts})
+	}))
+	defer server.Close()
+
+	source, subdomains, errs := runSource(t, `
+name: paged
+url: `+server.URL+`/hosts?domain={{domain}}&page={{page}}
+needs-key: true
+auth:
+  in: header
+  name: Authorization
+  prefix: "Bearer "
+pagination:
+  type: page
+  start: 1
+extract:
+  json: data[].hostname
+`, "secret")
+	require.Empty(t, errs)
+	require.Equal(t, []string{"page1.example.com", "page2.example.com"}, subdomains, "the pages are requested until one has no new subdomain")
+	require.Equal(t, 2, source.Statistics().Results)
+}
+
+func TestCursorPagination(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "secret", r.URL.Query().Get("apikey"))
+		switch r.URL.Query().Get("cursor") {
+		case "":
+			_ = json.NewEncoder(w).Encode(map[string]any{"subdomains": []string{"www"}, "meta": map[string]any{"next": "abc"}})
+		case "abc":
+			_ = json.NewEncoder(w).Encode(map[string]any{"subdomains": []string{"dev", "www"}, "meta": map[string]any{"next": ""}})
+		}
+	}))
+	defer server.Close()
+
+	_, subdomains, errs := runSource(t, `
+name: cursored
+url: `+server.URL+`/{{domain}}?cursor={{cursor}}
+needs-key: true
+auth:
+  in: query
+  name: apikey
+pagination:
+  type: cursor
+  cursor: meta.next
+extract:
+  json: subdomains
+  append-domain: true
+`, "secret")
+	require.Empty(t, errs)
+	require.Equal(t, []string{"www.example.com", "dev.example.com"}, subdomains)
+}
+
+func TestRegexExtraction(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		require.True(t, ok)
+		require.Equal(t, "user", username)
+		require.Equal(t, "pass", password)
+		_, _ = fmt.Fprint(w, "host=www.example.com\nother=dev.example.com\nhost=api.example.com\n")
+	}))
+	defer server.Close()
+
+	_, subdomains, errs := runSource(t, `
+name: regexed
+url: `+server.URL+`/?q={{domain}}
+needs-key: true
+auth:
+  in: basic
+extract:
+  regex: host=(\S+)
+`, "user:pass")
+	require.Empty(t, errs)
+	require.Equal(t, []string{"www.example.com", "api.example.com"}, subdomains)
+}
+
+func TestRunErrorsAndSkip(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	definition := `
+name: failing
+url: ` + server.URL + `/{{domain}}?key={{key}}
+needs-key: true
+`
+	source, _, errs := runSource(t, definition)
+	require.Empty(t, errs)
+	require.True(t, source.Statistics().Skipped, "the source is skipped without keys")
+
+	source, _, errs = runSource(t, definition, "secret")
+	require.Len(t, errs, 1)
+	require.Equal(t, 1, source.Statistics().Errors)
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name       string
+		definition Definition
+		err        string
+	}{
+		{"name", Definition{Name: "Bad Name", URL: "https://example.com/{{domain}}"}, "invalid name"},
+		{"url", Definition{Name: "source", URL: "example.com/{{domain}}"}, "invalid url"},
+		{"domain", Definition{Name: "source", URL: "https://example.com/"}, "{{domain}}"},
+		{"placeholder", Definition{Name: "source", URL: "https://example.com/{{domain}}/{{token}}"}, "unknown placeholder"},
+		{"method", Definition{Name: "source", URL: "https://example.com/{{domain}}", Method: "delete"}, "invalid method"},
+		{"auth", Definition{Name: "source", URL: "https://example.com/{{domain}}", NeedsKey: true, Auth: Auth{In: "cookie"}}, "invalid auth"},
+		{"auth name", Definition{Name: "source", URL: "https://example.com/{{domain}}", NeedsKey: true, Auth: Auth{In: AuthHeader}}, "name of the header"},
+		{"needs key", Definition{Name: "source", URL: "https://example.com/{{domain}}?key={{key}}"}, "needs-key"},
+		{"rate limit", Definition{Name: "source", URL: "https://example.com/{{domain}}", RateLimit: "fast"}, "invalid rate-limit"},
+		{"page", Definition{Name: "source", URL: "https://example.com/{{domain}}", Pagination: Pagination{Type: PaginationPage}}, "{{page}}"},
+		{"cursor", Definition{Name: "source", URL: "https://example.com/{{domain}}?c={{cursor}}", Pagination: Pagination{Type: PaginationCursor}}, "invalid cursor path"},
+		{"extract", Definition{Name: "source", URL: "https://example.com/{{domain}}", Extract: Extract{JSON: "a", Regex: "b"}}, "only one"},
+		{"json", Definition{Name: "source", URL: "https://example.com/{{domain}}", Extract: Extract{JSON: "a..b"}}, "invalid json path"},
+		{"regex", Definition{Name: "source", URL: "https://example.com/{{domain}}", Extract: Extract{Regex: "("}}, "invalid regex"},
+		{"type", Definition{Name: "source", Type: "ftp", URL: "https://example.com/{{domain}}"}, "invalid type"},
+		{"command", Definition{Name: "source", Type: TypeCommand}, "command is missing"},
+		{"command url", Definition{Name: "source", Type: TypeCommand, Command: []string{"tool"}, URL: "https://example.com/{{domain}}"}, "not supported by command sources"},
+		{"command page", Definition{Name: "source", Type: TypeCommand, Command: []string{"tool", "{{page}}"}}, "not supported by command sources"},
+		{"timeout", Definition{Name: "source", Type: TypeCommand, Command: []string{"tool"}, Timeout: -time.Second}, "invalid timeout"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.ErrorContains(t, test.definition.Validate(), test.err)
+		})
+	}
+
+	valid := Definition{Name: "source", URL: "https://example.com/{{domain}}", RateLimit: "10/s"}
+	require.NoError(t, valid.Validate())
+	require.Equal(t, http.MethodGet, valid.Method)
+}
+
+func TestLoadDirectory(t *testing.T) {
+	definitions, err := LoadDirectory(filepath.Join(t.TempDir(), "missing"))
+	require.NoError(t, err, "a missing directory holds no source")
+	require.Empty(t, definitions)
+
+	directory := t.TempDir()
+	files := map[string]string{
+		"first.yaml":  "name: first\nurl: https://example.com/{{domain}}\n",
+		"second.yml":  "name: first\nurl: https://example.org/{{domain}}\n",
+		"broken.yaml": "name: broken\nurl: https://example.com/\n",
+		"notes.txt":   "not a source",
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(directory, name), []byte(content), 0600))
+	}
+
+	definitions, err = LoadDirectory(directory)
+	require.Len(t, definitions, 1)
+	require.Equal(t, "first", definitions[0].Name)
+	require.ErrorContains(t, err, "broken.yaml")
+	require.ErrorContains(t, err, "source first is already defined")
+}
+
+func TestJSONPath(t *testing.T) {
+	var document any
+	require.NoError(t, json.Unmarshal([]byte(`{"data":{"items":[{"name":"a"},{"name":"b"},{"id":1}]},"list":["c",2],"next":"d"}`), &document))
+
+	for path, expected := range map[string][]string{
+		"data.items[].name": {"a", "b"},
+		"list":              {"c", "2"},
+		"next":              {"d"},
+		"data.missing":      nil,
+	} {
+		parsed, err := parseJSONPath(path)
+		require.NoError(t, err)
+		require.Equal(t, expected, parsed.values(document), path)
+	}
+}
@@ -0,0 +1,310 @@
+// Package declarative implements the sources defined in YAML files
+package declarative
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/projectdiscovery/goflags"
+	"gopkg.in/yaml.v3"
+)
+
+// Places of the API key in the requests
+const (
+	AuthHeader = "header"
+	AuthQuery  = "query"
+	AuthBasic  = "basic"
+)
+
+// Pagination strategies
+const (
+	PaginationPage   = "page"
+	PaginationCursor = "cursor"
+)
+
+// Types of sources
+const (
+	TypeHTTP    = "http"
+	TypeCommand = "command"
+)
+
+// DefaultMaxPages is the number of pages requested when the definition has no limit
+const DefaultMaxPages = 10
+
+// Definition describes a source querying an HTTP API, e.g.
+//
+//	name: internaldns
+//	url: https://dns.example.internal/api/v1/{{domain}}/hosts?page={{page}}
+//	needs-key: true
+//	rate-limit: 5/s
+//	auth:
+//	  in: header
+//	  name: Authorization
+//	  prefix: "Bearer "
+//	pagination:
+//	  type: page
+//	  start: 1
+//	extract:
+//	  json: data[].hostname
+//
+// The URL, the body and the headers may use the {{domain}}, {{key}}, {{page}}
+// and {{cursor}} placeholders.
+//
+// A definition of the command type runs an external command instead, each line
+// of its output being a result, or a JSON record when a JSON path is extracted:
+//
+//	name: legacytool
+//	type: command
+//	command: [/opt/legacy/enumerate.sh, --json, "{{domain}}"]
+//	timeout: 5m
+//	extract:
+//	  json: host
+//
+// The arguments and the environment of the command may use the {{domain}} and
+// {{key}} placeholders, the command also receiving them in the SUBFINDER_DOMAIN
+// and SUBFINDER_API_KEY environment variables.
+type Definition struct {
+	Name string `yaml:"name"`
+	// Type is http, the default, or command
+	Type      string            `yaml:"type,omitempty"`
+	Command   []string          `yaml:"command,omitempty"`
+	Env       map[string]string `yaml:"env,omitempty"`
+	URL       string            `yaml:"url"`
+	Method    string            `yaml:"method,omitempty"`
+	Body      string            `yaml:"body,omitempty"`
+	Headers   map[string]string `yaml:"headers,omitempty"`
+	NeedsKey  bool              `yaml:"needs-key,omitempty"`
+	Default   bool              `yaml:"default,omitempty"`
+	Recursive bool              `yaml:"recursive,omitempty"`
+	// Timeout bounds the run of the source for a domain, the enumeration time being used by default
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+	// RateLimit is the default rate limit of the source in the -rls format (10/s)
+	RateLimit  string     `yaml:"rate-limit,omitempty"`
+	Auth       Auth       `yaml:"auth,omitempty"`
+	Pagination Pagination `yaml:"pagination,omitempty"`
+	Extract    Extract    `yaml:"extract,omitempty"`
+
+	regex    *regexp.Regexp
+	json     jsonPath
+	cursor   jsonPath
+	maxPages int
+}
+
+// Auth is the place of the API key in the requests
+type Auth struct {
+	// In is header, query or basic, the key being in the user:password format for the latter
+	In     string `yaml:"in"`
+	Name   string `yaml:"name,omitempty"`
+	Prefix string `yaml:"prefix,omitempty"`
+}
+
+// Pagination is the strategy used to request the next pages
+type Pagination struct {
+	// Type is page, where {{page}} starts at Start and is incremented by Step
+	// until a page returns no new subdomain, or cursor, where {{cursor}} is
+	// read from the Cursor JSON path of the previous response until it is empty
+	Type     string `yaml:"type"`
+	Start    int    `yaml:"start,omitempty"`
+	Step     int    `yaml:"step,omitempty"`
+	Cursor   string `yaml:"cursor,omitempty"`
+	MaxPages int    `yaml:"max-pages,omitempty"`
+}
+
+// Extract is how the subdomains are read from the responses, the
+// whole response being searched for subdomains by default
+type Extract struct {
+	// JSON is the path of the values in the response (data.items[].name)
+	JSON string `yaml:"json,omitempty"`
+	// Regex matches the values in the response, the first group being used when present
+	Regex string `yaml:"regex,omitempty"`
+	// AppendDomain is set when the values are labels rather than subdomains
+	AppendDomain bool `yaml:"append-domain,omitempty"`
+}
+
+var (
+	namePattern        = regexp.MustCompile(`^[a-z0-9_-]+$`)
+	placeholderPattern = regexp.MustCompile(`{{\s*([a-z]+)\s*}}`)
+	placeholders       = []string{"domain", "key", "page", "cursor"}
+)
+
+// LoadDirectory loads the definitions of the YAML files of a directory,
+// a missing directory holding none. All the invalid files are reported.
+func LoadDirectory(directory string) ([]*Definition, error) {
+	entries, err := os.ReadDir(directory)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var definitions []*Definition
+	var errs []error
+	names := make(map[string]string)
+	for _, entry := range entries {
+		extension := filepath.Ext(entry.Name())
+		if entry.IsDir() || (extension != ".yaml" && extension != ".yml") {
+			continue
+		}
+		file := filepath.Join(directory, entry.Name())
+		definition, err := Load(file)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if other, ok := names[definition.Name]; ok {
+			errs = append(errs, fmt.Errorf("%s: source %s is already defined in %s", file, definition.Name, other))
+			continue
+		}
+		names[definition.Name] = file
+		definitions = append(definitions, definition)
+	}
+	return definitions, errors.Join(errs...)
+}
+
+// Load loads and validates the definition of a YAML file
+func Load(file string) (*Definition, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	definition := &Definition{}
+	if err := yaml.Unmarshal(data, definition); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	if err := definition.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return definition, nil
+}
+
+// Validate checks the definition and prepares it to be run
+func (d *Definition) Validate() error {
+	if !namePattern.MatchString(d.Name) {
+		return fmt.Errorf("invalid name %q, expected lower case letters, digits, - and _", d.Name)
+	}
+
+	var templates []string
+	switch d.Type {
+	case "", TypeHTTP:
+		d.Type = TypeHTTP
+		parsedURL, err := url.Parse(d.URL)
+		if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") || parsedURL.Host == "" {
+			return fmt.Errorf("invalid url %q, expected an absolute http(s) url", d.URL)
+		}
+		templates = append(templates, d.URL, d.Body)
+		for _, value := range d.Headers {
+			templates = append(templates, value)
+		}
+
+		if d.Method == "" {
+			d.Method = http.MethodGet
+		}
+		d.Method = strings.ToUpper(d.Method)
+		if d.Method != http.MethodGet && d.Method != http.MethodPost {
+			return fmt.Errorf("invalid method %s, expected GET or POST", d.Method)
+		}
+	case TypeCommand:
+		if len(d.Command) == 0 || d.Command[0] == "" {
+			return errors.New("the command is missing")
+		}
+		if d.URL != "" || d.Method != "" || d.Body != "" || len(d.Headers) > 0 || d.Auth.In != "" || d.Pagination.Type != "" {
+			return errors.New("url, method, body, headers, auth and pagination are not supported by command sources")
+		}
+		templates = append(templates, d.Command...)
+		for _, value := range d.Env {
+			templates = append(templates, value)
+		}
+	default:
+		return fmt.Errorf("invalid type %s, expected http or command", d.Type)
+	}
+	if d.Timeout < 0 {
+		return fmt.Errorf("invalid timeout %s", d.Timeout)
+	}
+
+	used := make(map[string]bool)
+	for _, template := range templates {
+		for _, match := range placeholderPattern.FindAllStringSubmatch(template, -1) {
+			if !slices.Contains(placeholders, match[1]) {
+				return fmt.Errorf("unknown placeholder %s, expected one of %s", match[0], strings.Join(placeholders, ", "))
+			}
+			used[match[1]] = true
+		}
+	}
+	// commands also receive the domain in the SUBFINDER_DOMAIN environment variable
+	if !used["domain"] && d.Type == TypeHTTP {
+		return errors.New("the {{domain}} placeholder is not used")
+	}
+	if (used["page"] || used["cursor"]) && d.Type == TypeCommand {
+		return errors.New("the {{page}} and {{cursor}} placeholders are not supported by command sources")
+	}
+
+	switch d.Auth.In {
+	case "":
+	case AuthHeader, AuthQuery:
+		if d.Auth.Name == "" {
+			return fmt.Errorf("the name of the %s holding the key is missing", d.Auth.In)
+		}
+	case AuthBasic:
+	default:
+		return fmt.Errorf("invalid auth %s, expected header, query or basic", d.Auth.In)
+	}
+	if (d.Auth.In != "" || used["key"]) && !d.NeedsKey {
+		return errors.New("the key is used but needs-key is not set")
+	}
+
+	var err error
+	if d.RateLimit != "" {
+		var rateLimits goflags.RateLimitMap
+		if err := rateLimits.Set(d.Name + "=" + d.RateLimit); err != nil {
+			return fmt.Errorf("invalid rate-limit %s: %w", d.RateLimit, err)
+		}
+	}
+
+	d.maxPages = d.Pagination.MaxPages
+	if d.maxPages <= 0 {
+		d.maxPages = DefaultMaxPages
+	}
+	switch d.Pagination.Type {
+	case "":
+	case PaginationPage:
+		if !used["page"] {
+			return errors.New("page pagination requires the {{page}} placeholder")
+		}
+		if d.Pagination.Step == 0 {
+			d.Pagination.Step = 1
+		}
+	case PaginationCursor:
+		if !used["cursor"] {
+			return errors.New("cursor pagination requires the {{cursor}} placeholder")
+		}
+		if d.cursor, err = parseJSONPath(d.Pagination.Cursor); err != nil || d.Pagination.Cursor == "" {
+			return fmt.Errorf("invalid cursor path %q", d.Pagination.Cursor)
+		}
+	default:
+		return fmt.Errorf("invalid pagination %s, expected page or cursor", d.Pagination.Type)
+	}
+
+	if d.Extract.JSON != "" && d.Extract.Regex != "" {
+		return errors.New("only one of the json and regex extractions can be used")
+	}
+	if d.Extract.JSON != "" {
+		if d.json, err = parseJSONPath(d.Extract.JSON); err != nil {
+			return fmt.Errorf("invalid json path %q: %w", d.Extract.JSON, err)
+		}
+	}
+	if d.Extract.Regex != "" {
+		if d.regex, err = regexp.Compile(d.Extract.Regex); err != nil {
+			return fmt.Errorf("invalid regex: %w", err)
+		}
+	}
+	return nil
+}
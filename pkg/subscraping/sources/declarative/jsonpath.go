@@ -0,0 +1,83 @@
+package declarative
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// jsonPathSegment selects a field of an object, then all the elements of the array when each is set
+type jsonPathSegment struct {
+	field string
+	each  bool
+}
+
+// jsonPath is a dotted path in a decoded JSON document, [] selecting
+// all the elements of an array (data.items[].name)
+type jsonPath []jsonPathSegment
+
+func parseJSONPath(path string) (jsonPath, error) {
+	var parsed jsonPath
+	if path == "" {
+		return parsed, nil
+	}
+	for _, part := range strings.Split(path, ".") {
+		segment := jsonPathSegment{}
+		segment.field, segment.each = strings.CutSuffix(part, "[]")
+		if strings.ContainsAny(segment.field, "[]") || (segment.field == "" && !segment.each) {
+			return nil, errors.New("expected fields separated by dots and followed by [] for arrays")
+		}
+		parsed = append(parsed, segment)
+	}
+	return parsed, nil
+}
+
+// values returns the strings and numbers found at the path, the
+// elements of an array being returned when the path ends on it
+func (path jsonPath) values(document any) []string {
+	nodes := []any{document}
+	for _, segment := range path {
+		var next []any
+		for _, node := range nodes {
+			if segment.field != "" {
+				object, ok := node.(map[string]any)
+				if !ok {
+					continue
+				}
+				if node, ok = object[segment.field]; !ok {
+					continue
+				}
+			}
+			if !segment.each {
+				next = append(next, node)
+				continue
+			}
+			if array, ok := node.([]any); ok {
+				next = append(next, array...)
+			}
+		}
+		nodes = next
+	}
+
+	var values []string
+	for _, node := range nodes {
+		if array, ok := node.([]any); ok {
+			for _, element := range array {
+				values = appendScalar(values, element)
+			}
+			continue
+		}
+		values = appendScalar(values, node)
+	}
+	return values
+}
+
+func appendScalar(values []string, node any) []string {
+	switch value := node.(type) {
+	case string:
+		return append(values, value)
+	case float64:
+		return append(values, strconv.FormatFloat(value, 'f', -1, 64))
+	}
+	return values
+}
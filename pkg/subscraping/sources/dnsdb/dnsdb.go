@@ -25,6 +25,8 @@ type rateResponse struct {
 
 type rate struct {
 	OffsetMax json.Number `json:"offset_max"`
+	// Remaining is "n/a" for the keys without a quota
+	Remaining json.Number `json:"remaining"`
 }
 
 type safResponse struct {
@@ -60,7 +62,7 @@ func (s *Source) Run(ctx context.Context, domain string, session *subscraping.Se
 
 		sourceName := s.Name()
 
-		randomApiKey := subscraping.PickRandom(s.apiKeys, sourceName)
+		ctx, randomApiKey := session.Key(ctx, s.apiKeys)
 		if randomApiKey == "" {
 			return
 		}
@@ -227,3 +229,26 @@ func getMaxOffset(ctx context.Context, session *subscraping.Session, headers map
 
 	return offsetMax, nil
 }
+
+// VerifyKey checks a key against the rate limit endpoint, which does not count
+// against the quota, reporting the remaining queries
+func (s *Source) VerifyKey(ctx context.Context, key string, session *subscraping.Session) subscraping.KeyCheck {
+	resp, err := session.Get(ctx, fmt.Sprintf("%s/rate_limit", urlBase), "", map[string]string{"X-API-KEY": key})
+	defer session.DiscardHTTPResponse(resp)
+	check := subscraping.CheckKeyResponse(resp, err)
+	if err != nil {
+		return check
+	}
+
+	var data rateResponse
+	if err := jsoniter.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return subscraping.KeyCheck{Status: subscraping.KeyUnverified, Error: err}
+	}
+	if remaining, err := strconv.Atoi(data.Rate.Remaining.String()); err == nil {
+		check.Remaining = &remaining
+		if remaining == 0 && check.Status == subscraping.KeyValid {
+			check.Status = subscraping.KeyExhausted
+		}
+	}
+	return check
+}
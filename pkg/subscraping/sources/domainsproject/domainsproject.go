@@ -43,7 +43,7 @@ func (s *Source) Run(ctx context.Context, domain string, session *subscraping.Se
 			close(results)
 		}(time.Now())
 
-		randomApiKey := subscraping.PickRandom(s.apiKeys, s.Name())
+		ctx, randomApiKey := subscraping.PickKey(ctx, session, s.apiKeys, func(key apiKey) string { return key.username + ":" + key.password })
 		if randomApiKey.username == "" || randomApiKey.password == "" {
 			s.skipped = true
 			return
@@ -132,7 +132,7 @@ func (s *Source) runSubsource(ctx context.Context, domain string, session *subsc
 	}
 
 	// Pick an API key
-	randomApiKey := subscraping.PickRandom(s.apiKeys, s.Name())
+	ctx, randomApiKey := session.Key(ctx, s.apiKeys)
 	if randomApiKey != "" {
 		headers["authorization"] = "Bearer " + randomApiKey
 	}
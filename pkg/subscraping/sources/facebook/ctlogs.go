@@ -99,7 +99,11 @@ func (s *Source) Run(ctx context.Context, domain string, session *subscraping.Se
 			close(results)
 		}(time.Now())
 
-		key := subscraping.PickRandom(s.apiKeys, s.Name())
+		ctx, key := subscraping.PickKey(ctx, session, s.apiKeys, func(key apiKey) string { return key.AppID + ":" + key.Secret })
+		if !key.IsValid() {
+			s.skipped = true
+			return
+		}
 		domainsURL := fmt.Sprintf(domainsUrl, key.AccessToken, domain)
 
 		for {
@@ -47,7 +47,7 @@ func (s *Source) Run(ctx context.Context, domain string, session *subscraping.Se
 			close(results)
 		}(time.Now())
 
-		randomApiKey := subscraping.PickRandom(s.apiKeys, s.Name())
+		ctx, randomApiKey := subscraping.PickKey(ctx, session, s.apiKeys, func(key apiKey) string { return key.username + ":" + key.secret })
 		if randomApiKey.username == "" || randomApiKey.secret == "" {
 			s.skipped = true
 			return
@@ -135,3 +135,37 @@ func (s *Source) Statistics() subscraping.Statistics {
 		Skipped:   s.skipped,
 	}
 }
+
+type accountResponse struct {
+	Error          bool   `json:"error"`
+	ErrMsg         string `json:"errmsg"`
+	RemainAPIQuery int    `json:"remain_api_query"`
+}
+
+// VerifyKey checks a key against the account information endpoint, reporting
+// the remaining queries. Fofa rejects a key with a 200 status and an error.
+func (s *Source) VerifyKey(ctx context.Context, key string, session *subscraping.Session) subscraping.KeyCheck {
+	keys := subscraping.CreateApiKeys([]string{key}, func(k, v string) apiKey {
+		return apiKey{k, v}
+	})
+	if len(keys) == 0 || keys[0].username == "" || keys[0].secret == "" {
+		return subscraping.KeyCheck{Status: subscraping.KeyInvalid, Error: subscraping.ErrKeyFormat}
+	}
+
+	resp, err := session.SimpleGet(ctx, fmt.Sprintf("https://fofa.info/api/v1/info/my?email=%s&key=%s", keys[0].username, keys[0].secret))
+	defer session.DiscardHTTPResponse(resp)
+	check := subscraping.CheckKeyResponse(resp, err)
+	if err != nil {
+		return check
+	}
+
+	var data accountResponse
+	if err := jsoniter.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return subscraping.KeyCheck{Status: subscraping.KeyUnverified, Error: err}
+	}
+	if data.Error {
+		return subscraping.KeyCheck{Status: subscraping.KeyInvalid, Error: fmt.Errorf("%s", data.ErrMsg)}
+	}
+	check.Remaining = &data.RemainAPIQuery
+	return check
+}
@@ -37,7 +37,7 @@ func (s *Source) Run(ctx context.Context, domain string, session *subscraping.Se
 			close(results)
 		}(time.Now())
 
-		randomApiKey := subscraping.PickRandom(s.apiKeys, s.Name())
+		ctx, randomApiKey := session.Key(ctx, s.apiKeys)
 		if randomApiKey == "" {
 			s.skipped = true
 			return
@@ -102,3 +102,27 @@ func (s *Source) Statistics() subscraping.Statistics {
 		Skipped:   s.skipped,
 	}
 }
+
+type authStatusResponse struct {
+	UserCredits struct {
+		RemainingCredits int `json:"remaining_credits"`
+	} `json:"user_credits"`
+}
+
+// VerifyKey checks a key against the authentication status endpoint, reporting
+// the remaining credits
+func (s *Source) VerifyKey(ctx context.Context, key string, session *subscraping.Session) subscraping.KeyCheck {
+	resp, err := session.Get(ctx, "https://fullhunt.io/api/v1/auth/status", "", map[string]string{"X-API-KEY": key})
+	defer session.DiscardHTTPResponse(resp)
+	check := subscraping.CheckKeyResponse(resp, err)
+	if err != nil {
+		return check
+	}
+
+	var data authStatusResponse
+	if err := jsoniter.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return subscraping.KeyCheck{Status: subscraping.KeyUnverified, Error: err}
+	}
+	check.Remaining = &data.UserCredits.RemainingCredits
+	return check
+}
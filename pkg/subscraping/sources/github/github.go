@@ -9,7 +9,6 @@ import (
 	"net/http"
 	"net/url"
 	"regexp"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -64,47 +63,34 @@ func (s *Source) Run(ctx context.Context, domain string, session *subscraping.Se
 			return
 		}
 
-		tokens := NewTokenManager(s.apiKeys)
-
 		searchURL := fmt.Sprintf("https://api.github.com/search/code?per_page=100&q=%s&sort=created&order=asc", domain)
-		s.enumerate(ctx, searchURL, domainRegexp(domain), tokens, session, results)
+		s.enumerate(ctx, searchURL, domainRegexp(domain), session, results)
 	}()
 
 	return results
 }
 
-func (s *Source) enumerate(ctx context.Context, searchURL string, domainRegexp *regexp.Regexp, tokens *Tokens, session *subscraping.Session, results chan subscraping.Result) {
+func (s *Source) enumerate(ctx context.Context, searchURL string, domainRegexp *regexp.Regexp, session *subscraping.Session, results chan subscraping.Result) {
 	select {
 	case <-ctx.Done():
 		return
 	default:
 	}
 
-	token := tokens.Get()
-	headers := map[string]string{
-		"Accept": "application/vnd.github.v3.text-match+json", "Authorization": "token " + token.Hash,
-	}
-
-	// Initial request to GitHub search
-	resp, err := session.Get(ctx, searchURL, "", headers)
-	isForbidden := resp != nil && resp.StatusCode == http.StatusForbidden
-	if err != nil && !isForbidden {
+	// Each page is requested with the next healthy token, and again with the
+	// following one when GitHub rejects the token once its quota is exhausted
+	resp, err := session.WithKey(ctx, s.apiKeys, func(ctx context.Context, token string) (*http.Response, error) {
+		return session.Get(ctx, searchURL, "", map[string]string{
+			"Accept": "application/vnd.github.v3.text-match+json", "Authorization": "token " + token,
+		})
+	})
+	if err != nil {
 		results <- subscraping.Result{Source: s.Name(), Type: subscraping.Error, Error: err}
 		s.errors++
 		session.DiscardHTTPResponse(resp)
 		return
 	}
 
-	// Retry enumerarion after Retry-After seconds on rate limit abuse detected
-	ratelimitRemaining, _ := strconv.ParseInt(resp.Header.Get("X-Ratelimit-Remaining"), 10, 64)
-	if isForbidden && ratelimitRemaining == 0 {
-		retryAfterSeconds, _ := strconv.ParseInt(resp.Header.Get("Retry-After"), 10, 64)
-		tokens.setCurrentTokenExceeded(retryAfterSeconds)
-		session.DiscardHTTPResponse(resp)
-
-		s.enumerate(ctx, searchURL, domainRegexp, tokens, session, results)
-	}
-
 	var data response
 
 	// Marshall json response
@@ -141,7 +127,7 @@ func (s *Source) enumerate(ctx context.Context, searchURL string, domainRegexp *
 				s.errors++
 				return
 			}
-			s.enumerate(ctx, nextURL, domainRegexp, tokens, session, results)
+			s.enumerate(ctx, nextURL, domainRegexp, session, results)
 		}
 	}
 }
@@ -194,7 +180,7 @@ func (s *Source) proccesItems(ctx context.Context, items []item, domainRegexp *r
 						case <-ctx.Done():
 							session.DiscardHTTPResponse(resp)
 							return
-						case results <- subscraping.Result{Source: name, Type: subscraping.Subdomain, Value: subdomain}:
+						case results <- subscraping.Result{Source: name, Type: subscraping.Subdomain, Value: subdomain, Metadata: &subscraping.Metadata{Evidence: responseItem.HTMLURL}}:
 							s.results++
 						}
 					}
@@ -212,7 +198,7 @@ func (s *Source) proccesItems(ctx context.Context, items []item, domainRegexp *r
 					select {
 					case <-ctx.Done():
 						return
-					case results <- subscraping.Result{Source: name, Type: subscraping.Subdomain, Value: subdomain}:
+					case results <- subscraping.Result{Source: name, Type: subscraping.Subdomain, Value: subdomain, Metadata: &subscraping.Metadata{Evidence: responseItem.HTMLURL}}:
 						s.results++
 					}
 				}
@@ -281,3 +267,32 @@ func (s *Source) Statistics() subscraping.Statistics {
 		Skipped:   s.skipped,
 	}
 }
+
+type rateLimitResponse struct {
+	Resources struct {
+		Search struct {
+			Remaining int `json:"remaining"`
+		} `json:"search"`
+	} `json:"resources"`
+}
+
+// VerifyKey checks a token against the rate limit endpoint, which does not count
+// against the quota, reporting the remaining code searches
+func (s *Source) VerifyKey(ctx context.Context, key string, session *subscraping.Session) subscraping.KeyCheck {
+	resp, err := session.Get(ctx, "https://api.github.com/rate_limit", "", map[string]string{"Authorization": "token " + key})
+	defer session.DiscardHTTPResponse(resp)
+	check := subscraping.CheckKeyResponse(resp, err)
+	if err != nil {
+		return check
+	}
+
+	var data rateLimitResponse
+	if err := jsoniter.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return subscraping.KeyCheck{Status: subscraping.KeyUnverified, Error: err}
+	}
+	check.Remaining = &data.Resources.Search.Remaining
+	if data.Resources.Search.Remaining == 0 {
+		check.Status = subscraping.KeyExhausted
+	}
+	return check
+}
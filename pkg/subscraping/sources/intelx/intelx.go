@@ -63,7 +63,7 @@ func (s *Source) Run(ctx context.Context, domain string, session *subscraping.Se
 			close(results)
 		}(time.Now())
 
-		randomApiKey := subscraping.PickRandom(s.apiKeys, s.Name())
+		ctx, randomApiKey := subscraping.PickKey(ctx, session, s.apiKeys, func(key apiKey) string { return key.host + ":" + key.key })
 		if randomApiKey.host == "" || randomApiKey.key == "" {
 			s.skipped = true
 			return
@@ -183,3 +183,36 @@ func (s *Source) Statistics() subscraping.Statistics {
 		Skipped:   s.skipped,
 	}
 }
+
+type authenticateInfoResponse struct {
+	Paths map[string]struct {
+		Credit int `json:"Credit"`
+	} `json:"paths"`
+}
+
+// VerifyKey checks a key against the authentication information endpoint,
+// reporting the remaining phonebook searches
+func (s *Source) VerifyKey(ctx context.Context, key string, session *subscraping.Session) subscraping.KeyCheck {
+	keys := subscraping.CreateApiKeys([]string{key}, func(k, v string) apiKey {
+		return apiKey{k, v}
+	})
+	if len(keys) == 0 || keys[0].host == "" || keys[0].key == "" {
+		return subscraping.KeyCheck{Status: subscraping.KeyInvalid, Error: subscraping.ErrKeyFormat}
+	}
+
+	resp, err := session.SimpleGet(ctx, fmt.Sprintf("https://%s/authenticate/info?k=%s", keys[0].host, keys[0].key))
+	defer session.DiscardHTTPResponse(resp)
+	check := subscraping.CheckKeyResponse(resp, err)
+	if err != nil {
+		return check
+	}
+
+	var data authenticateInfoResponse
+	if err := jsoniter.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return subscraping.KeyCheck{Status: subscraping.KeyUnverified, Error: err}
+	}
+	if search, ok := data.Paths["/phonebook/search"]; ok {
+		check.Remaining = &search.Credit
+	}
+	return check
+}
@@ -35,7 +35,7 @@ func (s *Source) Run(ctx context.Context, domain string, session *subscraping.Se
 			"accept": "application/json",
 		}
 		// Pick an API key
-		randomApiKey := subscraping.PickRandom(s.apiKeys, s.Name())
+		ctx, randomApiKey := session.Key(ctx, s.apiKeys)
 		if randomApiKey != "" {
 			headers["api-key"] = randomApiKey
 		}
@@ -62,7 +62,7 @@ func (s *Source) Run(ctx context.Context, domain string, session *subscraping.Se
 		countUrl := endpoint + "?" + params.Encode()
 
 		// Pick an API key
-		randomApiKey := subscraping.PickRandom(s.apiKeys, s.Name())
+		ctx, randomApiKey := session.Key(ctx, s.apiKeys)
 		resp1, err := session.HTTPRequest(ctx, http.MethodGet, countUrl, "", map[string]string{
 			"accept":    "application/json",
 			"X-API-Key": randomApiKey,
@@ -118,7 +118,7 @@ func (s *Source) Run(ctx context.Context, domain string, session *subscraping.Se
 		}
 
 		// Pick an API key
-		randomApiKey = subscraping.PickRandom(s.apiKeys, s.Name())
+		ctx, randomApiKey = session.Key(ctx, s.apiKeys)
 
 		resp2, err := session.HTTPRequest(ctx, http.MethodPost, apiUrl, "", map[string]string{
 			"accept":       "application/json",
@@ -51,7 +51,7 @@ func (s *Source) Run(ctx context.Context, domain string, session *subscraping.Se
 			close(results)
 		}(time.Now())
 
-		randomApiKey := subscraping.PickRandom(s.apiKeys, s.Name())
+		ctx, randomApiKey := session.Key(ctx, s.apiKeys)
 		if randomApiKey == "" {
 			s.skipped = true
 			return
@@ -155,3 +155,28 @@ func (s *Source) Statistics() subscraping.Statistics {
 		Skipped:   s.skipped,
 	}
 }
+
+type userInfoResponse struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// VerifyKey checks a key against the user information endpoint. Quake rejects a
+// key with a 200 status and a non-zero code.
+func (s *Source) VerifyKey(ctx context.Context, key string, session *subscraping.Session) subscraping.KeyCheck {
+	resp, err := session.Get(ctx, "https://quake.360.net/api/v3/user/info", "", map[string]string{"X-QuakeToken": key})
+	defer session.DiscardHTTPResponse(resp)
+	check := subscraping.CheckKeyResponse(resp, err)
+	if err != nil {
+		return check
+	}
+
+	var data userInfoResponse
+	if err := jsoniter.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return subscraping.KeyCheck{Status: subscraping.KeyUnverified, Error: err}
+	}
+	if data.Code != 0 {
+		return subscraping.KeyCheck{Status: subscraping.KeyInvalid, Error: fmt.Errorf("%s", data.Message)}
+	}
+	return check
+}
@@ -46,7 +46,7 @@ func (s *Source) Run(ctx context.Context, domain string, session *subscraping.Se
 			close(results)
 		}(time.Now())
 
-		randomApiKey := subscraping.PickRandom(s.apiKeys, s.Name())
+		ctx, randomApiKey := session.Key(ctx, s.apiKeys)
 		if randomApiKey == "" {
 			s.skipped = true
 			return
@@ -158,3 +158,33 @@ func (s *Source) Statistics() subscraping.Statistics {
 		Skipped:   s.skipped,
 	}
 }
+
+type usageResponse struct {
+	CurrentMonthlyUsage int `json:"current_monthly_usage"`
+	AllowedMonthlyUsage int `json:"allowed_monthly_usage"`
+}
+
+// VerifyKey checks a key against the account usage endpoint, reporting the
+// remaining monthly queries
+func (s *Source) VerifyKey(ctx context.Context, key string, session *subscraping.Session) subscraping.KeyCheck {
+	resp, err := session.Get(ctx, "https://api.securitytrails.com/v1/account/usage", "", map[string]string{"APIKEY": key})
+	defer session.DiscardHTTPResponse(resp)
+	check := subscraping.CheckKeyResponse(resp, err)
+	if err != nil {
+		return check
+	}
+
+	var data usageResponse
+	if err := jsoniter.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return subscraping.KeyCheck{Status: subscraping.KeyUnverified, Error: err}
+	}
+	// the plans without a monthly allowance have no quota to report
+	if data.AllowedMonthlyUsage > 0 {
+		remaining := max(data.AllowedMonthlyUsage-data.CurrentMonthlyUsage, 0)
+		check.Remaining = &remaining
+		if remaining == 0 && check.Status == subscraping.KeyValid {
+			check.Status = subscraping.KeyExhausted
+		}
+	}
+	return check
+}
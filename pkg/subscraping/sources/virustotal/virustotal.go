@@ -45,7 +45,7 @@ func (s *Source) Run(ctx context.Context, domain string, session *subscraping.Se
 			close(results)
 		}(time.Now())
 
-		randomApiKey := subscraping.PickRandom(s.apiKeys, s.Name())
+		ctx, randomApiKey := session.Key(ctx, s.apiKeys)
 		if randomApiKey == "" {
 			return
 		}
@@ -129,3 +129,40 @@ func (s *Source) Statistics() subscraping.Statistics {
 		Skipped:   s.skipped,
 	}
 }
+
+type userResponse struct {
+	Data struct {
+		Attributes struct {
+			Quotas struct {
+				APIRequestsDaily struct {
+					Allowed int `json:"allowed"`
+					Used    int `json:"used"`
+				} `json:"api_requests_daily"`
+			} `json:"quotas"`
+		} `json:"attributes"`
+	} `json:"data"`
+}
+
+// VerifyKey checks a key against the user endpoint, which does not count against
+// the quota, reporting the remaining daily requests
+func (s *Source) VerifyKey(ctx context.Context, key string, session *subscraping.Session) subscraping.KeyCheck {
+	resp, err := session.Get(ctx, fmt.Sprintf("https://www.virustotal.com/api/v3/users/%s", key), "", map[string]string{"x-apikey": key})
+	defer session.DiscardHTTPResponse(resp)
+	check := subscraping.CheckKeyResponse(resp, err)
+	if err != nil {
+		return check
+	}
+
+	var data userResponse
+	if err := jsoniter.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return subscraping.KeyCheck{Status: subscraping.KeyUnverified, Error: err}
+	}
+	if daily := data.Data.Attributes.Quotas.APIRequestsDaily; daily.Allowed > 0 {
+		remaining := max(daily.Allowed-daily.Used, 0)
+		check.Remaining = &remaining
+		if remaining == 0 && check.Status == subscraping.KeyValid {
+			check.Status = subscraping.KeyExhausted
+		}
+	}
+	return check
+}
@@ -12,6 +12,9 @@ import (
 	"github.com/projectdiscovery/subfinder/v2/pkg/subscraping"
 )
 
+// timestampLayout is the layout of the capture timestamps of the archive
+const timestampLayout = "20060102150405"
+
 // Source is the passive scraping agent
 type Source struct {
 	timeTaken time.Duration
@@ -31,7 +34,7 @@ func (s *Source) Run(ctx context.Context, domain string, session *subscraping.Se
 			close(results)
 		}(time.Now())
 
-		resp, err := session.SimpleGet(ctx, fmt.Sprintf("http://web.archive.org/cdx/search/cdx?url=*.%s/*&output=txt&fl=original&collapse=urlkey", domain))
+		resp, err := session.SimpleGet(ctx, fmt.Sprintf("http://web.archive.org/cdx/search/cdx?url=*.%s/*&output=txt&fl=timestamp,original&collapse=urlkey", domain))
 		if err != nil {
 			results <- subscraping.Result{Source: s.Name(), Type: subscraping.Error, Error: err}
 			s.errors++
@@ -52,7 +55,15 @@ func (s *Source) Run(ctx context.Context, domain string, session *subscraping.Se
 			if line == "" {
 				continue
 			}
-			line, _ = url.QueryUnescape(line)
+			// Each line is the capture timestamp followed by the archived URL
+			timestamp, original, ok := strings.Cut(line, " ")
+			if !ok {
+				original = line
+			}
+			metadata := &subscraping.Metadata{Evidence: original}
+			metadata.Timestamp, _ = time.Parse(timestampLayout, timestamp)
+
+			line, _ = url.QueryUnescape(original)
 			for _, subdomain := range session.Extractor.Extract(line) {
 				subdomain = strings.ToLower(subdomain)
 				subdomain = strings.TrimPrefix(subdomain, "25")
@@ -61,7 +72,7 @@ func (s *Source) Run(ctx context.Context, domain string, session *subscraping.Se
 				select {
 				case <-ctx.Done():
 					return
-				case results <- subscraping.Result{Source: s.Name(), Type: subscraping.Subdomain, Value: subdomain}:
+				case results <- subscraping.Result{Source: s.Name(), Type: subscraping.Subdomain, Value: subdomain, Metadata: metadata}:
 					s.results++
 				}
 			}
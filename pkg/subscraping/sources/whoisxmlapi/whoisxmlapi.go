@@ -48,7 +48,7 @@ func (s *Source) Run(ctx context.Context, domain string, session *subscraping.Se
 			close(results)
 		}(time.Now())
 
-		randomApiKey := subscraping.PickRandom(s.apiKeys, s.Name())
+		ctx, randomApiKey := session.Key(ctx, s.apiKeys)
 		if randomApiKey == "" {
 			s.skipped = true
 			return
@@ -115,3 +115,10 @@ func (s *Source) Statistics() subscraping.Statistics {
 		Skipped:   s.skipped,
 	}
 }
+
+// VerifyKey checks a key against the account balance endpoint
+func (s *Source) VerifyKey(ctx context.Context, key string, session *subscraping.Session) subscraping.KeyCheck {
+	resp, err := session.SimpleGet(ctx, fmt.Sprintf("https://user.whoisxmlapi.com/user-service/account-balance?apiKey=%s", key))
+	defer session.DiscardHTTPResponse(resp)
+	return subscraping.CheckKeyResponse(resp, err)
+}
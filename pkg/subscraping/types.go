@@ -3,6 +3,7 @@ package subscraping
 import (
 	"context"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/projectdiscovery/ratelimit"
@@ -13,6 +14,8 @@ type CtxArg string
 
 const (
 	CtxSourceArg CtxArg = "source"
+	// CtxKeyArg is the API key the request was built with, set by Session.Key
+	CtxKeyArg CtxArg = "key"
 )
 
 type CustomRateLimit struct {
@@ -27,10 +30,13 @@ type BasicAuth struct {
 
 // Statistics contains statistics about the scraping process
 type Statistics struct {
-	TimeTaken time.Duration
-	Errors    int
-	Results   int
-	Skipped   bool
+	TimeTaken   time.Duration
+	Errors      int
+	Results     int
+	Skipped     bool
+	CacheHits   int
+	CacheMisses int
+	Retries     int
 }
 
 // Source is an interface inherited by each passive source
@@ -61,6 +67,14 @@ type Source interface {
 	Statistics() Statistics
 }
 
+// KeyVerifier is implemented by the sources which verify an API key with a
+// call to an account or usage endpoint of the provider, which does not spend a
+// search and reports the remaining quota when it can. The keys of the other
+// sources are left unverified.
+type KeyVerifier interface {
+	VerifyKey(ctx context.Context, key string, session *Session) KeyCheck
+}
+
 // SubdomainExtractor is an interface that defines the contract for subdomain extraction.
 type SubdomainExtractor interface {
 	Extract(text string) []string
@@ -75,6 +89,18 @@ type Session struct {
 	Client *http.Client
 	// Rate limit instance
 	MultiRateLimiter *ratelimit.MultiLimiter
+	// Cache stores the responses on disk to avoid repeating requests, nil disables caching
+	Cache *ResponseCache
+	// Retrier retries the failed requests, nil disables the retries
+	Retrier *Retrier
+	// Keys hands out the healthy API keys of the sources in turn and quarantines the rejected ones, nil disables it
+	Keys *KeyManager
+	// AdaptiveLimiter paces the requests at the learned rate limits, nil disables it
+	AdaptiveLimiter *AdaptiveLimiter
+
+	domain    string
+	retriesMu sync.Mutex
+	retries   map[string]int
 }
 
 // Result is a result structure returned by a source
@@ -83,6 +109,16 @@ type Result struct {
 	Source string
 	Value  string
 	Error  error
+	// Metadata optionally describes where the source found the result
+	Metadata *Metadata
+}
+
+// Metadata contains the raw evidence backing a result when the source provides it
+type Metadata struct {
+	// Evidence is a reference to the raw data, e.g. a certificate ID or an URL
+	Evidence string
+	// Timestamp is when the source observed the result, zero if unknown
+	Timestamp time.Time
 }
 
 // ResultType is the type of result returned by the source
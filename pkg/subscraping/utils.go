@@ -1,7 +1,9 @@
 package subscraping
 
 import (
+	"context"
 	"math/rand"
+	"slices"
 	"strings"
 
 	"github.com/projectdiscovery/gologger"
@@ -19,6 +21,22 @@ func PickRandom[T any](v []T, sourceName string) T {
 	return v[rand.Intn(length)]
 }
 
+// PickKey returns the key of the next requests of a source among its parsed keys,
+// the one Session.Key hands out given the keys the parsed keys were created from,
+// along with the context to send these requests with
+func PickKey[T any](ctx context.Context, session *Session, keys []T, raw func(T) string) (context.Context, T) {
+	rawKeys := make([]string, len(keys))
+	for i, key := range keys {
+		rawKeys[i] = raw(key)
+	}
+	ctx, picked := session.Key(ctx, rawKeys)
+	var result T
+	if index := slices.Index(rawKeys, picked); index >= 0 && picked != "" {
+		result = keys[index]
+	}
+	return ctx, result
+}
+
 func CreateApiKeys[T any](keys []string, provider func(k, v string) T) []T {
 	var result []T
 	for _, key := range keys {
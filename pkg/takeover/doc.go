@@ -0,0 +1,3 @@
+// Package takeover detects subdomain takeover candidates from the
+// CNAME chains of the resolved hosts and a table of service fingerprints.
+package takeover
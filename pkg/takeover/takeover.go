@@ -0,0 +1,83 @@
+package takeover
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// NXDomain is the DNS status of a dangling CNAME target
+const NXDomain = "NXDOMAIN"
+
+//go:embed fingerprints.yaml
+var defaultFingerprints []byte
+
+// Fingerprint identifies a third-party service by the domains its CNAME targets belong to
+type Fingerprint struct {
+	Service string   `yaml:"service"`
+	CNAME   []string `yaml:"cname"`
+}
+
+// Fingerprints is a table of service fingerprints
+type Fingerprints []Fingerprint
+
+// Default returns the built-in fingerprints table
+func Default() Fingerprints {
+	fingerprints, err := parse(defaultFingerprints)
+	if err != nil {
+		panic(fmt.Sprintf("invalid default takeover fingerprints: %s", err))
+	}
+	return fingerprints
+}
+
+// Load reads the fingerprints from a YAML file and appends them to the
+// built-in ones, so that the file only needs to contain the additions
+func Load(file string) (Fingerprints, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	fingerprints, err := parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse takeover fingerprints %s: %w", file, err)
+	}
+	return append(Default(), fingerprints...), nil
+}
+
+func parse(data []byte) (Fingerprints, error) {
+	var fingerprints Fingerprints
+	if err := yaml.Unmarshal(data, &fingerprints); err != nil {
+		return nil, err
+	}
+	for i, fingerprint := range fingerprints {
+		if fingerprint.Service == "" || len(fingerprint.CNAME) == 0 {
+			return nil, fmt.Errorf("fingerprint %d must have a service and at least one cname", i+1)
+		}
+		for j, cname := range fingerprint.CNAME {
+			fingerprints[i].CNAME[j] = strings.Trim(strings.ToLower(cname), ".")
+		}
+	}
+	return fingerprints, nil
+}
+
+// Check reports whether a host whose CNAME chain and DNS status are given is a
+// takeover candidate, that is its CNAME chain ends on a known service and the
+// target does not exist. The returned reason describes the match.
+func (f Fingerprints) Check(cnames []string, status string) (string, bool) {
+	if len(cnames) == 0 || status != NXDomain {
+		return "", false
+	}
+
+	target := strings.TrimSuffix(strings.ToLower(cnames[len(cnames)-1]), ".")
+	for _, fingerprint := range f {
+		for _, cname := range fingerprint.CNAME {
+			if target == cname || strings.HasSuffix(target, "."+cname) {
+				return fmt.Sprintf("cname %s of %s returns %s", target, fingerprint.Service, NXDomain), true
+			}
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,46 @@
+package takeover
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheck(t *testing.T) {
+	fingerprints := Default()
+
+	reason, ok := fingerprints.Check([]string{"www.example.com", "example-app.herokuapp.com"}, NXDomain)
+	require.True(t, ok)
+	require.Contains(t, reason, "heroku")
+
+	_, ok = fingerprints.Check([]string{"example-app.herokuapp.com"}, "NOERROR")
+	require.False(t, ok, "existing targets are not candidates")
+
+	_, ok = fingerprints.Check([]string{"cdn.example.net"}, NXDomain)
+	require.False(t, ok, "unknown services are not candidates")
+
+	_, ok = fingerprints.Check([]string{"notgithub.io"}, NXDomain)
+	require.False(t, ok, "only whole labels match")
+
+	_, ok = fingerprints.Check(nil, NXDomain)
+	require.False(t, ok)
+}
+
+func TestLoad(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "fingerprints.yaml")
+	require.NoError(t, os.WriteFile(file, []byte("- service: custom\n  cname:\n    - .Custom-Host.example.\n"), 0600))
+
+	fingerprints, err := Load(file)
+	require.NoError(t, err)
+	require.Len(t, fingerprints, len(Default())+1)
+
+	reason, ok := fingerprints.Check([]string{"app.custom-host.example"}, NXDomain)
+	require.True(t, ok)
+	require.Contains(t, reason, "custom")
+
+	require.NoError(t, os.WriteFile(file, []byte("- service: custom\n"), 0600))
+	_, err = Load(file)
+	require.Error(t, err)
+}